@@ -0,0 +1,451 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewDiscordAdapter())
+}
+
+const messageSizeMax = 2000
+
+// queueSize is the number of events waiting to be handled, events coming when it is full are dropped.
+const queueSize = 100
+
+const (
+	reconnectDelay = 5 * time.Second
+	// interactionTimeout is the time given to a command before deferring its response, discord waits 3 seconds.
+	interactionTimeout = 2500 * time.Millisecond
+)
+
+const (
+	interactionTypeCommand      = 2
+	responseTypeMessage         = 4
+	responseTypeDeferredMessage = 5
+	messageFlagEphemeral        = 1 << 6
+	commandTypeChatInput        = 1
+	commandOptionTypeString     = 3
+	commandTextOption           = "text"
+	commandDescriptionSizeMax   = 100
+)
+
+type DiscordConfig struct {
+	DiscordToken      string
+	DiscordGuildId    string
+	DiscordApiUrl     string
+	DiscordGatewayUrl string
+	DiscordChannels   []string
+}
+
+type message struct {
+	Id        string `json:"id"`
+	ChannelId string `json:"channel_id"`
+	GuildId   string `json:"guild_id"`
+	Author    user   `json:"author"`
+	Content   string `json:"content"`
+	Mentions  []user `json:"mentions"`
+}
+
+type interaction struct {
+	Id        string `json:"id"`
+	Type      int    `json:"type"`
+	Token     string `json:"token"`
+	GuildId   string `json:"guild_id"`
+	ChannelId string `json:"channel_id"`
+	Member    *struct {
+		User user `json:"user"`
+	} `json:"member"`
+	User *user `json:"user"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string      `json:"name"`
+			Value interface{} `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+type interactionData struct {
+	Content string `json:"content"`
+	Flags   int    `json:"flags,omitempty"`
+	// followUps are the parts of a response too long for one message, they are sent after the response
+	followUps []string
+}
+
+type interactionResponse struct {
+	Type int              `json:"type"`
+	Data *interactionData `json:"data,omitempty"`
+}
+
+type DiscordAdapter struct {
+	client     *client
+	config     *DiscordConfig
+	gubot      *robot.Gubot
+	me         user
+	appId      string
+	mutex      *sync.Mutex
+	writeMutex *sync.Mutex
+	channels   map[string]channel
+	conn       *websocket.Conn
+	gatewayUrl string
+	resumeUrl  string
+	sessionId  string
+	seq        int64
+	received   chan func()
+	stopper    *adapter.Stopper
+}
+
+func NewDiscordAdapter() robot.Adapter {
+	return &DiscordAdapter{
+		mutex:      new(sync.Mutex),
+		writeMutex: new(sync.Mutex),
+		channels:   make(map[string]channel),
+		received:   make(chan func(), queueSize),
+		stopper:    adapter.NewStopper(),
+	}
+}
+
+func (a *DiscordAdapter) Send(envelop robot.Envelop, message string) error {
+	channelId, err := a.envelopChannelId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.createMessages(channelId, "", message)
+}
+
+// Reply mention user and reference the message which triggered the reply.
+func (a *DiscordAdapter) Reply(envelop robot.Envelop, message string) error {
+	channelId, err := a.envelopChannelId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.createMessages(channelId, envelop.MessageId, "<@"+envelop.User.Id+"> "+message)
+}
+
+func (a *DiscordAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	channelId, err := a.client.createDM(envelop.User.Id)
+	if err != nil {
+		return err
+	}
+	return a.createMessages(channelId, "", message)
+}
+
+// createMessages send message in parts of discord max size, first part reference messageId if not empty.
+func (a *DiscordAdapter) createMessages(channelId, messageId, message string) error {
+	for i, msg := range adapter.TruncateMessage(message, messageSizeMax) {
+		toSend := createMessage{Content: msg}
+		if i == 0 && messageId != "" {
+			toSend.MessageReference = &messageReference{MessageId: messageId}
+		}
+		err := a.client.createMessage(channelId, toSend)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *DiscordAdapter) envelopChannelId(envelop robot.Envelop) (string, error) {
+	if envelop.ChannelId != "" {
+		return envelop.ChannelId, nil
+	}
+	if envelop.ChannelName == "" {
+		return "", errors.New("You must provide a channel name or channel id in envelop")
+	}
+	name := strings.TrimPrefix(envelop.ChannelName, "#")
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, ch := range a.channels {
+		if ch.Name == name {
+			return ch.Id, nil
+		}
+	}
+	return "", fmt.Errorf("Channel '%s' not found", envelop.ChannelName)
+}
+
+func (a *DiscordAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*DiscordConfig)
+	a.gubot = gubot
+	if conf.DiscordToken == "" {
+		return errors.New("discord_token config param is required")
+	}
+	a.config = conf
+	a.client = newClient(conf.DiscordApiUrl, conf.DiscordToken, gubot.HttpClient())
+	me, err := a.client.me()
+	if err != nil {
+		return err
+	}
+	a.me = me
+	appId, err := a.client.applicationId()
+	if err != nil {
+		return err
+	}
+	a.appId = appId
+	a.gatewayUrl = conf.DiscordGatewayUrl
+	if a.gatewayUrl == "" {
+		a.gatewayUrl, err = a.client.gatewayUrl()
+		if err != nil {
+			return err
+		}
+	}
+	go a.receiveLoop()
+	go a.gatewayLoop()
+	return nil
+}
+
+// Stop wait for messages and commands received to be handled before closing gateway connection.
+func (a *DiscordAdapter) Stop(ctx context.Context) error {
+	if !a.stopper.Close() {
+		return nil
+	}
+	err := a.stopper.Wait(ctx)
+	if err == nil {
+		close(a.received)
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.conn != nil {
+		return a.conn.Close()
+	}
+	return nil
+}
+
+func (a *DiscordAdapter) cacheChannel(ch channel) {
+	if ch.Id == "" {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.channels[ch.Id] = ch
+}
+
+// channelName give name of channel from cache or from discord, direct channels have no name.
+func (a *DiscordAdapter) channelName(channelId string) string {
+	a.mutex.Lock()
+	ch, ok := a.channels[channelId]
+	a.mutex.Unlock()
+	if ok {
+		return ch.Name
+	}
+	ch, err := a.client.channel(channelId)
+	if err != nil {
+		log.Errorf("Cannot get discord channel '%s': %s", channelId, err.Error())
+		return ""
+	}
+	a.cacheChannel(ch)
+	return ch.Name
+}
+
+func (a *DiscordAdapter) isListenedChannel(channelName string) bool {
+	for _, ch := range a.config.DiscordChannels {
+		if strings.TrimPrefix(ch, "#") == channelName {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *DiscordAdapter) toEnvelop(channelId, guildId string, author user) robot.Envelop {
+	channelName := a.channelName(channelId)
+	envelop := robot.Envelop{
+		ChannelId:   channelId,
+		ChannelName: channelName,
+		User: robot.UserEnvelop{
+			Id:          author.Id,
+			Name:        author.Username,
+			ChannelId:   channelId,
+			ChannelName: channelName,
+		},
+		Properties: make(map[string]interface{}),
+	}
+	if guildId != "" {
+		envelop.Properties["guild_id"] = guildId
+	}
+	return envelop
+}
+
+// enqueue give an event to receive loop without blocking gateway, event is dropped when adapter is stopped
+// or when too many events are waiting.
+func (a *DiscordAdapter) enqueue(handle func()) {
+	if !a.stopper.Begin() {
+		return
+	}
+	select {
+	case a.received <- handle:
+	default:
+		a.stopper.End()
+		log.Error("Too many discord events waiting to be handled, event dropped")
+	}
+}
+
+// receiveLoop handle events one by one so that they are answered in the order they were received.
+func (a *DiscordAdapter) receiveLoop() {
+	for handle := range a.received {
+		handle()
+		a.stopper.End()
+	}
+}
+
+func (a *DiscordAdapter) receive(msg message) {
+	if adapter.IsFromBot(msg.Author.Id, a.me.Id, msg.Author.Bot) {
+		return
+	}
+	direct := msg.GuildId == ""
+	envelop := a.toEnvelop(msg.ChannelId, msg.GuildId, msg.Author)
+	if !direct && len(a.config.DiscordChannels) > 0 && !a.isListenedChannel(envelop.ChannelName) {
+		return
+	}
+	mentioned := direct
+	for _, mention := range msg.Mentions {
+		if mention.Id == a.me.Id {
+			mentioned = true
+		}
+	}
+	text := msg.Content
+	if mentioned {
+		text = strings.Replace(text, "<@"+a.me.Id+">", "", -1)
+		text = strings.Replace(text, "<@!"+a.me.Id+">", "", -1)
+		text = strings.TrimSpace(text)
+	}
+	envelop.Message = text
+	envelop.MessageId = msg.Id
+	envelop.NotMentioned = !mentioned
+	a.gubot.Receive(envelop)
+}
+
+type commandResult struct {
+	response interface{}
+	err      error
+}
+
+// handleInteraction dispatch an application command, response is deferred when command takes too long.
+func (a *DiscordAdapter) handleInteraction(i interaction) {
+	if i.Type != interactionTypeCommand {
+		return
+	}
+	var author user
+	if i.Member != nil {
+		author = i.Member.User
+	} else if i.User != nil {
+		author = *i.User
+	}
+	envelop := a.toEnvelop(i.ChannelId, i.GuildId, author)
+	for _, option := range i.Data.Options {
+		if option.Name == commandTextOption {
+			envelop.Message = fmt.Sprint(option.Value)
+		}
+	}
+	done := make(chan commandResult, 1)
+	go func() {
+		response, err := a.gubot.DispatchCommand(robot.SlashCommandToken{
+			CommandName: a.commandTrigger(i.Data.Name),
+			AdapterName: a.Name(),
+		}, envelop)
+		done <- commandResult{response, err}
+	}()
+	var data *interactionData
+	select {
+	case result := <-done:
+		data = interactionResultData(result)
+		err := a.client.interactionCallback(i.Id, i.Token, interactionResponse{
+			Type: responseTypeMessage,
+			Data: data,
+		})
+		if err != nil {
+			log.Error("Error when responding to discord command: " + err.Error())
+			return
+		}
+	case <-time.After(interactionTimeout):
+		err := a.client.interactionCallback(i.Id, i.Token, interactionResponse{Type: responseTypeDeferredMessage})
+		if err != nil {
+			log.Error("Error when deferring discord command: " + err.Error())
+			return
+		}
+		data = interactionResultData(<-done)
+		err = a.client.editInteractionResponse(a.appId, i.Token, data)
+		if err != nil {
+			log.Error("Error when responding to discord command: " + err.Error())
+			return
+		}
+	}
+	for _, followUp := range data.followUps {
+		err := a.client.createFollowUp(a.appId, i.Token, &interactionData{Content: followUp, Flags: data.Flags})
+		if err != nil {
+			log.Error("Error when sending follow-up of discord command: " + err.Error())
+			return
+		}
+	}
+}
+
+// commandTrigger give the trigger of gubot slash command registered under name,
+// discord only accept lowercase names for commands.
+func (a *DiscordAdapter) commandTrigger(name string) string {
+	for _, slashCommand := range a.gubot.GetSlashCommands() {
+		if strings.EqualFold(slashCommand.Trigger, name) {
+			return slashCommand.Trigger
+		}
+	}
+	return name
+}
+
+func interactionResultData(result commandResult) *interactionData {
+	if result.err != nil {
+		log.Error(result.err.Error())
+		return &interactionData{Content: "Error: " + result.err.Error(), Flags: messageFlagEphemeral}
+	}
+	if data, ok := result.response.(*interactionData); ok {
+		return data
+	}
+	return &interactionData{Content: "Done.", Flags: messageFlagEphemeral}
+}
+
+func (a *DiscordAdapter) Format(message string) (interface{}, error) {
+	if message == "" {
+		return nil, nil
+	}
+	messages := adapter.TruncateMessage(message, messageSizeMax)
+	return &interactionData{Content: messages[0], followUps: messages[1:]}, nil
+}
+
+// Register create application command on discord, in guild DiscordGuildId if set (available immediately)
+// or globally otherwise.
+func (a *DiscordAdapter) Register(slashCommand robot.SlashCommand) ([]robot.SlashCommandToken, error) {
+	description := slashCommand.Description
+	if description == "" {
+		description = slashCommand.Title
+	}
+	description = adapter.TruncateText(description, commandDescriptionSizeMax)
+	err := a.client.registerCommand(a.appId, a.config.DiscordGuildId, applicationCommand{
+		Name:        strings.ToLower(slashCommand.Trigger),
+		Description: description,
+		Type:        commandTypeChatInput,
+		Options: []applicationCommandOption{{
+			Type:        commandOptionTypeString,
+			Name:        commandTextOption,
+			Description: "text given to the command",
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error when registering discord command '%s': %s", slashCommand.Trigger, err.Error())
+	}
+	return []robot.SlashCommandToken{}, nil
+}
+
+func (a DiscordAdapter) Name() string {
+	return "discord"
+}
+
+func (a DiscordAdapter) Config() interface{} {
+	return DiscordConfig{}
+}
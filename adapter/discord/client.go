@@ -0,0 +1,196 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DEFAULT_API_URL = "https://discord.com/api/v10"
+
+const maxRetries = 3
+
+// client call the discord rest api.
+type client struct {
+	apiUrl     string
+	token      string
+	httpClient *http.Client
+}
+
+type apiError struct {
+	Code       int     `json:"code"`
+	Message    string  `json:"message"`
+	RetryAfter float64 `json:"retry_after"`
+	status     int
+}
+
+func (e apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("discord api returned status %d", e.status)
+	}
+	return fmt.Sprintf("discord api error %d: %s", e.Code, e.Message)
+}
+
+type user struct {
+	Id       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot"`
+}
+
+type channel struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Type    int    `json:"type"`
+	GuildId string `json:"guild_id"`
+}
+
+type messageReference struct {
+	MessageId string `json:"message_id"`
+}
+
+type createMessage struct {
+	Content          string            `json:"content"`
+	MessageReference *messageReference `json:"message_reference,omitempty"`
+}
+
+type applicationCommand struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Type        int                        `json:"type"`
+	Options     []applicationCommandOption `json:"options,omitempty"`
+}
+
+type applicationCommandOption struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+func newClient(apiUrl, token string, httpClient *http.Client) *client {
+	if apiUrl == "" {
+		apiUrl = DEFAULT_API_URL
+	}
+	return &client{
+		apiUrl:     strings.TrimSuffix(apiUrl, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// do call api and retry when discord rate limit the request.
+func (c client) do(method, path string, body interface{}, result interface{}) error {
+	var b []byte
+	if body != nil {
+		var err error
+		b, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	for i := 0; ; i++ {
+		var reader io.Reader
+		if b != nil {
+			reader = bytes.NewReader(b)
+		}
+		err := c.request(method, path, reader, result)
+		apiErr, ok := err.(apiError)
+		if !ok || apiErr.status != http.StatusTooManyRequests || i >= maxRetries {
+			return err
+		}
+		time.Sleep(time.Duration(apiErr.RetryAfter * float64(time.Second)))
+	}
+}
+
+func (c client) request(method, path string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, c.apiUrl+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := apiError{status: resp.StatusCode}
+		json.Unmarshal(b, &apiErr)
+		return apiErr
+	}
+	if result == nil || len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, result)
+}
+
+func (c client) me() (user, error) {
+	var me user
+	err := c.do("GET", "/users/@me", nil, &me)
+	return me, err
+}
+
+func (c client) applicationId() (string, error) {
+	var app struct {
+		Id string `json:"id"`
+	}
+	err := c.do("GET", "/oauth2/applications/@me", nil, &app)
+	return app.Id, err
+}
+
+func (c client) gatewayUrl() (string, error) {
+	var gateway struct {
+		Url string `json:"url"`
+	}
+	err := c.do("GET", "/gateway/bot", nil, &gateway)
+	return gateway.Url, err
+}
+
+func (c client) createMessage(channelId string, message createMessage) error {
+	return c.do("POST", "/channels/"+channelId+"/messages", message, nil)
+}
+
+func (c client) channel(channelId string) (channel, error) {
+	var ch channel
+	err := c.do("GET", "/channels/"+channelId, nil, &ch)
+	return ch, err
+}
+
+func (c client) createDM(userId string) (string, error) {
+	var ch channel
+	err := c.do("POST", "/users/@me/channels", map[string]string{"recipient_id": userId}, &ch)
+	return ch.Id, err
+}
+
+// registerCommand create or update command, in guild if guildId is given otherwise globally.
+func (c client) registerCommand(appId, guildId string, command applicationCommand) error {
+	path := "/applications/" + appId + "/commands"
+	if guildId != "" {
+		path = "/applications/" + appId + "/guilds/" + guildId + "/commands"
+	}
+	return c.do("POST", path, command, nil)
+}
+
+func (c client) interactionCallback(interactionId, token string, response interface{}) error {
+	return c.do("POST", "/interactions/"+interactionId+"/"+token+"/callback", response, nil)
+}
+
+func (c client) editInteractionResponse(appId, token string, data interface{}) error {
+	return c.do("PATCH", "/webhooks/"+appId+"/"+token+"/messages/@original", data, nil)
+}
+
+func (c client) createFollowUp(appId, token string, data interface{}) error {
+	return c.do("POST", "/webhooks/"+appId+"/"+token, data, nil)
+}
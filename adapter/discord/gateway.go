@@ -0,0 +1,246 @@
+package discord
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opResume         = 6
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatAck   = 11
+)
+
+// intents are GUILDS, GUILD_MESSAGES, DIRECT_MESSAGES and MESSAGE_CONTENT.
+const intents = 1<<0 | 1<<9 | 1<<12 | 1<<15
+
+type payload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+type sendPayload struct {
+	Op int         `json:"op"`
+	D  interface{} `json:"d"`
+}
+
+type ready struct {
+	SessionId        string `json:"session_id"`
+	ResumeGatewayUrl string `json:"resume_gateway_url"`
+}
+
+// gatewayLoop keep a connection to the gateway, it resumes the session when connection is lost.
+func (a *DiscordAdapter) gatewayLoop() {
+	for {
+		err := a.runGateway()
+		if a.stopper.IsStopped() {
+			return
+		}
+		log.Error("Discord gateway connection lost, reconnecting: " + err.Error())
+		select {
+		case <-a.stopper.Stopped():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (a *DiscordAdapter) runGateway() error {
+	a.mutex.Lock()
+	gatewayUrl := a.gatewayUrl
+	sessionId := a.sessionId
+	if sessionId != "" && a.resumeUrl != "" {
+		gatewayUrl = a.resumeUrl
+	}
+	a.mutex.Unlock()
+	separator := "?"
+	if strings.Contains(gatewayUrl, "?") {
+		separator = "&"
+	}
+	conn, _, err := adapter.WebsocketDialer(a.gubot.HttpClient()).Dial(gatewayUrl+separator+"v=10&encoding=json", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	a.mutex.Lock()
+	a.conn = conn
+	a.mutex.Unlock()
+	if a.stopper.IsStopped() {
+		return errors.New("discord adapter is stopped")
+	}
+
+	var hello payload
+	err = conn.ReadJSON(&hello)
+	if err != nil {
+		return err
+	}
+	if hello.Op != opHello {
+		return fmt.Errorf("discord gateway sent op %d instead of hello", hello.Op)
+	}
+	var helloData struct {
+		HeartbeatInterval int `json:"heartbeat_interval"`
+	}
+	json.Unmarshal(hello.D, &helloData)
+
+	if sessionId != "" {
+		err = a.writeGateway(opResume, map[string]interface{}{
+			"token":      a.config.DiscordToken,
+			"session_id": sessionId,
+			"seq":        a.sequence(),
+		})
+	} else {
+		err = a.writeGateway(opIdentify, map[string]interface{}{
+			"token":   a.config.DiscordToken,
+			"intents": intents,
+			"properties": map[string]string{
+				"os":      "linux",
+				"browser": "gubot",
+				"device":  "gubot",
+			},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	closed := make(chan struct{})
+	defer close(closed)
+	acked := make(chan struct{}, 1)
+	go a.heartbeat(conn, time.Duration(helloData.HeartbeatInterval)*time.Millisecond, acked, closed)
+
+	for {
+		var p payload
+		err := conn.ReadJSON(&p)
+		if err != nil {
+			return err
+		}
+		if p.S != nil {
+			a.mutex.Lock()
+			a.seq = *p.S
+			a.mutex.Unlock()
+		}
+		switch p.Op {
+		case opDispatch:
+			a.dispatch(p.T, p.D)
+		case opHeartbeat:
+			a.writeGateway(opHeartbeat, a.sequence())
+		case opHeartbeatAck:
+			select {
+			case acked <- struct{}{}:
+			default:
+			}
+		case opReconnect:
+			return errors.New("discord gateway asked to reconnect")
+		case opInvalidSession:
+			resumable := false
+			json.Unmarshal(p.D, &resumable)
+			if !resumable {
+				a.mutex.Lock()
+				a.sessionId = ""
+				a.seq = 0
+				a.mutex.Unlock()
+			}
+			return errors.New("discord gateway invalidated session")
+		}
+	}
+}
+
+// heartbeat send heartbeats and close connection when gateway doesn't acknowledge them.
+func (a *DiscordAdapter) heartbeat(conn *websocket.Conn, interval time.Duration, acked, closed chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	waitingAck := false
+	for {
+		select {
+		case <-closed:
+			return
+		case <-acked:
+			waitingAck = false
+		case <-ticker.C:
+			if waitingAck {
+				log.Error("Discord gateway didn't acknowledge heartbeat")
+				conn.Close()
+				return
+			}
+			a.writeGateway(opHeartbeat, a.sequence())
+			waitingAck = true
+		}
+	}
+}
+
+func (a *DiscordAdapter) sequence() interface{} {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.seq == 0 {
+		return nil
+	}
+	return a.seq
+}
+
+func (a *DiscordAdapter) writeGateway(op int, data interface{}) error {
+	a.writeMutex.Lock()
+	defer a.writeMutex.Unlock()
+	a.mutex.Lock()
+	conn := a.conn
+	a.mutex.Unlock()
+	if conn == nil {
+		return errors.New("discord gateway is not connected")
+	}
+	return conn.WriteJSON(sendPayload{Op: op, D: data})
+}
+
+func (a *DiscordAdapter) dispatch(eventType string, data json.RawMessage) {
+	switch eventType {
+	case "READY":
+		var r ready
+		json.Unmarshal(data, &r)
+		a.mutex.Lock()
+		a.sessionId = r.SessionId
+		a.resumeUrl = r.ResumeGatewayUrl
+		a.mutex.Unlock()
+		log.Infof("Connected on discord as %s", a.me.Username)
+	case "GUILD_CREATE":
+		var guild struct {
+			Channels []channel `json:"channels"`
+		}
+		json.Unmarshal(data, &guild)
+		for _, ch := range guild.Channels {
+			a.cacheChannel(ch)
+		}
+	case "CHANNEL_CREATE", "CHANNEL_UPDATE":
+		var ch channel
+		json.Unmarshal(data, &ch)
+		a.cacheChannel(ch)
+	case "MESSAGE_CREATE":
+		var msg message
+		if err := json.Unmarshal(data, &msg); err == nil {
+			a.enqueue(func() {
+				a.receive(msg)
+			})
+		}
+	case "INTERACTION_CREATE":
+		var i interaction
+		if err := json.Unmarshal(data, &i); err == nil {
+			a.enqueue(func() {
+				a.handleInteraction(i)
+			})
+		}
+	}
+}
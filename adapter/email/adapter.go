@@ -0,0 +1,375 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	"github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewEmailAdapter())
+}
+
+type EmailConfig struct {
+	EmailAddress                   string
+	EmailName                      string
+	EmailSubject                   string
+	EmailAllowedSenders            []string
+	EmailSmtpHost                  string
+	EmailSmtpPort                  int `cloud-default:"587"`
+	EmailSmtpUser                  string
+	EmailSmtpPassword              string
+	EmailSmtpTls                   bool
+	EmailSmtpListen                string
+	EmailImapHost                  string
+	EmailImapPort                  int `cloud-default:"993"`
+	EmailImapUser                  string
+	EmailImapPassword              string
+	EmailImapMailbox               string `cloud-default:"INBOX"`
+	EmailImapPlaintext             bool
+	EmailImapPollIntervalInSeconds int `cloud-default:"60"`
+}
+
+type EmailAdapter struct {
+	config  *EmailConfig
+	gubot   *robot.Gubot
+	from    mail.Address
+	smtpd   *smtpd
+	stopper *adapter.Stopper
+}
+
+func NewEmailAdapter() robot.Adapter {
+	return &EmailAdapter{
+		stopper: adapter.NewStopper(),
+	}
+}
+
+// Send send a new mail when channel name is an email address, otherwise it answers in thread of envelop.
+func (a *EmailAdapter) Send(envelop robot.Envelop, message string) error {
+	if strings.Contains(envelop.ChannelName, "@") {
+		return a.sendMail(outgoingMail{
+			to:      envelop.ChannelName,
+			subject: a.config.EmailSubject,
+			text:    message,
+		})
+	}
+	return a.Reply(envelop, message)
+}
+
+// Reply answer to user in thread of the mail which triggered the reply.
+func (a *EmailAdapter) Reply(envelop robot.Envelop, message string) error {
+	if envelop.User.Id == "" {
+		return errors.New("You must provide a user id (email address) in envelop")
+	}
+	subject := a.config.EmailSubject
+	if envelop.ChannelName != "" {
+		subject = "Re: " + envelop.ChannelName
+	}
+	references := make([]string, 0)
+	if rawReferences, ok := envelop.Properties["references"].(string); ok {
+		references = strings.Fields(rawReferences)
+	}
+	if envelop.MessageId != "" {
+		references = append(references, envelop.MessageId)
+	}
+	return a.sendMail(outgoingMail{
+		to:         envelop.User.Id,
+		subject:    subject,
+		inReplyTo:  envelop.MessageId,
+		references: references,
+		text:       message,
+	})
+}
+
+func (a *EmailAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	return a.Reply(envelop, message)
+}
+
+func (a *EmailAdapter) sendMail(m outgoingMail) error {
+	to, err := mail.ParseAddress(m.to)
+	if err != nil {
+		return fmt.Errorf("Invalid email address '%s': %s", m.to, err.Error())
+	}
+	m.from = a.from
+	m.to = to.String()
+	m.messageId = "<" + uuid.NewV4().String() + "@" + domain(a.from.Address) + ">"
+	data, err := m.bytes()
+	if err != nil {
+		return err
+	}
+	client, err := a.smtpClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if a.config.EmailSmtpUser != "" {
+		err = client.Auth(smtp.PlainAuth("", a.config.EmailSmtpUser, a.config.EmailSmtpPassword, a.config.EmailSmtpHost))
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Mail(a.from.Address)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(to.Address)
+	if err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// smtpClient connect to smtp server with implicit tls if EmailSmtpTls is set or with starttls when server supports it.
+func (a *EmailAdapter) smtpClient() (*smtp.Client, error) {
+	addr := net.JoinHostPort(a.config.EmailSmtpHost, strconv.Itoa(a.config.EmailSmtpPort))
+	if a.config.EmailSmtpTls {
+		conn, err := tls.Dial("tcp", addr, adapter.TlsConfig(a.gubot.HttpClient(), a.config.EmailSmtpHost))
+		if err != nil {
+			return nil, err
+		}
+		return smtp.NewClient(conn, a.config.EmailSmtpHost)
+	}
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(adapter.TlsConfig(a.gubot.HttpClient(), a.config.EmailSmtpHost))
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+func domain(address string) string {
+	return address[strings.LastIndex(address, "@")+1:]
+}
+
+func (a *EmailAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*EmailConfig)
+	a.gubot = gubot
+	if conf.EmailAddress == "" {
+		return errors.New("email_address config param is required")
+	}
+	if conf.EmailSmtpHost == "" {
+		return errors.New("email_smtp_host config param is required")
+	}
+	address, err := mail.ParseAddress(conf.EmailAddress)
+	if err != nil {
+		return fmt.Errorf("email_address config param is not valid: %s", err.Error())
+	}
+	if conf.EmailName == "" {
+		conf.EmailName = gubot.Name()
+	}
+	if conf.EmailSubject == "" {
+		conf.EmailSubject = "Message from " + conf.EmailName
+	}
+	if conf.EmailSmtpPort == 0 {
+		conf.EmailSmtpPort = 587
+	}
+	if conf.EmailImapPort == 0 {
+		conf.EmailImapPort = 993
+	}
+	if conf.EmailImapMailbox == "" {
+		conf.EmailImapMailbox = "INBOX"
+	}
+	if conf.EmailImapPollIntervalInSeconds <= 0 {
+		conf.EmailImapPollIntervalInSeconds = 60
+	}
+	if conf.EmailImapUser == "" {
+		conf.EmailImapUser = address.Address
+	}
+	a.config = conf
+	a.from = mail.Address{Name: conf.EmailName, Address: address.Address}
+
+	if conf.EmailSmtpListen != "" && len(conf.EmailAllowedSenders) == 0 {
+		// smtp listener doesn't authenticate senders, anyone able to reach it could talk to the bot
+		return errors.New("email_allowed_senders config param is required when email_smtp_listen is set")
+	}
+	if conf.EmailSmtpListen != "" {
+		listener, err := net.Listen("tcp", conf.EmailSmtpListen)
+		if err != nil {
+			return err
+		}
+		a.smtpd = &smtpd{
+			listener: listener,
+			hostname: domain(address.Address),
+			handler:  a.receive,
+		}
+		go a.smtpd.serve()
+		log.Infof("Email adapter listening for smtp on `%s`", conf.EmailSmtpListen)
+	}
+	if conf.EmailImapHost != "" && a.stopper.Begin() {
+		go a.pollLoop()
+	}
+	return nil
+}
+
+// Stop close smtp listener, stop polling and wait for mails being processed.
+func (a *EmailAdapter) Stop(ctx context.Context) error {
+	if a.smtpd != nil {
+		a.smtpd.close()
+	}
+	return a.stopper.Stop(ctx)
+}
+
+func (a *EmailAdapter) pollLoop() {
+	defer a.stopper.End()
+	interval := time.Duration(a.config.EmailImapPollIntervalInSeconds) * time.Second
+	for {
+		err := a.poll()
+		if err != nil {
+			log.Error("Error when polling imap mailbox: " + err.Error())
+		}
+		select {
+		case <-a.stopper.Stopped():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// poll retrieve unseen mails, they are marked as seen before being processed to never process a mail twice.
+func (a *EmailAdapter) poll() error {
+	addr := net.JoinHostPort(a.config.EmailImapHost, strconv.Itoa(a.config.EmailImapPort))
+	var tlsConfig *tls.Config
+	if !a.config.EmailImapPlaintext {
+		tlsConfig = adapter.TlsConfig(a.gubot.HttpClient(), a.config.EmailImapHost)
+	}
+	client, err := dialImap(addr, tlsConfig)
+	if err != nil {
+		return err
+	}
+	defer client.logout()
+	err = client.login(a.config.EmailImapUser, a.config.EmailImapPassword)
+	if err != nil {
+		return err
+	}
+	err = client.selectMailbox(a.config.EmailImapMailbox)
+	if err != nil {
+		return err
+	}
+	uids, err := client.searchUnseen()
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		if a.stopper.IsStopped() {
+			return nil
+		}
+		raw, err := client.fetch(uid)
+		if err != nil {
+			return err
+		}
+		err = client.markSeen(uid)
+		if err != nil {
+			return err
+		}
+		a.receive(raw)
+	}
+	return nil
+}
+
+func (a *EmailAdapter) isAllowedSender(address string) bool {
+	if len(a.config.EmailAllowedSenders) == 0 {
+		return true
+	}
+	address = strings.ToLower(address)
+	for _, allowed := range a.config.EmailAllowedSenders {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if strings.HasPrefix(allowed, "@") || !strings.Contains(allowed, "@") {
+			// a domain is given
+			if domain(address) == strings.TrimPrefix(allowed, "@") {
+				return true
+			}
+			continue
+		}
+		if address == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *EmailAdapter) receive(raw []byte) {
+	if !a.stopper.Begin() {
+		return
+	}
+	defer a.stopper.End()
+	m, err := parseMail(raw)
+	if err != nil {
+		log.Error("Error when parsing mail: " + err.Error())
+		return
+	}
+	// auto replies and our own mails are ignored to prevent loops
+	if m.autoSubmitted || strings.EqualFold(m.from.Address, a.from.Address) {
+		log.Debugf("Mail from '%s' ignored, it is an automatic mail", m.from.Address)
+		return
+	}
+	if !a.isAllowedSender(m.from.Address) {
+		log.Warnf("Mail from '%s' ignored, sender is not allowed", m.from.Address)
+		return
+	}
+	name := m.from.Name
+	if name == "" {
+		name = m.from.Address
+	}
+	subject := cleanSubject(m.subject)
+	message := m.text
+	if message == "" {
+		message = subject
+	}
+	envelop := robot.Envelop{
+		Message:     message,
+		ChannelId:   m.threadId,
+		ChannelName: subject,
+		MessageId:   m.messageId,
+		ThreadId:    m.threadId,
+		User: robot.UserEnvelop{
+			Id:          m.from.Address,
+			Name:        name,
+			ChannelId:   m.threadId,
+			ChannelName: subject,
+		},
+		Properties: map[string]interface{}{
+			"subject":    m.subject,
+			"to":         m.to,
+			"references": strings.Join(m.references, " "),
+		},
+	}
+	a.gubot.Receive(envelop)
+}
+
+func (a EmailAdapter) Name() string {
+	return "email"
+}
+
+func (a EmailAdapter) Config() interface{} {
+	return EmailConfig{}
+}
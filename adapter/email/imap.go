@@ -0,0 +1,188 @@
+package email
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const imapTimeout = 60 * time.Second
+
+// imapClient is a minimal imap client, it only knows commands needed to retrieve unseen mails.
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// imapResponse is an untagged response line with literals it contains.
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+func dialImap(addr string, tlsConfig *tls.Config) (*imapClient, error) {
+	dialer := &net.Dialer{Timeout: imapTimeout}
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &imapClient{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+	conn.SetDeadline(time.Now().Add(imapTimeout))
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("imap server refused connection: %s", greeting)
+	}
+	return c, nil
+}
+
+func (c *imapClient) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readResponse read a full response line, literals ({size} at end of line) are read and line continues after them.
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var resp imapResponse
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return resp, err
+		}
+		resp.line += line
+		size, ok := literalSize(line)
+		if !ok {
+			return resp, nil
+		}
+		literal := make([]byte, size)
+		_, err = io.ReadFull(c.reader, literal)
+		if err != nil {
+			return resp, err
+		}
+		resp.literals = append(resp.literals, literal)
+	}
+}
+
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndex(line, "{")
+	if i == -1 {
+		return 0, false
+	}
+	size, err := strconv.Atoi(line[i+1 : len(line)-1])
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+// cmd send command and give untagged responses, an error is returned when command doesn't end with OK.
+func (c *imapClient) cmd(command string) ([]imapResponse, error) {
+	c.tag++
+	tag := "g" + strconv.Itoa(c.tag)
+	c.conn.SetDeadline(time.Now().Add(imapTimeout))
+	_, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, command)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]imapResponse, 0)
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(resp.line, tag+" ") {
+			responses = append(responses, resp)
+			continue
+		}
+		status := strings.TrimPrefix(resp.line, tag+" ")
+		if !strings.HasPrefix(status, "OK") {
+			return responses, fmt.Errorf("imap command failed: %s", status)
+		}
+		return responses, nil
+	}
+}
+
+func quoteImap(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+func (c *imapClient) login(user, password string) error {
+	_, err := c.cmd("LOGIN " + quoteImap(user) + " " + quoteImap(password))
+	if err != nil {
+		return errors.New("imap login failed: " + err.Error())
+	}
+	return nil
+}
+
+func (c *imapClient) selectMailbox(mailbox string) error {
+	_, err := c.cmd("SELECT " + quoteImap(mailbox))
+	return err
+}
+
+// searchUnseen give uids of unseen mails.
+func (c *imapClient) searchUnseen() ([]string, error) {
+	responses, err := c.cmd("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]string, 0)
+	for _, resp := range responses {
+		if !strings.HasPrefix(resp.line, "* SEARCH") {
+			continue
+		}
+		uids = append(uids, strings.Fields(strings.TrimPrefix(resp.line, "* SEARCH"))...)
+	}
+	return uids, nil
+}
+
+// fetch give raw mail with uid without marking it as seen.
+func (c *imapClient) fetch(uid string) ([]byte, error) {
+	responses, err := c.cmd("UID FETCH " + uid + " (BODY.PEEK[])")
+	if err != nil {
+		return nil, err
+	}
+	for _, resp := range responses {
+		if strings.Contains(resp.line, "FETCH") && len(resp.literals) > 0 {
+			return resp.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap server didn't give mail with uid %s", uid)
+}
+
+func (c *imapClient) markSeen(uid string) error {
+	_, err := c.cmd("UID STORE " + uid + ` +FLAGS.SILENT (\Seen)`)
+	return err
+}
+
+func (c *imapClient) logout() error {
+	defer c.conn.Close()
+	_, err := c.cmd("LOGOUT")
+	return err
+}
@@ -0,0 +1,236 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	subjectPrefixRegex = regexp.MustCompile(`(?i)^\s*((re|fwd?|tr|aw)\s*:\s*)+`)
+	quoteHeaderRegex   = regexp.MustCompile(`(?i)^(on .*|le .*|am .*)(wrote|a écrit|schrieb)\s*:\s*$`)
+	tagRegex           = regexp.MustCompile(`<[^>]*>`)
+	wordDecoder        = &mime.WordDecoder{}
+)
+
+// incomingMail is a received mail reduced to what is needed by adapter.
+type incomingMail struct {
+	from          *mail.Address
+	to            string
+	subject       string
+	messageId     string
+	threadId      string
+	references    []string
+	text          string
+	autoSubmitted bool
+}
+
+func parseMail(raw []byte) (incomingMail, error) {
+	var m incomingMail
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return m, err
+	}
+	from, err := msg.Header.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return m, fmt.Errorf("mail has no valid sender")
+	}
+	m.from = from[0]
+	m.to = msg.Header.Get("To")
+	m.subject, err = wordDecoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		m.subject = msg.Header.Get("Subject")
+	}
+	m.messageId = strings.TrimSpace(msg.Header.Get("Message-Id"))
+	m.references = strings.Fields(msg.Header.Get("References"))
+	inReplyTo := strings.Fields(msg.Header.Get("In-Reply-To"))
+	if len(m.references) == 0 && len(inReplyTo) > 0 {
+		m.references = inReplyTo
+	}
+	// thread is identified by its first mail
+	m.threadId = m.messageId
+	if len(m.references) > 0 {
+		m.threadId = m.references[0]
+	}
+	autoSubmitted := strings.ToLower(msg.Header.Get("Auto-Submitted"))
+	precedence := strings.ToLower(msg.Header.Get("Precedence"))
+	m.autoSubmitted = (autoSubmitted != "" && autoSubmitted != "no") ||
+		precedence == "bulk" || precedence == "list" || precedence == "junk"
+
+	text, err := readText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return m, err
+	}
+	m.text = cleanText(text)
+	return m, nil
+}
+
+// readText give text of the mail, plain text part is preferred over html part in multipart mails.
+func readText(contentType, transferEncoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		htmlText := ""
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if part.Header.Get("Content-Type") == "" {
+				partType = "text/plain"
+			}
+			if strings.HasPrefix(partType, "multipart/") {
+				text, err := readText(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+				if err != nil {
+					return "", err
+				}
+				if text != "" {
+					return text, nil
+				}
+				continue
+			}
+			if partType != "text/plain" && partType != "text/html" {
+				continue
+			}
+			// quoted-printable parts are already decoded by multipart reader
+			text, err := decodeBody(part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", err
+			}
+			if partType == "text/plain" {
+				return text, nil
+			}
+			htmlText = stripHtml(text)
+		}
+		return htmlText, nil
+	}
+	text, err := decodeBody(transferEncoding, body)
+	if err != nil {
+		return "", err
+	}
+	if mediaType == "text/html" {
+		return stripHtml(text), nil
+	}
+	return text, nil
+}
+
+func decodeBody(transferEncoding string, body io.Reader) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &newlineStripper{body})
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// newlineStripper remove line breaks of base64 encoded body.
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (n *newlineStripper) Read(p []byte) (int, error) {
+	count, err := n.r.Read(p)
+	kept := 0
+	for _, b := range p[:count] {
+		if b != '\r' && b != '\n' {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
+
+func stripHtml(html string) string {
+	html = strings.Replace(html, "<br>", "\n", -1)
+	html = strings.Replace(html, "</p>", "\n", -1)
+	html = strings.Replace(html, "</div>", "\n", -1)
+	return tagRegex.ReplaceAllString(html, "")
+}
+
+// cleanText remove quoted previous mails and signature from text.
+func cleanText(text string) string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	lines := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, ">") || line == "-- " {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > 0 && quoteHeaderRegex.MatchString(strings.TrimSpace(lines[len(lines)-1])) {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// cleanSubject remove reply and forward prefixes from subject.
+func cleanSubject(subject string) string {
+	return strings.TrimSpace(subjectPrefixRegex.ReplaceAllString(subject, ""))
+}
+
+// outgoingMail is a mail sent by adapter.
+type outgoingMail struct {
+	from       mail.Address
+	to         string
+	subject    string
+	messageId  string
+	inReplyTo  string
+	references []string
+	text       string
+}
+
+func (m outgoingMail) bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	header := func(key, value string) {
+		fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+	}
+	header("From", m.from.String())
+	header("To", m.to)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-Id", m.messageId)
+	if m.inReplyTo != "" {
+		header("In-Reply-To", m.inReplyTo)
+	}
+	if len(m.references) > 0 {
+		header("References", strings.Join(m.references, " "))
+	}
+	// prevent auto responders to answer to the bot
+	header("Auto-Submitted", "auto-replied")
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+	writer := quotedprintable.NewWriter(buf)
+	text := strings.Replace(m.text, "\r\n", "\n", -1)
+	_, err := writer.Write([]byte(strings.Replace(text, "\n", "\r\n", -1)))
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,140 @@
+package email
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	smtpdTimeout   = 5 * time.Minute
+	smtpdMaxSize   = 10 * 1024 * 1024
+	smtpdMaxErrors = 10
+)
+
+// smtpd is a minimal smtp server receiving mails for the bot, it doesn't relay anything and has no authentication,
+// it should only be reachable by a trusted mail server.
+type smtpd struct {
+	listener net.Listener
+	hostname string
+	handler  func([]byte)
+}
+
+func (s *smtpd) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *smtpd) serveConn(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(code int, msg string) {
+		text.PrintfLine("%d %s", code, msg)
+	}
+	conn.SetDeadline(time.Now().Add(smtpdTimeout))
+	reply(220, s.hostname+" ESMTP gubot")
+	hasSender := false
+	hasRecipient := false
+	errCount := 0
+	for errCount < smtpdMaxErrors {
+		conn.SetDeadline(time.Now().Add(smtpdTimeout))
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(line)
+		if i := strings.Index(verb, " "); i != -1 {
+			verb = verb[:i]
+		}
+		switch verb {
+		case "HELO":
+			reply(250, s.hostname)
+		case "EHLO":
+			text.PrintfLine("250-%s", s.hostname)
+			text.PrintfLine("250-8BITMIME")
+			reply(250, "SIZE "+strconv.Itoa(smtpdMaxSize))
+		case "MAIL":
+			hasSender = true
+			hasRecipient = false
+			reply(250, "OK")
+		case "RCPT":
+			if !hasSender {
+				errCount++
+				reply(503, "MAIL first")
+				continue
+			}
+			hasRecipient = true
+			reply(250, "OK")
+		case "DATA":
+			if !hasRecipient {
+				errCount++
+				reply(503, "RCPT first")
+				continue
+			}
+			reply(354, "End data with <CR><LF>.<CR><LF>")
+			data, err := readData(text.R)
+			if err != nil {
+				reply(552, err.Error())
+				return
+			}
+			hasSender = false
+			hasRecipient = false
+			reply(250, "OK")
+			go s.handler(data)
+		case "RSET":
+			hasSender = false
+			hasRecipient = false
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			errCount++
+			reply(502, "Command not implemented")
+		}
+	}
+	log.Warn("Closing smtp connection from " + conn.RemoteAddr().String() + " after too many errors")
+}
+
+type smtpdError string
+
+func (e smtpdError) Error() string {
+	return string(e)
+}
+
+// readData read mail until the line with a single dot and remove dot stuffing.
+func readData(reader *bufio.Reader) ([]byte, error) {
+	data := make([]byte, 0)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return data, nil
+		}
+		if strings.HasPrefix(line, ".") {
+			line = line[1:]
+		}
+		if len(data)+len(line) > smtpdMaxSize {
+			return nil, smtpdError("Message exceeds maximum size")
+		}
+		data = append(data, line...)
+	}
+}
+
+func (s *smtpd) close() error {
+	return s.listener.Close()
+}
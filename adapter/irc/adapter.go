@@ -0,0 +1,514 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewIrcAdapter())
+}
+
+// messageSizeMax keep lines under the 512 bytes limit of irc with command and prefix added by server.
+const messageSizeMax = 400
+
+// queueSize is the number of lines waiting to be sent or messages waiting to be received,
+// lines and messages coming when it is full are dropped.
+const queueSize = 100
+
+const (
+	handshakeTimeout = 30 * time.Second
+	readTimeout      = 5 * time.Minute
+	reconnectDelay   = 10 * time.Second
+)
+
+type IrcConfig struct {
+	IrcServer           string
+	IrcTls              bool
+	IrcNick             string
+	IrcUser             string
+	IrcRealName         string
+	IrcPassword         string
+	IrcSaslUser         string
+	IrcSaslPassword     string
+	IrcNickservPassword string
+	IrcChannels         []string
+	IrcSendIntervalInMs int `cloud-default:"500"`
+	IrcSendBurst        int `cloud-default:"4"`
+	IrcSkipInsecure     bool
+}
+
+type IrcAdapter struct {
+	config   *IrcConfig
+	gubot    *robot.Gubot
+	conn     net.Conn
+	nick     string
+	mention  *mentionMatcher
+	mutex    *sync.Mutex
+	queue    chan string
+	received chan robot.Envelop
+	flush    chan struct{}
+	flushed  chan struct{}
+	stopper  *adapter.Stopper
+}
+
+func NewIrcAdapter() robot.Adapter {
+	return &IrcAdapter{
+		mutex:    new(sync.Mutex),
+		queue:    make(chan string, queueSize),
+		received: make(chan robot.Envelop, queueSize),
+		flush:    make(chan struct{}),
+		flushed:  make(chan struct{}),
+		stopper:  adapter.NewStopper(),
+	}
+}
+
+func (a *IrcAdapter) Send(envelop robot.Envelop, message string) error {
+	target := envelop.ChannelName
+	if target == "" {
+		target = envelop.ChannelId
+	}
+	if target == "" {
+		return errors.New("You must provide a channel name or channel id in envelop")
+	}
+	return a.privmsg(target, message)
+}
+
+func (a *IrcAdapter) Reply(envelop robot.Envelop, message string) error {
+	if !isChannel(envelop.ChannelName) {
+		return a.Send(envelop, message)
+	}
+	return a.Send(envelop, envelop.User.Name+": "+message)
+}
+
+func (a *IrcAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	if envelop.User.Name == "" {
+		return errors.New("You must provide a user name in envelop")
+	}
+	return a.privmsg(envelop.User.Name, message)
+}
+
+// privmsg queue message to target, each line is sent as a message.
+func (a *IrcAdapter) privmsg(target, message string) error {
+	for _, line := range strings.Split(message, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		for _, msg := range adapter.TruncateMessage(line, messageSizeMax) {
+			err := a.enqueue(fmt.Sprintf("PRIVMSG %s :%s", sanitize(target), sanitize(msg)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// enqueue never block, line is refused when queue is full or when queued lines were already flushed on stop.
+func (a *IrcAdapter) enqueue(line string) error {
+	select {
+	case <-a.flushed:
+		return errors.New("irc adapter is stopped")
+	default:
+	}
+	select {
+	case a.queue <- line:
+		return nil
+	default:
+		return errors.New("irc send queue is full, message dropped")
+	}
+}
+
+func (a *IrcAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*IrcConfig)
+	a.gubot = gubot
+	if conf.IrcServer == "" {
+		return errors.New("irc_server config param is required")
+	}
+	if conf.IrcNick == "" {
+		conf.IrcNick = gubot.Name()
+	}
+	if conf.IrcUser == "" {
+		conf.IrcUser = conf.IrcNick
+	}
+	if conf.IrcRealName == "" {
+		conf.IrcRealName = conf.IrcNick
+	}
+	if conf.IrcSendIntervalInMs <= 0 {
+		conf.IrcSendIntervalInMs = 500
+	}
+	if conf.IrcSendBurst <= 0 {
+		conf.IrcSendBurst = 4
+	}
+	a.config = conf
+	go a.receiveLoop()
+	go a.writeLoop()
+	reader, err := a.connect()
+	if err != nil {
+		return err
+	}
+	go a.readLoop(reader)
+	return nil
+}
+
+// Stop wait for messages received to be answered and send queued lines before quitting,
+// lines still queued when ctx is done are discarded.
+func (a *IrcAdapter) Stop(ctx context.Context) error {
+	if !a.stopper.Close() {
+		return nil
+	}
+	// adapter was never run
+	if a.config == nil {
+		return nil
+	}
+	err := a.stopper.Wait(ctx)
+	if err == nil {
+		close(a.received)
+	}
+	close(a.flush)
+	select {
+	case <-a.flushed:
+	case <-ctx.Done():
+		log.Warnf("IRC adapter stopped with %d queued lines discarded", len(a.queue))
+	}
+	a.writeRaw("QUIT :" + a.gubot.Name() + " is stopping")
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.conn != nil {
+		return a.conn.Close()
+	}
+	return nil
+}
+
+// connect open connection to server and register the bot, it returns when server welcomed the bot.
+func (a *IrcAdapter) connect() (*bufio.Reader, error) {
+	var conn net.Conn
+	var err error
+	if a.config.IrcTls {
+		tlsConfig := adapter.TlsConfig(a.gubot.HttpClient(), "")
+		if a.config.IrcSkipInsecure {
+			tlsConfig.InsecureSkipVerify = true
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: handshakeTimeout}, "tcp", a.config.IrcServer, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", a.config.IrcServer, handshakeTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.mutex.Lock()
+	a.conn = conn
+	a.mutex.Unlock()
+	a.setNick(a.config.IrcNick)
+
+	reader := bufio.NewReader(conn)
+	err = a.register(reader)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (a *IrcAdapter) register(reader *bufio.Reader) error {
+	useSasl := a.config.IrcSaslUser != ""
+	if useSasl {
+		a.writeRaw("CAP REQ :sasl")
+	}
+	if a.config.IrcPassword != "" {
+		a.writeRaw("PASS " + a.config.IrcPassword)
+	}
+	a.writeRaw("NICK " + a.config.IrcNick)
+	a.writeRaw(fmt.Sprintf("USER %s 0 * :%s", a.config.IrcUser, a.config.IrcRealName))
+	a.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("Error when registering on irc server: %s", err.Error())
+		}
+		msg := parseMessage(line)
+		switch msg.Command {
+		case "PING":
+			a.writeRaw("PONG :" + msg.Trailing())
+		case "CAP":
+			if msg.Param(1) == "ACK" && useSasl {
+				a.writeRaw("AUTHENTICATE PLAIN")
+			}
+			if msg.Param(1) == "NAK" && useSasl {
+				return errors.New("irc server doesn't support sasl authentication")
+			}
+		case "AUTHENTICATE":
+			if msg.Param(0) == "+" {
+				a.writeRaw("AUTHENTICATE " + saslPlain(a.config.IrcSaslUser, a.config.IrcSaslPassword))
+			}
+		case "903":
+			a.writeRaw("CAP END")
+		case "902", "904", "905", "906":
+			return errors.New("irc sasl authentication failed: " + msg.Trailing())
+		case "433":
+			nick := a.currentNick() + "_"
+			a.setNick(nick)
+			a.writeRaw("NICK " + nick)
+		case "ERROR":
+			return errors.New("irc server closed connection: " + msg.Trailing())
+		case "001":
+			a.setNick(msg.Param(0))
+			a.welcomed()
+			return nil
+		}
+	}
+}
+
+func saslPlain(user, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + "\x00" + user + "\x00" + password))
+}
+
+// welcomed identify bot with nickserv and join channels.
+func (a *IrcAdapter) welcomed() {
+	if a.config.IrcNickservPassword != "" {
+		a.writeRaw("PRIVMSG NickServ :IDENTIFY " + a.config.IrcNickservPassword)
+	}
+	for _, channel := range a.config.IrcChannels {
+		a.writeRaw("JOIN " + channel)
+	}
+	log.Infof("Connected on irc server %s as %s", a.config.IrcServer, a.currentNick())
+}
+
+func (a *IrcAdapter) currentNick() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.nick
+}
+
+// setNick change nick of bot and build again the regexes detecting its mentions.
+func (a *IrcAdapter) setNick(nick string) {
+	mention := newMentionMatcher(nick)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.nick = nick
+	a.mention = mention
+}
+
+func (a *IrcAdapter) mentionMatcher() *mentionMatcher {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.mention
+}
+
+// writeRaw send line immediately, it is used for protocol messages which must not wait in queue.
+func (a *IrcAdapter) writeRaw(line string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.conn == nil {
+		return errors.New("irc adapter is not connected")
+	}
+	_, err := a.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// writeLoop send queued messages, IrcSendBurst messages can be sent at once then one every IrcSendIntervalInMs
+// to not be kicked by flood protection of server. On stop, it sends lines left in queue before returning.
+func (a *IrcAdapter) writeLoop() {
+	defer close(a.flushed)
+	ticker := time.NewTicker(time.Duration(a.config.IrcSendIntervalInMs) * time.Millisecond)
+	defer ticker.Stop()
+	tokens := a.config.IrcSendBurst
+	write := func(line string) {
+		for tokens == 0 {
+			<-ticker.C
+			tokens++
+		}
+		tokens--
+		err := a.writeRaw(line)
+		if err != nil {
+			log.Error("Error when sending message on irc: " + err.Error())
+		}
+	}
+	for {
+		select {
+		case <-a.flush:
+			for {
+				select {
+				case line := <-a.queue:
+					write(line)
+				default:
+					return
+				}
+			}
+		case <-ticker.C:
+			if tokens < a.config.IrcSendBurst {
+				tokens++
+			}
+		case line := <-a.queue:
+			write(line)
+		}
+	}
+}
+
+// receiveLoop give messages to gubot one by one so that they are answered in the order they were received.
+func (a *IrcAdapter) receiveLoop() {
+	for envelop := range a.received {
+		a.gubot.Receive(envelop)
+		a.stopper.End()
+	}
+}
+
+// readLoop handle messages from server and reconnect when connection is lost.
+func (a *IrcAdapter) readLoop(reader *bufio.Reader) {
+	for {
+		a.mutex.Lock()
+		conn := a.conn
+		a.mutex.Unlock()
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		line, err := reader.ReadString('\n')
+		if err == nil {
+			a.handle(parseMessage(line))
+			continue
+		}
+		if a.stopper.IsStopped() {
+			return
+		}
+		log.Error("Error when reading from irc server, reconnecting: " + err.Error())
+		conn.Close()
+		reader = a.reconnect()
+		if reader == nil {
+			return
+		}
+	}
+}
+
+func (a *IrcAdapter) reconnect() *bufio.Reader {
+	for {
+		select {
+		case <-a.stopper.Stopped():
+			return nil
+		case <-time.After(reconnectDelay):
+		}
+		reader, err := a.connect()
+		if err == nil {
+			return reader
+		}
+		log.Error("Error when reconnecting to irc server: " + err.Error())
+	}
+}
+
+func (a *IrcAdapter) handle(msg message) {
+	nick := a.currentNick()
+	switch msg.Command {
+	case "PING":
+		a.writeRaw("PONG :" + msg.Trailing())
+	case "NICK":
+		if msg.Nick() == nick {
+			a.setNick(msg.Trailing())
+		}
+	case "JOIN":
+		if msg.Nick() == nick {
+			return
+		}
+		a.gubot.Emit(robot.GubotEvent{
+			Name:    robot.EVENT_ROBOT_CHANNEL_ENTER,
+			Envelop: a.toEnvelop(msg, msg.Param(0)),
+		})
+	case "PART":
+		if msg.Nick() == nick {
+			return
+		}
+		a.gubot.Emit(robot.GubotEvent{
+			Name:    robot.EVENT_ROBOT_CHANNEL_LEAVE,
+			Envelop: a.toEnvelop(msg, msg.Param(0)),
+		})
+	case "PRIVMSG":
+		a.receive(msg, a.mentionMatcher())
+	}
+}
+
+func (a *IrcAdapter) toEnvelop(msg message, channel string) robot.Envelop {
+	return robot.Envelop{
+		ChannelName: channel,
+		ChannelId:   channel,
+		User: robot.UserEnvelop{
+			Name:        msg.Nick(),
+			Id:          msg.Nick(),
+			ChannelName: channel,
+			ChannelId:   channel,
+			Properties: map[string]interface{}{
+				"prefix": msg.Prefix,
+			},
+		},
+		Properties: make(map[string]interface{}),
+	}
+}
+
+func (a *IrcAdapter) receive(msg message, mention *mentionMatcher) {
+	sender := msg.Nick()
+	text := msg.Trailing()
+	// we don't talk with services or ourself and ctcp requests are ignored
+	if sender == mention.nick || strings.EqualFold(sender, "NickServ") || strings.HasPrefix(text, "\x01") {
+		return
+	}
+	target := msg.Param(0)
+	channel := target
+	direct := !isChannel(target)
+	if direct {
+		channel = sender
+	}
+	envelop := a.toEnvelop(msg, channel)
+	mentioned, text := mention.strip(text)
+	envelop.Message = text
+	envelop.NotMentioned = !(mentioned || direct)
+	// scripts are run outside of read loop to not miss pings from server during a long script
+	if !a.stopper.Begin() {
+		return
+	}
+	select {
+	case a.received <- envelop:
+	default:
+		a.stopper.End()
+		log.Error("Too many irc messages waiting to be received, message dropped")
+	}
+}
+
+// mentionMatcher detect mentions of a nick, its regexes are built once each time nick changes.
+type mentionMatcher struct {
+	nick    string
+	address *regexp.Regexp
+	mention *regexp.Regexp
+}
+
+func newMentionMatcher(nick string) *mentionMatcher {
+	quoted := regexp.QuoteMeta(nick)
+	return &mentionMatcher{
+		nick:    nick,
+		address: regexp.MustCompile(`(?i)^@?` + quoted + `[:,]?\s+`),
+		mention: regexp.MustCompile(`(?i)(^|\W)@?` + quoted + `($|\W)`),
+	}
+}
+
+// strip detect if nick is mentioned and remove addressing like `nick: ` from text.
+func (m *mentionMatcher) strip(text string) (bool, string) {
+	if m.address.MatchString(text) {
+		return true, strings.TrimSpace(m.address.ReplaceAllString(text, ""))
+	}
+	return m.mention.MatchString(text), text
+}
+
+func (a IrcAdapter) Name() string {
+	return "irc"
+}
+
+func (a IrcAdapter) Config() interface{} {
+	return IrcConfig{}
+}
@@ -0,0 +1,192 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ArthurHlt/gubot/internal/testutil"
+	"github.com/ArthurHlt/gubot/robot"
+)
+
+// fakeServer is an irc server accepting one client, lines received are given on a channel.
+type fakeServer struct {
+	listener net.Listener
+	conn     net.Conn
+	lines    chan string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{
+		listener: listener,
+		lines:    make(chan string, 100),
+	}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		s.conn = conn
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(s.lines)
+				return
+			}
+			msg := parseMessage(line)
+			if msg.Command == "USER" {
+				conn.Write([]byte(":irc.example.com 001 gubot :Welcome\r\n"))
+			}
+			s.lines <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+	return s
+}
+
+// expect wait for a line starting with prefix and fail on timeout.
+func (s *fakeServer) expect(t *testing.T, prefix string) string {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				t.Fatalf("connection closed while waiting for '%s'", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		case <-timeout:
+			t.Fatalf("timeout while waiting for '%s'", prefix)
+		}
+	}
+}
+
+func (s *fakeServer) send(line string) {
+	s.conn.Write([]byte(line + "\r\n"))
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+	gubot, cleanup := testutil.NewGubot(t)
+	defer cleanup()
+	err := gubot.RegisterScript(robot.Script{
+		Name:             "ping",
+		Matcher:          "^ping$",
+		Type:             robot.Tsend,
+		TriggerOnMention: true,
+		Function: func(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
+			return []string{"pong\nsecond line"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adp := NewIrcAdapter().(*IrcAdapter)
+	gubot.RegisterAdapter(adp)
+	err = adp.Run(&IrcConfig{
+		IrcServer:   server.listener.Addr().String(),
+		IrcNick:     "gubot",
+		IrcChannels: []string{"#gubot"},
+	}, gubot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.expect(t, "JOIN #gubot")
+
+	server.send(":bob!bob@host PRIVMSG #gubot :ping")
+	server.send(":bob!bob@host PRIVMSG #gubot :gubot: ping")
+	if line := server.expect(t, "PRIVMSG"); line != "PRIVMSG #gubot :pong" {
+		t.Errorf("expected pong on #gubot, got '%s'", line)
+	}
+	if line := server.expect(t, "PRIVMSG"); line != "PRIVMSG #gubot :second line" {
+		t.Errorf("expected each line sent as a message, got '%s'", line)
+	}
+
+	server.send("PING :irc.example.com")
+	server.expect(t, "PONG :irc.example.com")
+
+	err = adp.Stop(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.expect(t, "QUIT")
+}
+
+func TestMessagesAnsweredInOrder(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.listener.Close()
+	gubot, cleanup := testutil.NewGubot(t)
+	defer cleanup()
+	err := gubot.RegisterScript(robot.Script{
+		Name:    "echo",
+		Matcher: "^echo (.+)$",
+		Type:    robot.Tsend,
+		Function: func(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
+			if subMatch[0][1] == "slow" {
+				time.Sleep(50 * time.Millisecond)
+			}
+			return []string{subMatch[0][1]}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adp := NewIrcAdapter().(*IrcAdapter)
+	gubot.RegisterAdapter(adp)
+	err = adp.Run(&IrcConfig{
+		IrcServer:           server.listener.Addr().String(),
+		IrcNick:             "gubot",
+		IrcChannels:         []string{"#gubot"},
+		IrcSendIntervalInMs: 10,
+		IrcSendBurst:        1,
+	}, gubot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.expect(t, "JOIN #gubot")
+
+	server.send(":bob!bob@host PRIVMSG gubot :echo slow")
+	server.send(":bob!bob@host PRIVMSG gubot :echo fast")
+	if line := server.expect(t, "PRIVMSG"); line != "PRIVMSG bob :slow" {
+		t.Errorf("expected first message to be answered first, got '%s'", line)
+	}
+	server.expect(t, "PRIVMSG bob :fast")
+
+	// lines queued before stop are sent before quitting
+	adp.Send(robot.Envelop{ChannelName: "#gubot"}, "one\ntwo\nthree")
+	err = adp.Stop(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"PRIVMSG #gubot :one", "PRIVMSG #gubot :two", "PRIVMSG #gubot :three", "QUIT"} {
+		if line := server.expect(t, ""); !strings.HasPrefix(line, expected) {
+			t.Errorf("expected '%s', got '%s'", expected, line)
+		}
+	}
+	if err := adp.Send(robot.Envelop{ChannelName: "#gubot"}, "too late"); err == nil {
+		t.Error("expected message to be refused after stop")
+	}
+}
+
+func TestEnqueueRefuseWhenQueueIsFull(t *testing.T) {
+	adp := NewIrcAdapter().(*IrcAdapter)
+	for i := 0; i < queueSize; i++ {
+		err := adp.enqueue("PRIVMSG #gubot :hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := adp.enqueue("PRIVMSG #gubot :hello")
+	if err == nil {
+		t.Error("expected line to be refused when queue is full")
+	}
+}
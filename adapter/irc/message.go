@@ -0,0 +1,86 @@
+package irc
+
+import (
+	"strings"
+)
+
+// message is a line of the irc protocol: [:prefix] command params... [:trailing]
+type message struct {
+	Prefix  string
+	Command string
+	Params  []string
+}
+
+func parseMessage(line string) message {
+	line = strings.TrimRight(line, "\r\n")
+	msg := message{}
+	if strings.HasPrefix(line, "@") {
+		// message tags are not used
+		index := strings.Index(line, " ")
+		if index == -1 {
+			return msg
+		}
+		line = strings.TrimLeft(line[index+1:], " ")
+	}
+	if strings.HasPrefix(line, ":") {
+		index := strings.Index(line, " ")
+		if index == -1 {
+			msg.Prefix = line[1:]
+			return msg
+		}
+		msg.Prefix = line[1:index]
+		line = strings.TrimLeft(line[index+1:], " ")
+	}
+	trailing := ""
+	hasTrailing := false
+	if index := strings.Index(line, " :"); index != -1 {
+		trailing = line[index+2:]
+		hasTrailing = true
+		line = line[:index]
+	} else if strings.HasPrefix(line, ":") {
+		trailing = line[1:]
+		hasTrailing = true
+		line = ""
+	}
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		msg.Command = strings.ToUpper(fields[0])
+		msg.Params = fields[1:]
+	}
+	if hasTrailing {
+		msg.Params = append(msg.Params, trailing)
+	}
+	return msg
+}
+
+// Nick give nick of sender from prefix nick!user@host.
+func (m message) Nick() string {
+	if index := strings.Index(m.Prefix, "!"); index != -1 {
+		return m.Prefix[:index]
+	}
+	return m.Prefix
+}
+
+func (m message) Param(i int) string {
+	if i >= len(m.Params) {
+		return ""
+	}
+	return m.Params[i]
+}
+
+// Trailing give the last param of message.
+func (m message) Trailing() string {
+	if len(m.Params) == 0 {
+		return ""
+	}
+	return m.Params[len(m.Params)-1]
+}
+
+func isChannel(target string) bool {
+	return strings.HasPrefix(target, "#") || strings.HasPrefix(target, "&")
+}
+
+// sanitize remove line breaks which would let a message inject irc commands.
+func sanitize(text string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(text)
+}
@@ -0,0 +1,92 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected message
+	}{
+		{
+			"PING :irc.example.com\r\n",
+			message{Command: "PING", Params: []string{"irc.example.com"}},
+		},
+		{
+			":bob!bob@host PRIVMSG #gubot :gubot: hello world\r\n",
+			message{Prefix: "bob!bob@host", Command: "PRIVMSG", Params: []string{"#gubot", "gubot: hello world"}},
+		},
+		{
+			":irc.example.com 001 gubot :Welcome to the network",
+			message{Prefix: "irc.example.com", Command: "001", Params: []string{"gubot", "Welcome to the network"}},
+		},
+		{
+			"@time=2019-01-01T00:00:00Z :bob!bob@host JOIN #gubot",
+			message{Prefix: "bob!bob@host", Command: "JOIN", Params: []string{"#gubot"}},
+		},
+		{
+			":bob!bob@host privmsg gubot :message with : colon",
+			message{Prefix: "bob!bob@host", Command: "PRIVMSG", Params: []string{"gubot", "message with : colon"}},
+		},
+		{
+			":bob!bob@host PRIVMSG #gubot :",
+			message{Prefix: "bob!bob@host", Command: "PRIVMSG", Params: []string{"#gubot", ""}},
+		},
+		{
+			":server",
+			message{Prefix: "server"},
+		},
+	}
+	for _, test := range tests {
+		msg := parseMessage(test.line)
+		if msg.Prefix != test.expected.Prefix || msg.Command != test.expected.Command ||
+			!reflect.DeepEqual(msg.Params, test.expected.Params) {
+			t.Errorf("parsing '%s': expected %#v, got %#v", test.line, test.expected, msg)
+		}
+	}
+}
+
+func TestMessageAccessors(t *testing.T) {
+	msg := parseMessage(":bob!bob@host PRIVMSG #gubot :hello")
+	if msg.Nick() != "bob" {
+		t.Errorf("expected nick bob, got '%s'", msg.Nick())
+	}
+	if msg.Param(0) != "#gubot" || msg.Param(5) != "" {
+		t.Errorf("unexpected params %v", msg.Params)
+	}
+	if msg.Trailing() != "hello" {
+		t.Errorf("expected trailing hello, got '%s'", msg.Trailing())
+	}
+	if parseMessage(":irc.example.com NOTICE * :hi").Nick() != "irc.example.com" {
+		t.Error("expected prefix without user to be used as nick")
+	}
+}
+
+func TestMentionMatcher(t *testing.T) {
+	mention := newMentionMatcher("gu.bot")
+	tests := []struct {
+		text      string
+		mentioned bool
+		stripped  string
+	}{
+		{"gu.bot: hello", true, "hello"},
+		{"@Gu.Bot, hello", true, "hello"},
+		{"hello gu.bot!", true, "hello gu.bot!"},
+		{"hello guxbot", false, "hello guxbot"},
+		{"hello gu.botanist", false, "hello gu.botanist"},
+	}
+	for _, test := range tests {
+		mentioned, stripped := mention.strip(test.text)
+		if mentioned != test.mentioned || stripped != test.stripped {
+			t.Errorf("'%s': expected (%t, '%s'), got (%t, '%s')", test.text, test.mentioned, test.stripped, mentioned, stripped)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	if sanitize("hello\r\nQUIT :bye") != "hello  QUIT :bye" {
+		t.Error("expected line breaks to be removed")
+	}
+}
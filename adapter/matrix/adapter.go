@@ -0,0 +1,477 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewMatrixAdapter())
+}
+
+const messageSizeMax = 4000
+
+const (
+	sinceKeyPrefix = "matrix_since_"
+	retryDelay     = 5 * time.Second
+	threadRelType  = "m.thread"
+)
+
+type MatrixConfig struct {
+	MatrixHomeserver           string
+	MatrixUser                 string
+	MatrixPassword             string
+	MatrixAccessToken          string
+	MatrixRooms                []string
+	MatrixAutoJoin             bool
+	MatrixSyncTimeoutInSeconds int `cloud-default:"30"`
+}
+
+type MatrixAdapter struct {
+	client       *client
+	config       *MatrixConfig
+	gubot        *robot.Gubot
+	mutex        *sync.Mutex
+	allowedRooms map[string]bool
+	roomNames    map[string]string
+	memberCounts map[string]int
+	direct       map[string][]string
+	txn          int64
+	cancel       context.CancelFunc
+	stopper      *adapter.Stopper
+}
+
+func NewMatrixAdapter() robot.Adapter {
+	return &MatrixAdapter{
+		mutex:        new(sync.Mutex),
+		allowedRooms: make(map[string]bool),
+		roomNames:    make(map[string]string),
+		memberCounts: make(map[string]int),
+		direct:       make(map[string][]string),
+		stopper:      adapter.NewStopper(),
+	}
+}
+
+func (a *MatrixAdapter) Send(envelop robot.Envelop, message string) error {
+	roomId, err := a.envelopRoomId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.sendText(roomId, "", message)
+}
+
+func (a *MatrixAdapter) Reply(envelop robot.Envelop, message string) error {
+	roomId, err := a.envelopRoomId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.sendText(roomId, envelop.ThreadId, envelop.User.Name+": "+message)
+}
+
+// SendDirect send message in the direct room with user, room is created if it doesn't exist.
+func (a *MatrixAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	if envelop.User.Id == "" {
+		return errors.New("You must provide a user id in envelop")
+	}
+	roomId, err := a.directRoom(envelop.User.Id)
+	if err != nil {
+		return err
+	}
+	return a.sendText(roomId, "", message)
+}
+
+// sendText send message in room, it is sent in thread of event threadId if not empty.
+func (a *MatrixAdapter) sendText(roomId, threadId, message string) error {
+	for _, msg := range adapter.TruncateMessage(message, messageSizeMax) {
+		content := messageContent{
+			MsgType: "m.text",
+			Body:    msg,
+		}
+		if threadId != "" {
+			content.RelatesTo = &relatesTo{
+				RelType: threadRelType,
+				EventId: threadId,
+			}
+		}
+		err := a.client.sendMessage(roomId, a.nextTxnId(), content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *MatrixAdapter) nextTxnId() string {
+	return fmt.Sprintf("gubot.%d.%d", time.Now().UnixNano(), atomic.AddInt64(&a.txn, 1))
+}
+
+// envelopRoomId give room id from channel id, or from channel name which can be an alias or a known room name.
+func (a *MatrixAdapter) envelopRoomId(envelop robot.Envelop) (string, error) {
+	if envelop.ChannelId != "" {
+		return envelop.ChannelId, nil
+	}
+	name := envelop.ChannelName
+	if name == "" {
+		return "", errors.New("You must provide a channel name or channel id in envelop")
+	}
+	if strings.HasPrefix(name, "!") {
+		return name, nil
+	}
+	if strings.HasPrefix(name, "#") {
+		return a.client.resolveAlias(name)
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for roomId, roomName := range a.roomNames {
+		if roomName == name {
+			return roomId, nil
+		}
+	}
+	return "", fmt.Errorf("Room '%s' not found", name)
+}
+
+func (a *MatrixAdapter) directRoom(userId string) (string, error) {
+	a.mutex.Lock()
+	rooms := a.direct[userId]
+	a.mutex.Unlock()
+	if len(rooms) > 0 {
+		return rooms[0], nil
+	}
+	roomId, err := a.client.createDirectRoom(userId)
+	if err != nil {
+		return "", err
+	}
+	a.mutex.Lock()
+	a.direct[userId] = append(a.direct[userId], roomId)
+	direct := make(map[string][]string)
+	for user, userRooms := range a.direct {
+		direct[user] = userRooms
+	}
+	a.mutex.Unlock()
+	err = a.client.setDirectRooms(direct)
+	if err != nil {
+		log.Error("Error when saving matrix direct rooms: " + err.Error())
+	}
+	return roomId, nil
+}
+
+func (a *MatrixAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*MatrixConfig)
+	a.gubot = gubot
+	if conf.MatrixHomeserver == "" {
+		return errors.New("matrix_homeserver config param is required")
+	}
+	if conf.MatrixAccessToken == "" && (conf.MatrixUser == "" || conf.MatrixPassword == "") {
+		return errors.New("matrix_access_token or matrix_user and matrix_password config params are required")
+	}
+	if conf.MatrixSyncTimeoutInSeconds <= 0 {
+		conf.MatrixSyncTimeoutInSeconds = 30
+	}
+	a.config = conf
+	a.client = newClient(conf.MatrixHomeserver, gubot.HttpClient())
+	var err error
+	if conf.MatrixAccessToken != "" {
+		a.client.accessToken = conf.MatrixAccessToken
+		err = a.client.whoami()
+	} else {
+		err = a.client.login(conf.MatrixUser, conf.MatrixPassword)
+	}
+	if err != nil {
+		return err
+	}
+	for _, room := range conf.MatrixRooms {
+		roomId := room
+		if strings.HasPrefix(room, "#") {
+			roomId, err = a.client.resolveAlias(room)
+			if err != nil {
+				return fmt.Errorf("Error when resolving matrix room '%s': %s", room, err.Error())
+			}
+		}
+		a.allowedRooms[roomId] = true
+	}
+	since, err := a.loadSince()
+	if err != nil {
+		return err
+	}
+	if since == "" {
+		// first start, old messages are not answered
+		resp, err := a.client.sync(context.Background(), "", 0)
+		if err != nil {
+			return err
+		}
+		a.handleSync(resp, false)
+		since = resp.NextBatch
+		a.saveSince(since)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	if a.stopper.Begin() {
+		go a.syncLoop(ctx, since)
+	}
+	return nil
+}
+
+func (a *MatrixAdapter) sinceKey() string {
+	return sinceKeyPrefix + a.client.userId
+}
+
+// loadSince give the since token of last sync kept in brain.
+func (a *MatrixAdapter) loadSince() (string, error) {
+	if a.gubot.Brain() == nil {
+		return "", nil
+	}
+	since, _, err := a.gubot.Brain().Get(robot.GlobalScope(), a.sinceKey())
+	return since, err
+}
+
+func (a *MatrixAdapter) saveSince(since string) {
+	if a.gubot.Brain() == nil {
+		return
+	}
+	err := a.gubot.Brain().Set(robot.GlobalScope(), a.sinceKey(), since, 0)
+	if err != nil {
+		log.Error("Error when saving matrix since token: " + err.Error())
+	}
+}
+
+func (a *MatrixAdapter) Stop(ctx context.Context) error {
+	if !a.stopper.Close() {
+		return nil
+	}
+	if a.cancel != nil {
+		a.cancel()
+	}
+	return a.stopper.Wait(ctx)
+}
+
+func (a *MatrixAdapter) syncLoop(ctx context.Context, since string) {
+	defer a.stopper.End()
+	for {
+		resp, err := a.client.sync(ctx, since, a.config.MatrixSyncTimeoutInSeconds*1000)
+		if a.stopper.IsStopped() {
+			return
+		}
+		if err != nil {
+			log.Error("Error when syncing with matrix: " + err.Error())
+			select {
+			case <-a.stopper.Stopped():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+		a.handleSync(resp, true)
+		since = resp.NextBatch
+		a.saveSince(since)
+	}
+}
+
+// handleSync update known rooms and process new events of timelines when withTimeline is true.
+func (a *MatrixAdapter) handleSync(resp syncResponse, withTimeline bool) {
+	for _, evt := range resp.AccountData.Events {
+		if evt.Type != "m.direct" {
+			continue
+		}
+		direct := make(map[string][]string)
+		if err := json.Unmarshal(evt.Content, &direct); err == nil {
+			a.mutex.Lock()
+			a.direct = direct
+			a.mutex.Unlock()
+		}
+	}
+	for roomId, room := range resp.Rooms.Invite {
+		if !a.config.MatrixAutoJoin {
+			continue
+		}
+		_, err := a.client.joinRoom(roomId)
+		if err != nil {
+			log.Errorf("Error when joining matrix room '%s': %s", roomId, err.Error())
+			continue
+		}
+		a.handleInvite(roomId, room.InviteState.Events)
+	}
+	for roomId, room := range resp.Rooms.Join {
+		a.mutex.Lock()
+		if room.Summary.JoinedMemberCount > 0 {
+			a.memberCounts[roomId] = room.Summary.JoinedMemberCount
+		}
+		a.mutex.Unlock()
+		for _, evt := range room.State.Events {
+			a.handleState(roomId, evt)
+		}
+		for _, evt := range room.Timeline.Events {
+			a.handleState(roomId, evt)
+			if !withTimeline {
+				continue
+			}
+			switch evt.Type {
+			case "m.room.message":
+				a.receive(roomId, evt)
+			case "m.room.member":
+				a.membership(roomId, evt)
+			}
+		}
+	}
+}
+
+// handleInvite keep room as direct room with inviter when invitation is flagged as direct.
+func (a *MatrixAdapter) handleInvite(roomId string, events []event) {
+	for _, evt := range events {
+		if evt.Type != "m.room.member" || evt.StateKey == nil || *evt.StateKey != a.client.userId {
+			continue
+		}
+		var content memberContent
+		if err := json.Unmarshal(evt.Content, &content); err != nil || !content.IsDirect {
+			continue
+		}
+		a.mutex.Lock()
+		a.direct[evt.Sender] = append(a.direct[evt.Sender], roomId)
+		a.mutex.Unlock()
+	}
+}
+
+func (a *MatrixAdapter) handleState(roomId string, evt event) {
+	if evt.Type != "m.room.name" {
+		return
+	}
+	var content struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(evt.Content, &content); err == nil {
+		a.mutex.Lock()
+		a.roomNames[roomId] = content.Name
+		a.mutex.Unlock()
+	}
+}
+
+func (a *MatrixAdapter) isDirect(roomId string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, rooms := range a.direct {
+		for _, room := range rooms {
+			if room == roomId {
+				return true
+			}
+		}
+	}
+	return a.memberCounts[roomId] == 2
+}
+
+func (a *MatrixAdapter) isAllowed(roomId string) bool {
+	return len(a.allowedRooms) == 0 || a.allowedRooms[roomId] || a.isDirect(roomId)
+}
+
+// membership emit channel enter and leave events when a user join or leave a room.
+func (a *MatrixAdapter) membership(roomId string, evt event) {
+	if evt.StateKey == nil || *evt.StateKey == a.client.userId || !a.isAllowed(roomId) {
+		return
+	}
+	var content, prevContent memberContent
+	json.Unmarshal(evt.Content, &content)
+	json.Unmarshal(evt.Unsigned.PrevContent, &prevContent)
+	var eventAction robot.EventAction
+	switch {
+	case content.Membership == "join" && prevContent.Membership != "join":
+		eventAction = robot.EVENT_ROBOT_CHANNEL_ENTER
+	case (content.Membership == "leave" || content.Membership == "ban") && prevContent.Membership == "join":
+		eventAction = robot.EVENT_ROBOT_CHANNEL_LEAVE
+	default:
+		return
+	}
+	a.gubot.Emit(robot.GubotEvent{
+		Name:    eventAction,
+		Envelop: a.toEnvelop(roomId, *evt.StateKey),
+	})
+}
+
+func (a *MatrixAdapter) receive(roomId string, evt event) {
+	if !a.isAllowed(roomId) {
+		return
+	}
+	var content messageContent
+	err := json.Unmarshal(evt.Content, &content)
+	if err != nil {
+		return
+	}
+	// notices are sent by bots
+	if adapter.IsFromBot(evt.Sender, a.client.userId, content.MsgType == "m.notice") || content.MsgType != "m.text" {
+		return
+	}
+	envelop := a.toEnvelop(roomId, evt.Sender)
+	mentioned, text := a.stripMention(content)
+	envelop.Message = text
+	envelop.MessageId = evt.EventId
+	if content.RelatesTo != nil && content.RelatesTo.RelType == threadRelType {
+		envelop.ThreadId = content.RelatesTo.EventId
+	}
+	envelop.NotMentioned = !(mentioned || a.isDirect(roomId))
+	a.gubot.Receive(envelop)
+}
+
+// stripMention detect if bot is mentioned by user id or name and remove addressing like `gubot: ` from body.
+func (a *MatrixAdapter) stripMention(content messageContent) (bool, string) {
+	text := content.Body
+	userId := a.client.userId
+	name := localpart(userId)
+	mentioned := strings.Contains(content.FormattedBody, "matrix.to/#/"+userId)
+	if strings.Contains(text, userId) {
+		mentioned = true
+		text = strings.TrimSpace(strings.Replace(text, userId, "", -1))
+		text = strings.TrimSpace(strings.TrimLeft(text, ":,"))
+	}
+	address := regexp.MustCompile(`(?i)^@?` + regexp.QuoteMeta(name) + `[:,]?\s+`)
+	if address.MatchString(text) {
+		return true, strings.TrimSpace(address.ReplaceAllString(text, ""))
+	}
+	if !mentioned {
+		mentioned = regexp.MustCompile(`(?i)(^|\W)@?` + regexp.QuoteMeta(name) + `($|\W)`).MatchString(text)
+	}
+	return mentioned, text
+}
+
+func localpart(userId string) string {
+	name := strings.TrimPrefix(userId, "@")
+	if index := strings.Index(name, ":"); index != -1 {
+		return name[:index]
+	}
+	return name
+}
+
+func (a *MatrixAdapter) toEnvelop(roomId, userId string) robot.Envelop {
+	a.mutex.Lock()
+	roomName := a.roomNames[roomId]
+	a.mutex.Unlock()
+	if roomName == "" {
+		roomName = roomId
+	}
+	return robot.Envelop{
+		ChannelId:   roomId,
+		ChannelName: roomName,
+		User: robot.UserEnvelop{
+			Id:          userId,
+			Name:        localpart(userId),
+			ChannelId:   roomId,
+			ChannelName: roomName,
+		},
+		Properties: make(map[string]interface{}),
+	}
+}
+
+func (a MatrixAdapter) Name() string {
+	return "matrix"
+}
+
+func (a MatrixAdapter) Config() interface{} {
+	return MatrixConfig{}
+}
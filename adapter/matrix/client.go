@@ -0,0 +1,224 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const apiPrefix = "/_matrix/client/v3"
+
+// client call the matrix client-server api.
+type client struct {
+	homeserver  string
+	accessToken string
+	userId      string
+	httpClient  *http.Client
+}
+
+type apiError struct {
+	Errcode string `json:"errcode"`
+	Err     string `json:"error"`
+	status  int
+}
+
+func (e apiError) Error() string {
+	if e.Errcode == "" {
+		return fmt.Sprintf("matrix api returned status %d", e.status)
+	}
+	return fmt.Sprintf("matrix api error %s: %s", e.Errcode, e.Err)
+}
+
+type syncResponse struct {
+	NextBatch   string `json:"next_batch"`
+	AccountData struct {
+		Events []event `json:"events"`
+	} `json:"account_data"`
+	Rooms struct {
+		Join map[string]struct {
+			Summary struct {
+				JoinedMemberCount int `json:"m.joined_member_count"`
+			} `json:"summary"`
+			State struct {
+				Events []event `json:"events"`
+			} `json:"state"`
+			Timeline struct {
+				Events []event `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+		Invite map[string]struct {
+			InviteState struct {
+				Events []event `json:"events"`
+			} `json:"invite_state"`
+		} `json:"invite"`
+	} `json:"rooms"`
+}
+
+type event struct {
+	Type     string          `json:"type"`
+	EventId  string          `json:"event_id"`
+	Sender   string          `json:"sender"`
+	StateKey *string         `json:"state_key"`
+	Content  json.RawMessage `json:"content"`
+	Unsigned struct {
+		PrevContent json.RawMessage `json:"prev_content"`
+	} `json:"unsigned"`
+}
+
+type messageContent struct {
+	MsgType       string     `json:"msgtype"`
+	Body          string     `json:"body"`
+	Format        string     `json:"format,omitempty"`
+	FormattedBody string     `json:"formatted_body,omitempty"`
+	RelatesTo     *relatesTo `json:"m.relates_to,omitempty"`
+}
+
+type relatesTo struct {
+	RelType string `json:"rel_type"`
+	EventId string `json:"event_id"`
+}
+
+type memberContent struct {
+	Membership string `json:"membership"`
+	IsDirect   bool   `json:"is_direct"`
+}
+
+func newClient(homeserver string, httpClient *http.Client) *client {
+	return &client{
+		homeserver: strings.TrimSuffix(homeserver, "/"),
+		httpClient: httpClient,
+	}
+}
+
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	u := c.homeserver + apiPrefix + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := apiError{status: resp.StatusCode}
+		json.Unmarshal(b, &apiErr)
+		return apiErr
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(b, result)
+}
+
+func (c *client) login(user, password string) error {
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		UserId      string `json:"user_id"`
+	}
+	err := c.do(context.Background(), "POST", "/login", nil, map[string]interface{}{
+		"type": "m.login.password",
+		"identifier": map[string]string{
+			"type": "m.id.user",
+			"user": user,
+		},
+		"password":                    password,
+		"initial_device_display_name": "gubot",
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	c.accessToken = resp.AccessToken
+	c.userId = resp.UserId
+	return nil
+}
+
+func (c *client) whoami() error {
+	var resp struct {
+		UserId string `json:"user_id"`
+	}
+	err := c.do(context.Background(), "GET", "/account/whoami", nil, nil, &resp)
+	if err != nil {
+		return err
+	}
+	c.userId = resp.UserId
+	return nil
+}
+
+func (c *client) sync(ctx context.Context, since string, timeoutMs int) (syncResponse, error) {
+	query := url.Values{}
+	query.Set("timeout", fmt.Sprint(timeoutMs))
+	if since != "" {
+		query.Set("since", since)
+	}
+	var resp syncResponse
+	err := c.do(ctx, "GET", "/sync", query, nil, &resp)
+	return resp, err
+}
+
+func (c *client) sendMessage(roomId, txnId string, content messageContent) error {
+	path := fmt.Sprintf("/rooms/%s/send/m.room.message/%s", url.PathEscape(roomId), url.PathEscape(txnId))
+	return c.do(context.Background(), "PUT", path, nil, content, nil)
+}
+
+func (c *client) joinRoom(roomIdOrAlias string) (string, error) {
+	var resp struct {
+		RoomId string `json:"room_id"`
+	}
+	err := c.do(context.Background(), "POST", "/join/"+url.PathEscape(roomIdOrAlias), nil, map[string]string{}, &resp)
+	return resp.RoomId, err
+}
+
+func (c *client) resolveAlias(alias string) (string, error) {
+	var resp struct {
+		RoomId string `json:"room_id"`
+	}
+	err := c.do(context.Background(), "GET", "/directory/room/"+url.PathEscape(alias), nil, nil, &resp)
+	return resp.RoomId, err
+}
+
+func (c *client) createDirectRoom(userId string) (string, error) {
+	var resp struct {
+		RoomId string `json:"room_id"`
+	}
+	err := c.do(context.Background(), "POST", "/createRoom", nil, map[string]interface{}{
+		"is_direct": true,
+		"invite":    []string{userId},
+		"preset":    "trusted_private_chat",
+	}, &resp)
+	return resp.RoomId, err
+}
+
+func (c *client) setDirectRooms(direct map[string][]string) error {
+	path := fmt.Sprintf("/user/%s/account_data/m.direct", url.PathEscape(c.userId))
+	return c.do(context.Background(), "PUT", path, nil, direct, nil)
+}
@@ -0,0 +1,137 @@
+package mattermost_user
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ArthurHlt/gubot/robot"
+	"github.com/mattermost/mattermost-server/model"
+)
+
+const actionIdContextKey = "action_id"
+
+func (a MattermostUserAdapter) actionIntegration(actionId string) *model.PostActionIntegration {
+	return &model.PostActionIntegration{
+		URL: a.gubot.ActionUrl(a.Name()),
+		Context: map[string]interface{}{
+			actionIdContextKey: actionId,
+		},
+	}
+}
+
+// loadSigningKey give the public key used by mattermost to sign trigger ids of action requests.
+func (a MattermostUserAdapter) loadSigningKey() (*ecdsa.PublicKey, error) {
+	clientConfig, resp := a.client.GetOldClientConfig("")
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	encodedKey := clientConfig["AsymmetricSigningPublicKey"]
+	if encodedKey == "" {
+		return nil, errors.New("server doesn't give its public signing key")
+	}
+	der, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("server public signing key is not an ecdsa key")
+	}
+	return ecdsaKey, nil
+}
+
+// verifyTrigger check that trigger id has been signed by mattermost for the user who made the action,
+// user id in request can't be trusted without it.
+func (a MattermostUserAdapter) verifyTrigger(actionReq model.PostActionIntegrationRequest) error {
+	if a.signingKey == nil {
+		return errors.New("Mattermost signing key is not loaded, actions can't be verified")
+	}
+	if actionReq.TriggerId == "" {
+		return errors.New("No trigger id found in action request")
+	}
+	_, userId, appErr := model.DecodeAndVerifyTriggerId(actionReq.TriggerId, &ecdsa.PrivateKey{PublicKey: *a.signingKey})
+	if appErr != nil {
+		return fmt.Errorf("Invalid trigger id: %s", appErr.Error())
+	}
+	if userId != actionReq.UserId {
+		return errors.New("Trigger id was not given to this user")
+	}
+	return nil
+}
+
+func (a MattermostUserAdapter) ParseAction(req *http.Request) (robot.ActionRequest, error) {
+	defer req.Body.Close()
+	var actionReq model.PostActionIntegrationRequest
+	err := json.NewDecoder(req.Body).Decode(&actionReq)
+	if err != nil {
+		return robot.ActionRequest{}, err
+	}
+	actionId, _ := actionReq.Context[actionIdContextKey].(string)
+	if actionId == "" {
+		return robot.ActionRequest{}, errors.New("No action id found in context")
+	}
+	selected, _ := actionReq.Context["selected_option"].(string)
+	err = a.verifyTrigger(actionReq)
+	if err != nil {
+		return robot.ActionRequest{}, err
+	}
+	// channel is taken from the post and user must be member of it, channel id in request is not signed
+	post, resp := a.client.GetPost(actionReq.PostId, "")
+	if resp.Error != nil {
+		return robot.ActionRequest{}, fmt.Errorf("Cannot retrieve post of action: %s", resp.Error.Error())
+	}
+	_, resp = a.client.GetChannelMember(post.ChannelId, actionReq.UserId, "")
+	if resp.Error != nil {
+		return robot.ActionRequest{}, errors.New("User is not a member of the channel of action")
+	}
+
+	user := robot.UserEnvelop{
+		Id:        actionReq.UserId,
+		ChannelId: post.ChannelId,
+	}
+	mattUser, resp := a.client.GetUser(actionReq.UserId, "")
+	if resp.Error == nil {
+		user.Name = mattUser.Username
+	}
+	envelop := robot.Envelop{
+		ChannelId: post.ChannelId,
+		MessageId: actionReq.PostId,
+		ThreadId:  post.RootId,
+		User:      user,
+		Properties: map[string]interface{}{
+			"team_id": actionReq.TeamId,
+		},
+	}
+	channel, resp := a.client.GetChannel(post.ChannelId, "")
+	if resp.Error == nil {
+		envelop.ChannelName = channel.Name
+		envelop.User.ChannelName = channel.Name
+	}
+	return robot.ActionRequest{
+		ActionId: actionId,
+		Selected: selected,
+		Envelop:  envelop,
+	}, nil
+}
+
+func (a MattermostUserAdapter) FormatActionResponse(response robot.ActionResponse) (interface{}, error) {
+	if !response.Update {
+		return model.PostActionIntegrationResponse{
+			EphemeralText: response.Text,
+		}, nil
+	}
+	return model.PostActionIntegrationResponse{
+		Update: &model.Post{
+			Message: response.Text,
+		},
+	}, nil
+}
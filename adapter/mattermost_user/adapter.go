@@ -1,6 +1,8 @@
 package mattermost_user
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -26,6 +28,8 @@ func init() {
 
 const messageSizeMax = 4000
 
+const reconnectDelay = 5 * time.Second
+
 type PostData struct {
 	ID            string    `json:"id"`
 	CreateAt      int64     `json:"create_at"`
@@ -52,6 +56,7 @@ type MattermostUserConfig struct {
 	MattermostUsername            string
 	MattermostUserID              string
 	MattermostPassword            string
+	MattermostToken               string
 	MattermostApi                 string
 	MattermostChannels            []string
 	MattermostTickStatusInSeconds int `cloud-default:"30"`
@@ -60,32 +65,40 @@ type MattermostUserConfig struct {
 type MattermostUserAdapter struct {
 	clientWs    *model.WebSocketClient
 	client      *model.Client4
+	session     *session
 	gubot       *robot.Gubot
 	mutex       *sync.Mutex
 	onlineUsers map[string]interface{}
 	me          *model.User
+	signingKey  *ecdsa.PublicKey
+	stopper     *adapter.Stopper
 }
 
 func NewMattermostUserAdapter() robot.Adapter {
 	return &MattermostUserAdapter{
 		onlineUsers: make(map[string]interface{}),
 		mutex:       new(sync.Mutex),
+		stopper:     adapter.NewStopper(),
 	}
 }
 
 func (a MattermostUserAdapter) Send(envelop robot.Envelop, message string) error {
+	channelId, err := a.envelopChannelId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.createPost(channelId, "", message)
+}
+
+func (a MattermostUserAdapter) envelopChannelId(envelop robot.Envelop) (string, error) {
 	if envelop.ChannelName == "" && envelop.ChannelId == "" {
-		return errors.New("You must provide a channel name or channel id in envelop")
+		return "", errors.New("You must provide a channel name or channel id in envelop")
 	}
-	var err error
-	channelId := envelop.ChannelId
-	if channelId == "" {
-		_, channelId, err = a.getTeamIdAndChannelIdByChannelName(envelop.ChannelName)
-		if err != nil {
-			return err
-		}
+	if envelop.ChannelId != "" {
+		return envelop.ChannelId, nil
 	}
-	return a.createPost(channelId, message)
+	_, channelId, err := a.getTeamIdAndChannelIdByChannelName(envelop.ChannelName)
+	return channelId, err
 }
 
 func (a MattermostUserAdapter) SendDirect(envelop robot.Envelop, message string) error {
@@ -93,15 +106,17 @@ func (a MattermostUserAdapter) SendDirect(envelop robot.Envelop, message string)
 	if resp.Error != nil {
 		return resp.Error
 	}
-	return a.createPost(channel.Id, message)
+	return a.createPost(channel.Id, "", message)
 }
 
-func (a MattermostUserAdapter) createPost(channelId, message string) error {
+// createPost send message in channel, it is sent in thread of post rootId if not empty.
+func (a MattermostUserAdapter) createPost(channelId, rootId, message string) error {
 	messages := adapter.TruncateMessage(message, messageSizeMax)
 
 	for _, msg := range messages {
 		_, resp := a.client.CreatePost(&model.Post{
 			ChannelId: channelId,
+			RootId:    rootId,
 			Message:   msg,
 		})
 		if resp.Error != nil {
@@ -162,17 +177,23 @@ func closeBody(r *http.Response) {
 }
 
 func (a MattermostUserAdapter) Reply(envelop robot.Envelop, message string) error {
-	return a.Send(envelop, envelop.User.Name+": "+message)
+	channelId, err := a.envelopChannelId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.createPost(channelId, envelop.ThreadId, envelop.User.Name+": "+message)
 }
 
 func (a *MattermostUserAdapter) Run(config interface{}, gubot *robot.Gubot) error {
 	conf := config.(*MattermostUserConfig)
 	a.gubot = gubot
-	if conf.MattermostUsername == "" && conf.MattermostUserID == "" {
-		return errors.New("mattermost_username or mattermost_user_id config param is required")
-	}
-	if conf.MattermostPassword == "" {
-		return errors.New("mattermost_password config param is required")
+	if conf.MattermostToken == "" {
+		if conf.MattermostUsername == "" && conf.MattermostUserID == "" {
+			return errors.New("mattermost_username or mattermost_user_id config param is required when not using mattermost_token")
+		}
+		if conf.MattermostPassword == "" {
+			return errors.New("mattermost_password config param is required when not using mattermost_token")
+		}
 	}
 	if conf.MattermostApi == "" {
 		return errors.New("mattermost_api config param is required")
@@ -191,43 +212,39 @@ func (a *MattermostUserAdapter) Run(config interface{}, gubot *robot.Gubot) erro
 		mattApi.Scheme = "http"
 		wsMattApi.Scheme = "ws"
 	}
-	client := model.NewAPIv4Client(mattApi.String())
-	client.HttpClient = gubot.HttpClient()
-	var resp *model.Response
-	if conf.MattermostUsername != "" {
-		_, resp = client.Login(conf.MattermostUsername, conf.MattermostPassword)
-	} else {
-		_, resp = client.LoginById(conf.MattermostUserID, conf.MattermostPassword)
+	a.session = newSession(conf, mattApi.String(), gubot.HttpClient())
+	mattMe, err := a.session.login()
+	if err != nil {
+		return err
 	}
-
-	if resp.Error != nil {
-		return resp.Error
+	a.me = mattMe
+	a.client = a.session.client
+	a.signingKey, err = a.loadSigningKey()
+	if err != nil {
+		log.Warnf("Actions from mattermost will be refused, server signing key can't be loaded: %s", err.Error())
 	}
-	a.client = client
 	websocket.DefaultDialer.TLSClientConfig = &tls.Config{
 		InsecureSkipVerify: gubot.HttpClient().Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify,
 	}
-	clientWs, appErr := model.NewWebSocketClient(wsMattApi.String(), client.AuthToken)
+	clientWs, appErr := model.NewWebSocketClient(wsMattApi.String(), a.session.token())
 	if appErr != nil {
-		return appErr
+		return fmt.Errorf("Cannot connect to mattermost web socket: %s", appErr.Error())
 	}
 	a.clientWs = clientWs
-
-	mattMe, resp := client.GetMe("")
-	if resp.Error != nil {
-		return resp.Error
-	}
-	a.me = mattMe
 	clientWs.Listen()
 	go func() {
 		for {
-			event := <-a.clientWs.EventChannel
+			var event *model.WebSocketEvent
+			select {
+			case <-a.stopper.Stopped():
+				return
+			case event = <-a.clientWs.EventChannel:
+			}
 			if event == nil {
-				appErr := clientWs.Connect()
-				if appErr != nil {
-					log.Error("Error when reconnecting to web socket: " + appErr.Error())
+				if a.stopper.IsStopped() {
+					return
 				}
-				clientWs.Listen()
+				a.reconnect()
 				continue
 			}
 			if event.Event == model.WEBSOCKET_EVENT_USER_ADDED {
@@ -266,14 +283,53 @@ func (a *MattermostUserAdapter) Run(config interface{}, gubot *robot.Gubot) erro
 		if conf.MattermostTickStatusInSeconds <= 0 {
 			return
 		}
+		ticker := time.NewTicker(time.Duration(conf.MattermostTickStatusInSeconds) * time.Second)
+		defer ticker.Stop()
 		for {
 			a.emitStatusChange()
-			time.Sleep(time.Duration(conf.MattermostTickStatusInSeconds) * time.Second)
+			select {
+			case <-a.stopper.Stopped():
+				return
+			case <-ticker.C:
+			}
 		}
 	}()
 	return nil
 }
 
+// reconnect connect again to web socket until it succeeds or adapter is stopped,
+// session is checked before to log in again if it has expired.
+func (a *MattermostUserAdapter) reconnect() {
+	for {
+		_, resp := a.client.GetMe("")
+		if resp.Error != nil {
+			log.Error("Error when checking mattermost session: " + resp.Error.Error())
+		}
+		a.clientWs.AuthToken = a.session.token()
+		appErr := a.clientWs.Connect()
+		if appErr == nil {
+			a.clientWs.Listen()
+			return
+		}
+		log.Error("Error when reconnecting to web socket: " + appErr.Error())
+		select {
+		case <-a.stopper.Stopped():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (a *MattermostUserAdapter) Stop(ctx context.Context) error {
+	if !a.stopper.Close() {
+		return nil
+	}
+	if a.clientWs != nil {
+		a.clientWs.Close()
+	}
+	return nil
+}
+
 func (a *MattermostUserAdapter) emitStatusChange() {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
@@ -382,6 +438,8 @@ func (a MattermostUserAdapter) sendingEnvelop(event *model.WebSocketEvent, mattM
 	envelop.ChannelId = channelId
 	envelop.ChannelName = channelName
 	envelop.Message = postData.Message
+	envelop.MessageId = postData.ID
+	envelop.ThreadId = postData.RootID
 	envelop.User = user
 	mentioned := a.isMentioned(event)
 	envelop.NotMentioned = !mentioned
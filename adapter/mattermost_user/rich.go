@@ -0,0 +1,169 @@
+package mattermost_user
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	"github.com/mattermost/mattermost-server/model"
+)
+
+func (a MattermostUserAdapter) SendRich(envelop robot.Envelop, message robot.Message) error {
+	channelId, err := a.envelopChannelId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.createRichPost(channelId, message)
+}
+
+func (a MattermostUserAdapter) ReplyRich(envelop robot.Envelop, message robot.Message) error {
+	if message.Markdown != "" {
+		message.Markdown = envelop.User.Name + ": " + message.Markdown
+	} else {
+		message.Text = envelop.User.Name + ": " + message.Text
+	}
+	if message.ThreadId == "" {
+		message.ThreadId = envelop.ThreadId
+	}
+	return a.SendRich(envelop, message)
+}
+
+func (a MattermostUserAdapter) SendDirectRich(envelop robot.Envelop, message robot.Message) error {
+	channel, resp := a.client.CreateDirectChannel(a.me.Id, envelop.User.Id)
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return a.createRichPost(channel.Id, message)
+}
+
+// createRichPost send message text as post with attachments and uploaded files,
+// when text is too long first parts are sent as simple posts.
+func (a MattermostUserAdapter) createRichPost(channelId string, message robot.Message) error {
+	fileIds, links, err := a.uploadFiles(channelId, message.Files)
+	if err != nil {
+		return err
+	}
+	text := message.Content()
+	if len(links) > 0 {
+		text = strings.TrimSpace(text + "\n" + strings.Join(links, "\n"))
+	}
+	messages := adapter.TruncateMessage(text, messageSizeMax)
+	for _, msg := range messages[:len(messages)-1] {
+		_, resp := a.client.CreatePost(&model.Post{
+			ChannelId: channelId,
+			RootId:    message.ThreadId,
+			Message:   msg,
+		})
+		if resp.Error != nil {
+			return resp.Error
+		}
+	}
+	post := &model.Post{
+		ChannelId: channelId,
+		RootId:    message.ThreadId,
+		Message:   messages[len(messages)-1],
+		FileIds:   fileIds,
+	}
+	attachments := a.messageToAttachments(message)
+	if len(attachments) > 0 {
+		post.AddProp("attachments", attachments)
+	}
+	_, resp := a.client.CreatePost(post)
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
+
+// uploadFiles upload files with content and give back markdown links for files with only an url.
+func (a MattermostUserAdapter) uploadFiles(channelId string, files []robot.MessageFile) ([]string, []string, error) {
+	fileIds := make([]string, 0)
+	links := make([]string, 0)
+	for _, file := range files {
+		if len(file.Content) == 0 {
+			if file.Url != "" {
+				links = append(links, "["+file.Name+"]("+file.Url+")")
+			}
+			continue
+		}
+		uploaded, resp := a.client.UploadFile(file.Content, channelId, file.Name)
+		if resp.Error != nil {
+			return nil, nil, resp.Error
+		}
+		if len(uploaded.FileInfos) == 0 {
+			return nil, nil, errors.New("No file info returned when uploading file " + file.Name)
+		}
+		fileIds = append(fileIds, uploaded.FileInfos[0].Id)
+	}
+	return fileIds, links, nil
+}
+
+func (a MattermostUserAdapter) messageToAttachments(message robot.Message) []*model.SlackAttachment {
+	attachments := make([]*model.SlackAttachment, 0)
+	main := &model.SlackAttachment{
+		Fallback:  message.PlainText(),
+		Color:     message.Color,
+		Title:     message.Title,
+		TitleLink: message.TitleUrl,
+		Fields:    make([]*model.SlackAttachmentField, 0),
+	}
+	for _, field := range message.Fields {
+		main.Fields = append(main.Fields, &model.SlackAttachmentField{
+			Title: field.Title,
+			Value: field.Value,
+			Short: model.SlackCompatibleBool(field.Short),
+		})
+	}
+	buttons := make([]string, 0)
+	for _, button := range message.Buttons {
+		if button.ActionId != "" {
+			main.Actions = append(main.Actions, &model.PostAction{
+				Type:        model.POST_ACTION_TYPE_BUTTON,
+				Name:        button.Text,
+				Integration: a.actionIntegration(button.ActionId),
+			})
+			continue
+		}
+		if button.Url == "" {
+			buttons = append(buttons, "`"+button.Text+"`")
+			continue
+		}
+		buttons = append(buttons, "["+button.Text+"]("+button.Url+")")
+	}
+	main.Text = strings.Join(buttons, " ")
+	for _, menu := range message.Menus {
+		if menu.ActionId == "" {
+			continue
+		}
+		options := make([]*model.PostActionOptions, len(menu.Options))
+		for i, option := range menu.Options {
+			options[i] = &model.PostActionOptions{
+				Text:  option.Text,
+				Value: option.Value,
+			}
+		}
+		main.Actions = append(main.Actions, &model.PostAction{
+			Type:        model.POST_ACTION_TYPE_SELECT,
+			Name:        menu.Text,
+			Options:     options,
+			Integration: a.actionIntegration(menu.ActionId),
+		})
+	}
+	images := message.Images
+	if len(images) > 0 {
+		main.ImageURL = images[0].Url
+		images = images[1:]
+	}
+	if main.Title != "" || main.Text != "" || main.ImageURL != "" || len(main.Fields) > 0 || len(main.Actions) > 0 {
+		attachments = append(attachments, main)
+	}
+	for _, image := range images {
+		attachments = append(attachments, &model.SlackAttachment{
+			Fallback: image.AltText,
+			Color:    message.Color,
+			ImageURL: image.Url,
+		})
+	}
+	return attachments
+}
@@ -0,0 +1,153 @@
+package mattermost_user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// session keep the adapter logged in on mattermost, when a request is refused because session has expired
+// it logs in again and retries the request.
+// Access tokens of bot accounts and personal access tokens don't expire, they are only checked.
+type session struct {
+	conf      *MattermostUserConfig
+	client    *model.Client4
+	base      http.RoundTripper
+	mutex     *sync.Mutex
+	authToken string
+}
+
+func newSession(conf *MattermostUserConfig, apiUrl string, httpClient *http.Client) *session {
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	s := &session{
+		conf:  conf,
+		base:  base,
+		mutex: new(sync.Mutex),
+	}
+	client := model.NewAPIv4Client(apiUrl)
+	client.HttpClient = &http.Client{
+		Transport: s,
+		Timeout:   httpClient.Timeout,
+	}
+	s.client = client
+	return s
+}
+
+func (s *session) useAccessToken() bool {
+	return s.conf.MattermostToken != ""
+}
+
+// token give current session token, it changes after each login.
+func (s *session) token() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.authToken
+}
+
+// login open a session on mattermost and give the user logged in.
+func (s *session) login() (*model.User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.loginUnlocked()
+}
+
+func (s *session) loginUnlocked() (*model.User, error) {
+	if s.useAccessToken() {
+		s.client.MockSession(s.conf.MattermostToken)
+		s.authToken = s.conf.MattermostToken
+		me, resp := s.client.GetMe("")
+		if resp.Error != nil {
+			return nil, loginError("access token", resp)
+		}
+		return me, nil
+	}
+	// a client without session transport is used to not retry login on failure
+	loginClient := model.NewAPIv4Client(s.client.Url)
+	loginClient.HttpClient = &http.Client{
+		Transport: s.base,
+		Timeout:   s.client.HttpClient.Timeout,
+	}
+	var me *model.User
+	var resp *model.Response
+	if s.conf.MattermostUsername != "" {
+		me, resp = loginClient.Login(s.conf.MattermostUsername, s.conf.MattermostPassword)
+	} else {
+		me, resp = loginClient.LoginById(s.conf.MattermostUserID, s.conf.MattermostPassword)
+	}
+	if resp.Error != nil {
+		return nil, loginError("password", resp)
+	}
+	if loginClient.AuthToken == "" {
+		return nil, errors.New("Cannot login on mattermost: server didn't give a session token")
+	}
+	s.client.MockSession(loginClient.AuthToken)
+	s.authToken = loginClient.AuthToken
+	return me, nil
+}
+
+func loginError(method string, resp *model.Response) error {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("Cannot login on mattermost with %s, credentials are invalid: %s", method, resp.Error.Message)
+	case 0:
+		return fmt.Errorf("Cannot login on mattermost with %s, server is unreachable: %s", method, resp.Error.DetailedError)
+	}
+	return fmt.Errorf("Cannot login on mattermost with %s: %s", method, resp.Error.Error())
+}
+
+// relogin open a new session if session used by a refused request is still the current one,
+// another request may have already logged in again.
+func (s *session) relogin(usedToken string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.authToken != usedToken {
+		return s.authToken, nil
+	}
+	log.Info("Mattermost session has expired, logging in again")
+	_, err := s.loginUnlocked()
+	if err != nil {
+		return "", err
+	}
+	return s.authToken, nil
+}
+
+// RoundTrip implements http.RoundTripper, request is sent again with a new session when it is refused
+// because session has expired.
+func (s *session) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := s.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || s.useAccessToken() {
+		return resp, err
+	}
+	if strings.HasSuffix(req.URL.Path, "/users/login") || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+	usedToken := strings.TrimPrefix(req.Header.Get(model.HEADER_AUTH), model.HEADER_BEARER+" ")
+	newToken, loginErr := s.relogin(usedToken)
+	if loginErr != nil {
+		log.Error(loginErr.Error())
+		return resp, nil
+	}
+	retryReq := new(http.Request)
+	*retryReq = *req
+	retryReq.Header = make(http.Header)
+	for k, v := range req.Header {
+		retryReq.Header[k] = v
+	}
+	retryReq.Header.Set(model.HEADER_AUTH, model.HEADER_BEARER+" "+newToken)
+	if req.GetBody != nil {
+		retryReq.Body, err = req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+	return s.base.RoundTrip(retryReq)
+}
@@ -0,0 +1,365 @@
+package rocketchat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewRocketchatAdapter())
+}
+
+// messageSizeMax is the default value of Message_MaxAllowedSize setting.
+const messageSizeMax = 5000
+
+const (
+	reconnectDelay = 5 * time.Second
+	// seenMessagesMax is the number of message ids kept to ignore updates of already received messages.
+	seenMessagesMax = 1000
+)
+
+const (
+	roomTypeDirect         = "d"
+	messageTypeUserJoined  = "uj"
+	messageTypeUserLeft    = "ul"
+	messageTypeUserAdded   = "au"
+	messageTypeUserRemoved = "ru"
+)
+
+type RocketchatConfig struct {
+	RocketchatUrl                   string
+	RocketchatUser                  string
+	RocketchatPassword              string
+	RocketchatUserId                string
+	RocketchatToken                 string
+	RocketchatChannels              []string
+	RocketchatPingIntervalInSeconds int `cloud-default:"30"`
+}
+
+type message struct {
+	Id       string           `json:"_id"`
+	Rid      string           `json:"rid"`
+	Msg      string           `json:"msg"`
+	Tmid     string           `json:"tmid"`
+	Type     string           `json:"t"`
+	User     user             `json:"u"`
+	Mentions []user           `json:"mentions"`
+	EditedAt *json.RawMessage `json:"editedAt"`
+	Bot      *json.RawMessage `json:"bot"`
+}
+
+// roomInfo is sent by realtime api with each message.
+type roomInfo struct {
+	RoomType        string `json:"roomType"`
+	RoomName        string `json:"roomName"`
+	RoomParticipant bool   `json:"roomParticipant"`
+}
+
+type RocketchatAdapter struct {
+	client      *client
+	config      *RocketchatConfig
+	gubot       *robot.Gubot
+	me          user
+	mutex       *sync.Mutex
+	writeMutex  *sync.Mutex
+	conn        *websocket.Conn
+	id          int64
+	rooms       map[string]room
+	onlineUsers map[string]bool
+	seen        map[string]bool
+	seenOrder   []string
+	stopper     *adapter.Stopper
+}
+
+func NewRocketchatAdapter() robot.Adapter {
+	return &RocketchatAdapter{
+		mutex:       new(sync.Mutex),
+		writeMutex:  new(sync.Mutex),
+		rooms:       make(map[string]room),
+		onlineUsers: make(map[string]bool),
+		seen:        make(map[string]bool),
+		seenOrder:   make([]string, 0),
+		stopper:     adapter.NewStopper(),
+	}
+}
+
+func (a *RocketchatAdapter) Send(envelop robot.Envelop, message string) error {
+	roomId, err := a.envelopRoomId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.sendMessages(roomId, envelop.ThreadId, message)
+}
+
+// Reply mention user (except in direct rooms), in thread of the message which triggered the reply if it was in a thread.
+func (a *RocketchatAdapter) Reply(envelop robot.Envelop, message string) error {
+	roomId, err := a.envelopRoomId(envelop)
+	if err != nil {
+		return err
+	}
+	if roomType, _ := envelop.Properties["room_type"].(string); roomType != roomTypeDirect {
+		message = "@" + envelop.User.Name + " " + message
+	}
+	return a.sendMessages(roomId, envelop.ThreadId, message)
+}
+
+func (a *RocketchatAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	if envelop.User.Name == "" {
+		return errors.New("You must provide a user name in envelop")
+	}
+	roomId, err := a.client.createDirect(envelop.User.Name)
+	if err != nil {
+		return err
+	}
+	return a.sendMessages(roomId, "", message)
+}
+
+func (a *RocketchatAdapter) sendMessages(roomId, threadId, message string) error {
+	for _, msg := range adapter.TruncateMessage(message, messageSizeMax) {
+		err := a.client.sendMessage(roomId, threadId, msg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *RocketchatAdapter) envelopRoomId(envelop robot.Envelop) (string, error) {
+	if envelop.ChannelId != "" {
+		return envelop.ChannelId, nil
+	}
+	if envelop.ChannelName == "" {
+		return "", errors.New("You must provide a channel name or channel id in envelop")
+	}
+	name := strings.TrimPrefix(envelop.ChannelName, "#")
+	a.mutex.Lock()
+	for _, r := range a.rooms {
+		if r.Name == name {
+			a.mutex.Unlock()
+			return r.Id, nil
+		}
+	}
+	a.mutex.Unlock()
+	r, err := a.client.roomByName(name)
+	if err != nil {
+		return "", err
+	}
+	a.cacheRoom(r)
+	return r.Id, nil
+}
+
+func (a *RocketchatAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*RocketchatConfig)
+	a.gubot = gubot
+	if conf.RocketchatUrl == "" {
+		return errors.New("rocketchat_url config param is required")
+	}
+	if conf.RocketchatToken == "" && (conf.RocketchatUser == "" || conf.RocketchatPassword == "") {
+		return errors.New("rocketchat_user and rocketchat_password or rocketchat_user_id and rocketchat_token config params are required")
+	}
+	if conf.RocketchatToken != "" && conf.RocketchatUserId == "" {
+		return errors.New("rocketchat_user_id config param is required when using a token")
+	}
+	if conf.RocketchatPingIntervalInSeconds <= 0 {
+		conf.RocketchatPingIntervalInSeconds = 30
+	}
+	a.config = conf
+	password := conf.RocketchatPassword
+	if conf.RocketchatToken != "" {
+		// personal access token never expires, there is no need to login again
+		password = ""
+	}
+	a.client = newClient(conf.RocketchatUrl, conf.RocketchatUser, password, conf.RocketchatUserId, conf.RocketchatToken, gubot.HttpClient())
+	err := a.client.login()
+	if err != nil {
+		return err
+	}
+	me, err := a.client.me()
+	if err != nil {
+		return err
+	}
+	a.me = me
+	go a.realtimeLoop()
+	return nil
+}
+
+func (a *RocketchatAdapter) Stop(ctx context.Context) error {
+	if !a.stopper.Close() {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.conn != nil {
+		return a.conn.Close()
+	}
+	return nil
+}
+
+func (a *RocketchatAdapter) cacheRoom(r room) {
+	if r.Id == "" {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.rooms[r.Id] = r
+}
+
+// alreadySeen tell if message has already been received, realtime api sends message again when it is updated
+// (e.g.: reactions, url previews or thread replies).
+func (a *RocketchatAdapter) alreadySeen(messageId string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.seen[messageId] {
+		return true
+	}
+	a.seen[messageId] = true
+	a.seenOrder = append(a.seenOrder, messageId)
+	if len(a.seenOrder) > seenMessagesMax {
+		delete(a.seen, a.seenOrder[0])
+		a.seenOrder = a.seenOrder[1:]
+	}
+	return false
+}
+
+func (a *RocketchatAdapter) isListenedChannel(roomName string) bool {
+	for _, ch := range a.config.RocketchatChannels {
+		if strings.TrimPrefix(ch, "#") == roomName {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *RocketchatAdapter) toEnvelop(msg message, info roomInfo) robot.Envelop {
+	if info.RoomName != "" {
+		a.cacheRoom(room{Id: msg.Rid, Name: info.RoomName, Type: info.RoomType})
+	}
+	return robot.Envelop{
+		ChannelId:   msg.Rid,
+		ChannelName: info.RoomName,
+		MessageId:   msg.Id,
+		ThreadId:    msg.Tmid,
+		User: robot.UserEnvelop{
+			Id:          msg.User.Id,
+			Name:        msg.User.Username,
+			ChannelId:   msg.Rid,
+			ChannelName: info.RoomName,
+		},
+		Properties: map[string]interface{}{
+			"room_type": info.RoomType,
+		},
+	}
+}
+
+func (a *RocketchatAdapter) receive(msg message, info roomInfo) {
+	if msg.Id == "" || msg.EditedAt != nil || a.alreadySeen(msg.Id) {
+		return
+	}
+	direct := info.RoomType == roomTypeDirect
+	if !direct && len(a.config.RocketchatChannels) > 0 && !a.isListenedChannel(info.RoomName) {
+		return
+	}
+	envelop := a.toEnvelop(msg, info)
+	if msg.Type != "" {
+		a.emitSystemMessage(msg, envelop)
+		return
+	}
+	if adapter.IsFromBot(msg.User.Id, a.me.Id, msg.Bot != nil) {
+		return
+	}
+	mentioned := direct
+	for _, mention := range msg.Mentions {
+		if mention.Id == a.me.Id {
+			mentioned = true
+		}
+	}
+	text := msg.Msg
+	if mentioned {
+		text = strings.TrimSpace(strings.Replace(text, "@"+a.me.Username, "", -1))
+	}
+	envelop.Message = text
+	envelop.NotMentioned = !mentioned
+	a.gubot.Receive(envelop)
+}
+
+// emitSystemMessage emit channel enter and leave events from system messages, others are ignored.
+func (a *RocketchatAdapter) emitSystemMessage(msg message, envelop robot.Envelop) {
+	var eventName robot.EventAction
+	switch msg.Type {
+	case messageTypeUserJoined, messageTypeUserAdded:
+		eventName = robot.EVENT_ROBOT_CHANNEL_ENTER
+	case messageTypeUserLeft, messageTypeUserRemoved:
+		eventName = robot.EVENT_ROBOT_CHANNEL_LEAVE
+	default:
+		return
+	}
+	// when a user is added or removed by someone else, message contains username of this user
+	if msg.Type == messageTypeUserAdded || msg.Type == messageTypeUserRemoved {
+		envelop.User.Id = ""
+		envelop.User.Name = msg.Msg
+	}
+	if envelop.User.Name == a.me.Username {
+		return
+	}
+	a.gubot.Emit(robot.GubotEvent{
+		Name:    eventName,
+		Envelop: envelop,
+	})
+}
+
+// statusChange emit user online and offline events when status of a user goes from or to offline,
+// going from online to away or busy is not a change.
+func (a *RocketchatAdapter) statusChange(userId, username string, online bool) {
+	if userId == "" || userId == a.me.Id {
+		return
+	}
+	a.mutex.Lock()
+	wasOnline := a.onlineUsers[userId]
+	if online {
+		a.onlineUsers[userId] = true
+	} else {
+		delete(a.onlineUsers, userId)
+	}
+	a.mutex.Unlock()
+	if wasOnline == online {
+		return
+	}
+	if username == "" {
+		u, err := a.client.userInfo(userId)
+		if err != nil {
+			log.Errorf("Cannot get rocket.chat user '%s': %s", userId, err.Error())
+		}
+		username = u.Username
+	}
+	eventName := robot.EVENT_ROBOT_USER_OFFLINE
+	if online {
+		eventName = robot.EVENT_ROBOT_USER_ONLINE
+	}
+	a.gubot.Emit(robot.GubotEvent{
+		Name: eventName,
+		Envelop: robot.Envelop{
+			User: robot.UserEnvelop{
+				Id:   userId,
+				Name: username,
+			},
+			Properties: make(map[string]interface{}),
+		},
+	})
+}
+
+func (a RocketchatAdapter) Name() string {
+	return "rocketchat"
+}
+
+func (a RocketchatAdapter) Config() interface{} {
+	return RocketchatConfig{}
+}
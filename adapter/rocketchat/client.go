@@ -0,0 +1,212 @@
+package rocketchat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// client call the rocket.chat rest api.
+type client struct {
+	baseUrl    string
+	user       string
+	password   string
+	httpClient *http.Client
+	mutex      *sync.Mutex
+	userId     string
+	authToken  string
+}
+
+type user struct {
+	Id       string `json:"_id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+type room struct {
+	Id   string `json:"_id"`
+	Name string `json:"name"`
+	Type string `json:"t"`
+}
+
+type apiError struct {
+	Success bool   `json:"success"`
+	Reason  string `json:"error"`
+	Message string `json:"message"`
+	status  int
+}
+
+func (e apiError) Error() string {
+	if e.Reason != "" {
+		return "rocket.chat api error: " + e.Reason
+	}
+	if e.Message != "" {
+		return "rocket.chat api error: " + e.Message
+	}
+	return fmt.Sprintf("rocket.chat api returned status %d", e.status)
+}
+
+func newClient(baseUrl, user, password, userId, authToken string, httpClient *http.Client) *client {
+	return &client{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		user:       user,
+		password:   password,
+		userId:     userId,
+		authToken:  authToken,
+		httpClient: httpClient,
+		mutex:      new(sync.Mutex),
+	}
+}
+
+func (c *client) credentials() (string, string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.userId, c.authToken
+}
+
+// login get an auth token with user and password, it does nothing when a personal access token is used.
+func (c *client) login() error {
+	if c.password == "" {
+		return nil
+	}
+	var result struct {
+		Data struct {
+			UserId    string `json:"userId"`
+			AuthToken string `json:"authToken"`
+		} `json:"data"`
+	}
+	err := c.request("POST", "/api/v1/login", map[string]string{
+		"user":     c.user,
+		"password": c.password,
+	}, &result)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.userId = result.Data.UserId
+	c.authToken = result.Data.AuthToken
+	return nil
+}
+
+// do call api, it logs in again when session has expired.
+func (c *client) do(method, path string, body interface{}, result interface{}) error {
+	err := c.request(method, path, body, result)
+	apiErr, ok := err.(apiError)
+	if !ok || apiErr.status != http.StatusUnauthorized || c.password == "" {
+		return err
+	}
+	err = c.login()
+	if err != nil {
+		return err
+	}
+	return c.request(method, path, body, result)
+}
+
+func (c *client) request(method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.baseUrl+path, reader)
+	if err != nil {
+		return err
+	}
+	userId, authToken := c.credentials()
+	if authToken != "" {
+		req.Header.Set("X-User-Id", userId)
+		req.Header.Set("X-Auth-Token", authToken)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := apiError{status: resp.StatusCode}
+		json.Unmarshal(b, &apiErr)
+		return apiErr
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(b, result)
+}
+
+func (c *client) me() (user, error) {
+	var me user
+	err := c.do("GET", "/api/v1/me", nil, &me)
+	return me, err
+}
+
+func (c *client) sendMessage(roomId, threadId, text string) error {
+	msg := map[string]string{
+		"rid": roomId,
+		"msg": text,
+	}
+	if threadId != "" {
+		msg["tmid"] = threadId
+	}
+	return c.do("POST", "/api/v1/chat.sendMessage", map[string]interface{}{"message": msg}, nil)
+}
+
+func (c *client) roomByName(name string) (room, error) {
+	var result struct {
+		Room *room `json:"room"`
+	}
+	err := c.do("GET", "/api/v1/rooms.info?roomName="+url.QueryEscape(name), nil, &result)
+	if err != nil {
+		return room{}, err
+	}
+	if result.Room == nil {
+		return room{}, fmt.Errorf("Room '%s' not found", name)
+	}
+	return *result.Room, nil
+}
+
+// createDirect give id of direct room with user, room is created if it doesn't exist.
+func (c *client) createDirect(username string) (string, error) {
+	var result struct {
+		Room *struct {
+			Rid string `json:"rid"`
+			Id  string `json:"_id"`
+		} `json:"room"`
+	}
+	err := c.do("POST", "/api/v1/im.create", map[string]string{"username": username}, &result)
+	if err != nil {
+		return "", err
+	}
+	if result.Room == nil {
+		return "", errors.New("rocket.chat didn't create direct room")
+	}
+	if result.Room.Rid != "" {
+		return result.Room.Rid, nil
+	}
+	return result.Room.Id, nil
+}
+
+func (c *client) userInfo(userId string) (user, error) {
+	var result struct {
+		User user `json:"user"`
+	}
+	err := c.do("GET", "/api/v1/users.info?userId="+url.QueryEscape(userId), nil, &result)
+	return result.User, err
+}
@@ -0,0 +1,240 @@
+package rocketchat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	collectionRoomMessages = "stream-room-messages"
+	collectionNotifyLogged = "stream-notify-logged"
+	eventUserStatus        = "user-status"
+)
+
+// ddpMessage is a message of the ddp protocol used by rocket.chat realtime api.
+type ddpMessage struct {
+	Msg        string          `json:"msg"`
+	Id         string          `json:"id,omitempty"`
+	Session    string          `json:"session,omitempty"`
+	Collection string          `json:"collection,omitempty"`
+	Fields     json.RawMessage `json:"fields,omitempty"`
+	Error      *ddpError       `json:"error,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+}
+
+type ddpError struct {
+	Error   interface{} `json:"error"`
+	Reason  string      `json:"reason"`
+	Message string      `json:"message"`
+}
+
+type streamFields struct {
+	EventName string            `json:"eventName"`
+	Args      []json.RawMessage `json:"args"`
+}
+
+// realtimeLoop keep a connection to the realtime api, it reconnects when connection is lost.
+func (a *RocketchatAdapter) realtimeLoop() {
+	for {
+		err := a.runRealtime()
+		if a.stopper.IsStopped() {
+			return
+		}
+		log.Error("rocket.chat realtime connection lost, reconnecting: " + err.Error())
+		select {
+		case <-a.stopper.Stopped():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// websocketUrl give url of realtime api from url of rocket.chat server.
+func websocketUrl(serverUrl string) string {
+	wsUrl := strings.TrimSuffix(serverUrl, "/") + "/websocket"
+	if strings.HasPrefix(wsUrl, "https://") {
+		return "wss://" + strings.TrimPrefix(wsUrl, "https://")
+	}
+	return "ws://" + strings.TrimPrefix(wsUrl, "http://")
+}
+
+func (a *RocketchatAdapter) runRealtime() error {
+	conn, _, err := adapter.WebsocketDialer(a.gubot.HttpClient()).Dial(websocketUrl(a.config.RocketchatUrl), nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	a.mutex.Lock()
+	a.conn = conn
+	a.mutex.Unlock()
+	if a.stopper.IsStopped() {
+		return errors.New("rocketchat adapter is stopped")
+	}
+	pingInterval := time.Duration(a.config.RocketchatPingIntervalInSeconds) * time.Second
+	conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+
+	err = a.writeRealtime(map[string]interface{}{
+		"msg":     "connect",
+		"version": "1",
+		"support": []string{"1"},
+	})
+	if err != nil {
+		return err
+	}
+	_, authToken := a.client.credentials()
+	loginId := a.nextId()
+	err = a.writeRealtime(map[string]interface{}{
+		"msg":    "method",
+		"method": "login",
+		"id":     loginId,
+		"params": []interface{}{map[string]string{"resume": authToken}},
+	})
+	if err != nil {
+		return err
+	}
+
+	closed := make(chan struct{})
+	defer close(closed)
+	go a.ping(pingInterval, closed)
+
+	for {
+		var msg ddpMessage
+		err := conn.ReadJSON(&msg)
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		switch msg.Msg {
+		case "ping":
+			a.writeRealtime(map[string]interface{}{"msg": "pong", "id": msg.Id})
+		case "failed":
+			return errors.New("rocket.chat realtime api doesn't support ddp version 1")
+		case "result":
+			if msg.Id != loginId {
+				continue
+			}
+			if msg.Error != nil {
+				// token may have expired, a new one is asked before reconnecting
+				loginErr := a.client.login()
+				if loginErr != nil {
+					log.Error("Error when logging in on rocket.chat: " + loginErr.Error())
+				}
+				return fmt.Errorf("rocket.chat realtime login failed: %s", msg.Error.Reason)
+			}
+			err = a.subscribe()
+			if err != nil {
+				return err
+			}
+			log.Infof("Connected on rocket.chat as %s", a.me.Username)
+		case "nosub":
+			if msg.Error != nil {
+				return fmt.Errorf("rocket.chat realtime subscription failed: %s", msg.Error.Reason)
+			}
+		case "changed":
+			a.dispatch(msg.Collection, msg.Fields)
+		}
+	}
+}
+
+func (a *RocketchatAdapter) subscribe() error {
+	err := a.writeRealtime(map[string]interface{}{
+		"msg":    "sub",
+		"id":     a.nextId(),
+		"name":   collectionRoomMessages,
+		"params": []interface{}{"__my_messages__", false},
+	})
+	if err != nil {
+		return err
+	}
+	return a.writeRealtime(map[string]interface{}{
+		"msg":    "sub",
+		"id":     a.nextId(),
+		"name":   collectionNotifyLogged,
+		"params": []interface{}{eventUserStatus, false},
+	})
+}
+
+// ping keep connection alive, connection is closed by read deadline when server doesn't answer anymore.
+func (a *RocketchatAdapter) ping(interval time.Duration, closed chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			err := a.writeRealtime(map[string]interface{}{"msg": "ping", "id": a.nextId()})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (a *RocketchatAdapter) writeRealtime(data interface{}) error {
+	a.writeMutex.Lock()
+	defer a.writeMutex.Unlock()
+	a.mutex.Lock()
+	conn := a.conn
+	a.mutex.Unlock()
+	if conn == nil {
+		return errors.New("rocket.chat realtime api is not connected")
+	}
+	return conn.WriteJSON(data)
+}
+
+func (a *RocketchatAdapter) nextId() string {
+	return "gubot" + strconv.FormatInt(atomic.AddInt64(&a.id, 1), 10)
+}
+
+func (a *RocketchatAdapter) dispatch(collection string, data json.RawMessage) {
+	var fields streamFields
+	err := json.Unmarshal(data, &fields)
+	if err != nil || len(fields.Args) == 0 {
+		return
+	}
+	switch collection {
+	case collectionRoomMessages:
+		var msg message
+		if err := json.Unmarshal(fields.Args[0], &msg); err != nil {
+			return
+		}
+		var info roomInfo
+		if len(fields.Args) > 1 {
+			json.Unmarshal(fields.Args[1], &info)
+		}
+		go a.receive(msg, info)
+	case collectionNotifyLogged:
+		if fields.EventName != eventUserStatus {
+			return
+		}
+		var status []interface{}
+		if err := json.Unmarshal(fields.Args[0], &status); err != nil || len(status) < 3 {
+			return
+		}
+		userId, _ := status[0].(string)
+		username, _ := status[1].(string)
+		// status changes are handled in order to not miss a transition
+		a.statusChange(userId, username, isOnlineStatus(status[2]))
+	}
+}
+
+// isOnlineStatus tell if status is not offline, status is a number on recent servers (0 is offline)
+// and a string on older ones.
+func isOnlineStatus(status interface{}) bool {
+	switch s := status.(type) {
+	case float64:
+		return s != 0
+	case string:
+		return s != "" && s != "offline"
+	}
+	return false
+}
@@ -2,7 +2,9 @@ package shell
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"github.com/ArthurHlt/gubot/adapter"
 	"github.com/ArthurHlt/gubot/robot"
 	"os"
 	osuser "os/user"
@@ -14,10 +16,13 @@ func init() {
 }
 
 type ShellAdapter struct {
+	stopper *adapter.Stopper
 }
 
 func NewShellAdapter() robot.Adapter {
-	return &ShellAdapter{}
+	return &ShellAdapter{
+		stopper: adapter.NewStopper(),
+	}
 }
 
 func (a ShellAdapter) Send(envelop robot.Envelop, message string) error {
@@ -45,6 +50,9 @@ func (a ShellAdapter) Run(config interface{}, gubot *robot.Gubot) error {
 			reader := bufio.NewReader(os.Stdin)
 			fmt.Print(strings.Title(gubot.Name()) + "> ")
 			text, _ := reader.ReadString('\n')
+			if a.stopper.IsStopped() {
+				return
+			}
 			envelop := robot.Envelop{
 				User: robot.UserEnvelop{
 					Name: user.Username,
@@ -95,6 +103,11 @@ func (a ShellAdapter) Run(config interface{}, gubot *robot.Gubot) error {
 // 	return ""
 // }
 
+func (a ShellAdapter) Stop(ctx context.Context) error {
+	a.stopper.Close()
+	return nil
+}
+
 func (a ShellAdapter) Name() string {
 	return "shell"
 }
@@ -0,0 +1,30 @@
+package slack
+
+import (
+	"errors"
+	"github.com/ArthurHlt/gubot/adapter/slackapi"
+	"github.com/ArthurHlt/gubot/robot"
+	"net/http"
+)
+
+// ParseAction read interactive message payload sent by slack on gubot action url,
+// request is verified with signing secret when set, with verification token otherwise.
+func (a SlackAdapter) ParseAction(req *http.Request) (robot.ActionRequest, error) {
+	defer req.Body.Close()
+	values, err := a.readSignedForm(req)
+	if err != nil {
+		return robot.ActionRequest{}, err
+	}
+	payload, err := slackapi.ParseActionPayload(values.Get("payload"))
+	if err != nil {
+		return robot.ActionRequest{}, err
+	}
+	if a.config.SlackSigningSecret == "" && !a.isValidToken(payload.Token) {
+		return robot.ActionRequest{}, errors.New("token given is not valid")
+	}
+	return payload.ToActionRequest(), nil
+}
+
+func (a SlackAdapter) FormatActionResponse(response robot.ActionResponse) (interface{}, error) {
+	return slackapi.FormatActionResponse(response), nil
+}
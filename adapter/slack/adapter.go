@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"github.com/ArthurHlt/gubot/adapter/slackapi"
 	"github.com/ArthurHlt/gubot/robot"
 	log "github.com/sirupsen/logrus"
 	"net/http"
@@ -16,21 +17,26 @@ func init() {
 }
 
 type SlackConfig struct {
-	SlackTokens        []string
-	SlackChannel       string
-	SlackEndpoint      string
-	SlackIconEmoji     string
-	SlackIncomeUrl     string
-	SlackIconUrl       string
-	SlackGubotUsername string
+	SlackTokens              []string
+	SlackChannel             string
+	SlackEndpoint            string
+	SlackIconEmoji           string
+	SlackIncomeUrl           string
+	SlackIconUrl             string
+	SlackGubotUsername       string
+	SlackSigningSecret       string
+	SlackCommandEndpoint     string `cloud-default:"/slack/command"`
+	SlackCommandResponseType string `cloud-default:"ephemeral"`
 }
 
 type Notification struct {
-	Text      string      `json:"text"`
-	Username  string      `json:"username"`
-	IconURL   interface{} `json:"icon_url,omitempty"`
-	IconEmoji interface{} `json:"icon_emoji,omitempty"`
-	Channel   interface{} `json:"channel"`
+	Text        string                `json:"text"`
+	Username    string                `json:"username"`
+	IconURL     interface{}           `json:"icon_url,omitempty"`
+	IconEmoji   interface{}           `json:"icon_emoji,omitempty"`
+	Channel     interface{}           `json:"channel"`
+	Attachments []slackapi.Attachment `json:"attachments,omitempty"`
+	ThreadTs    string                `json:"thread_ts,omitempty"`
 }
 
 type SlackAdapter struct {
@@ -45,6 +51,10 @@ func NewSlackAdapter() robot.Adapter {
 func (a SlackAdapter) Send(envelop robot.Envelop, message string) error {
 	notif := a.envelopToNotif(envelop)
 	notif.Text = message
+	return a.sendNotif(notif)
+}
+
+func (a SlackAdapter) sendNotif(notif Notification) error {
 	jsonMessage, err := json.Marshal(notif)
 	if err != nil {
 		return err
@@ -83,7 +93,10 @@ func (a SlackAdapter) envelopToNotif(envelop robot.Envelop) Notification {
 }
 
 func (a SlackAdapter) Reply(envelop robot.Envelop, message string) error {
-	return a.Send(envelop, "@"+envelop.User.Name+": "+message)
+	notif := a.envelopToNotif(envelop)
+	notif.Text = "@" + envelop.User.Name + ": " + message
+	notif.ThreadTs = envelop.ThreadId
+	return a.sendNotif(notif)
 }
 
 func (a SlackAdapter) getChannel(envelop robot.Envelop) string {
@@ -105,11 +118,18 @@ func (a *SlackAdapter) Run(config interface{}, gubot *robot.Gubot) error {
 	if slackConf.SlackEndpoint == "" {
 		slackConf.SlackEndpoint = "/slack"
 	}
+	if slackConf.SlackCommandEndpoint == "" {
+		slackConf.SlackCommandEndpoint = "/slack/command"
+	}
+	if slackConf.SlackCommandResponseType == "" {
+		slackConf.SlackCommandResponseType = slackapi.ResponseEphemeral
+	}
 	if slackConf.SlackIncomeUrl == "" {
 		return errors.New("slack_income_url config param is required")
 	}
 	a.config = slackConf
 	gubot.Router().HandleFunc(a.config.SlackEndpoint, a.handler).Methods("POST")
+	gubot.Router().HandleFunc(a.config.SlackCommandEndpoint, a.commandHandler).Methods("POST")
 	return nil
 }
 
@@ -128,6 +148,8 @@ func (a SlackAdapter) handler(w http.ResponseWriter, req *http.Request) {
 	channelId := req.PostForm.Get("channel_id")
 	envelop.ChannelName = channel
 	envelop.ChannelId = channelId
+	envelop.MessageId = req.PostForm.Get("timestamp")
+	envelop.ThreadId = req.PostForm.Get("thread_ts")
 
 	user.ChannelName = channel
 	user.Id = req.PostForm.Get("user_id")
@@ -0,0 +1,105 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/ArthurHlt/gubot/adapter/slackapi"
+	"github.com/ArthurHlt/gubot/robot"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func (a SlackAdapter) Format(message string) (interface{}, error) {
+	if message == "" {
+		return nil, nil
+	}
+	return slackapi.FormatCommandResponse(a.config.SlackCommandResponseType, message), nil
+}
+
+// Register create a slash command token derived from the secret verifying slack requests,
+// slack commands must be created in slack app settings with SlackCommandEndpoint as request url.
+func (a SlackAdapter) Register(slashCommand robot.SlashCommand) ([]robot.SlashCommandToken, error) {
+	id := a.commandTokenId(slashCommand.Trigger)
+	var slashToken robot.SlashCommandToken
+	var c int
+	a.gubot.Store().Where("id = ?", id).Find(&slashToken).Count(&c)
+	if c == 1 {
+		return []robot.SlashCommandToken{}, nil
+	}
+	log.Infof(
+		"Slack command '/%s' must be created in slack app settings with request url %s",
+		slashCommand.Trigger,
+		a.gubot.Host()+a.config.SlackCommandEndpoint,
+	)
+	return []robot.SlashCommandToken{{
+		ID:          id,
+		AdapterName: a.Name(),
+		CommandName: slashCommand.Trigger,
+	}}, nil
+}
+
+// commandTokenId give the id of slash command token, slack send the same token for all commands of an app
+// so id is signed with signing secret or verification tokens.
+func (a SlackAdapter) commandTokenId(trigger string) string {
+	secret := a.config.SlackSigningSecret
+	if secret == "" {
+		secret = strings.Join(a.config.SlackTokens, ",")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(a.Name() + ":" + trigger))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readSignedForm read form sent by slack and check its signature when signing secret is set,
+// requests must be verified with verification token by caller otherwise.
+func (a SlackAdapter) readSignedForm(req *http.Request) (url.Values, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if a.config.SlackSigningSecret != "" {
+		err = slackapi.VerifyRequest(a.config.SlackSigningSecret, req.Header, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return url.ParseQuery(string(body))
+}
+
+// verifyCommand check request with signing secret when set, with verification token otherwise.
+func (a SlackAdapter) verifyCommand(req *http.Request) (url.Values, error) {
+	values, err := a.readSignedForm(req)
+	if err != nil {
+		return nil, err
+	}
+	if a.config.SlackSigningSecret == "" && !a.isValidToken(values.Get("token")) {
+		return nil, errors.New("token given is not valid")
+	}
+	return values, nil
+}
+
+func (a SlackAdapter) commandHandler(w http.ResponseWriter, req *http.Request) {
+	values, err := a.verifyCommand(req)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		log.Error("Error with slack adapter: " + err.Error())
+		return
+	}
+	var slashToken robot.SlashCommandToken
+	var c int
+	err = a.gubot.Store().Where("id = ?", a.commandTokenId(slackapi.CommandName(values))).Find(&slashToken).Count(&c).Error
+	if err != nil || c == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Slash command not found"))
+		log.Errorf("Slack command '%s' not found", values.Get("command"))
+		return
+	}
+	slackapi.ServeCommand(w, a.gubot.HttpClient(), values.Get("response_url"), func() (interface{}, error) {
+		return a.gubot.DispatchCommand(slashToken, slackapi.CommandToEnvelop(values))
+	})
+}
@@ -0,0 +1,37 @@
+package slack
+
+import (
+	"github.com/ArthurHlt/gubot/adapter/slackapi"
+	"github.com/ArthurHlt/gubot/robot"
+	"strings"
+)
+
+func (a SlackAdapter) SendRich(envelop robot.Envelop, message robot.Message) error {
+	notif := a.envelopToNotif(envelop)
+	notif.Text = message.Content()
+	links := make([]string, 0)
+	for _, file := range message.Files {
+		// incoming webhooks can't upload files
+		if file.Url != "" {
+			links = append(links, "<"+file.Url+"|"+file.Name+">")
+		}
+	}
+	if len(links) > 0 {
+		notif.Text = strings.TrimSpace(notif.Text + "\n" + strings.Join(links, "\n"))
+	}
+	notif.Attachments = slackapi.MessageToAttachments(message)
+	notif.ThreadTs = message.ThreadId
+	return a.sendNotif(notif)
+}
+
+func (a SlackAdapter) ReplyRich(envelop robot.Envelop, message robot.Message) error {
+	if message.Markdown != "" {
+		message.Markdown = "@" + envelop.User.Name + ": " + message.Markdown
+	} else {
+		message.Text = "@" + envelop.User.Name + ": " + message.Text
+	}
+	if message.ThreadId == "" {
+		message.ThreadId = envelop.ThreadId
+	}
+	return a.SendRich(envelop, message)
+}
@@ -0,0 +1,30 @@
+package slack_events
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/ArthurHlt/gubot/adapter/slackapi"
+	"github.com/ArthurHlt/gubot/robot"
+)
+
+// ParseAction read interactive message payload sent by slack on gubot action url after checking its signature.
+func (a SlackEventsAdapter) ParseAction(req *http.Request) (robot.ActionRequest, error) {
+	body, err := a.readSignedBody(req)
+	if err != nil {
+		return robot.ActionRequest{}, err
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return robot.ActionRequest{}, err
+	}
+	payload, err := slackapi.ParseActionPayload(values.Get("payload"))
+	if err != nil {
+		return robot.ActionRequest{}, err
+	}
+	return payload.ToActionRequest(), nil
+}
+
+func (a SlackEventsAdapter) FormatActionResponse(response robot.ActionResponse) (interface{}, error) {
+	return slackapi.FormatActionResponse(response), nil
+}
@@ -0,0 +1,406 @@
+package slack_events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/adapter/slackapi"
+	"github.com/ArthurHlt/gubot/robot"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewSlackEventsAdapter())
+}
+
+const messageSizeMax = 4000
+
+// seenTTL is the time during which an already received message is ignored, slack retries events not acknowledged in time.
+const seenTTL = 10 * time.Minute
+
+type SlackEventsConfig struct {
+	SlackEventsBotToken            string
+	SlackEventsSigningSecret       string
+	SlackEventsEndpoint            string `cloud-default:"/slack/events"`
+	SlackEventsCommandEndpoint     string `cloud-default:"/slack/commands"`
+	SlackEventsCommandResponseType string `cloud-default:"ephemeral"`
+	SlackEventsApiUrl              string
+	SlackEventsChannels            []string
+}
+
+type eventCallback struct {
+	Type      string          `json:"type"`
+	Challenge string          `json:"challenge"`
+	TeamId    string          `json:"team_id"`
+	EventId   string          `json:"event_id"`
+	Event     json.RawMessage `json:"event"`
+}
+
+type event struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	User        string `json:"user"`
+	BotId       string `json:"bot_id"`
+	Text        string `json:"text"`
+	Channel     string `json:"channel"`
+	ChannelType string `json:"channel_type"`
+	Ts          string `json:"ts"`
+	ThreadTs    string `json:"thread_ts"`
+}
+
+type SlackEventsAdapter struct {
+	client   *slackapi.Client
+	config   *SlackEventsConfig
+	gubot    *robot.Gubot
+	me       slackapi.AuthTestResponse
+	mutex    *sync.Mutex
+	users    map[string]string
+	channels map[string]slackapi.Channel
+	seen     map[string]time.Time
+	stopper  *adapter.Stopper
+}
+
+func NewSlackEventsAdapter() robot.Adapter {
+	return &SlackEventsAdapter{
+		mutex:    new(sync.Mutex),
+		users:    make(map[string]string),
+		channels: make(map[string]slackapi.Channel),
+		seen:     make(map[string]time.Time),
+		stopper:  adapter.NewStopper(),
+	}
+}
+
+func (a SlackEventsAdapter) Send(envelop robot.Envelop, message string) error {
+	channelId, err := a.envelopChannelId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.postMessage(channelId, "", message)
+}
+
+func (a SlackEventsAdapter) Reply(envelop robot.Envelop, message string) error {
+	channelId, err := a.envelopChannelId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.postMessage(channelId, envelop.ThreadId, "<@"+envelop.User.Id+">: "+message)
+}
+
+func (a SlackEventsAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	channelId, err := a.client.ConversationsOpen(envelop.User.Id)
+	if err != nil {
+		return err
+	}
+	return a.postMessage(channelId, "", message)
+}
+
+// postMessage send message in channel, it is sent in thread threadTs if not empty.
+func (a SlackEventsAdapter) postMessage(channelId, threadTs, message string) error {
+	for _, msg := range adapter.TruncateMessage(message, messageSizeMax) {
+		_, err := a.client.PostMessage(slackapi.PostMessage{
+			Channel:  channelId,
+			Text:     msg,
+			ThreadTs: threadTs,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a SlackEventsAdapter) envelopChannelId(envelop robot.Envelop) (string, error) {
+	if envelop.ChannelName == "" && envelop.ChannelId == "" {
+		return "", errors.New("You must provide a channel name or channel id in envelop")
+	}
+	if envelop.ChannelId != "" {
+		return envelop.ChannelId, nil
+	}
+	channel, err := a.client.FindConversation(envelop.ChannelName)
+	if err != nil {
+		return "", err
+	}
+	a.mutex.Lock()
+	a.channels[channel.Id] = channel
+	a.mutex.Unlock()
+	return channel.Id, nil
+}
+
+func (a *SlackEventsAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*SlackEventsConfig)
+	a.gubot = gubot
+	if conf.SlackEventsBotToken == "" {
+		return errors.New("slack_events_bot_token config param is required")
+	}
+	if conf.SlackEventsSigningSecret == "" {
+		return errors.New("slack_events_signing_secret config param is required")
+	}
+	if conf.SlackEventsEndpoint == "" {
+		conf.SlackEventsEndpoint = "/slack/events"
+	}
+	if conf.SlackEventsCommandEndpoint == "" {
+		conf.SlackEventsCommandEndpoint = "/slack/commands"
+	}
+	if conf.SlackEventsCommandResponseType == "" {
+		conf.SlackEventsCommandResponseType = slackapi.ResponseEphemeral
+	}
+	a.config = conf
+	a.client = slackapi.NewClient(conf.SlackEventsBotToken, conf.SlackEventsApiUrl, gubot.HttpClient())
+	me, err := a.client.AuthTest()
+	if err != nil {
+		return err
+	}
+	a.me = me
+	gubot.Router().HandleFunc(conf.SlackEventsEndpoint, a.eventsHandler).Methods("POST")
+	gubot.Router().HandleFunc(conf.SlackEventsCommandEndpoint, a.commandHandler).Methods("POST")
+	return nil
+}
+
+// Stop wait for events and commands being processed.
+func (a *SlackEventsAdapter) Stop(ctx context.Context) error {
+	return a.stopper.Stop(ctx)
+}
+
+// readSignedBody give body of request sent by slack after checking its signature.
+func (a SlackEventsAdapter) readSignedBody(req *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	err = slackapi.VerifyRequest(a.config.SlackEventsSigningSecret, req.Header, body)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (a *SlackEventsAdapter) eventsHandler(w http.ResponseWriter, req *http.Request) {
+	if a.stopper.IsStopped() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, err := a.readSignedBody(req)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		log.Error("Error with slack events adapter: " + err.Error())
+		return
+	}
+	var callback eventCallback
+	err = json.Unmarshal(body, &callback)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Error("Error when unmarshalling slack event: " + err.Error())
+		return
+	}
+	if callback.Type == "url_verification" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(callback.Challenge))
+		return
+	}
+	if callback.Type != "event_callback" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var evt event
+	err = json.Unmarshal(callback.Event, &evt)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Error("Error when unmarshalling slack event: " + err.Error())
+		return
+	}
+	// slack expects an answer in 3 seconds, event is processed after acknowledging it
+	if !a.stopper.Begin() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	go func() {
+		defer a.stopper.End()
+		a.handleEvent(callback.TeamId, evt)
+	}()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *SlackEventsAdapter) handleEvent(teamId string, evt event) {
+	switch evt.Type {
+	case "member_joined_channel":
+		a.gubot.Emit(robot.GubotEvent{
+			Name:    robot.EVENT_ROBOT_CHANNEL_ENTER,
+			Envelop: a.userEnvelop(teamId, evt.User, evt.Channel),
+		})
+	case "member_left_channel":
+		a.gubot.Emit(robot.GubotEvent{
+			Name:    robot.EVENT_ROBOT_CHANNEL_LEAVE,
+			Envelop: a.userEnvelop(teamId, evt.User, evt.Channel),
+		})
+	case "message", "app_mention":
+		a.receiveMessage(teamId, evt)
+	}
+}
+
+func (a *SlackEventsAdapter) receiveMessage(teamId string, evt event) {
+	// edited, deleted and bot messages have a subtype
+	if evt.Subtype != "" || evt.User == "" || adapter.IsFromBot(evt.User, a.me.UserId, evt.BotId != "") {
+		return
+	}
+	// a mention is sent both as message and app_mention when bot subscribed to both
+	if a.alreadySeen(evt.Channel + ":" + evt.Ts) {
+		return
+	}
+	envelop := a.userEnvelop(teamId, evt.User, evt.Channel)
+	if len(a.config.SlackEventsChannels) > 0 && !a.isListenedChannel(envelop.ChannelName) {
+		return
+	}
+	mention := "<@" + a.me.UserId + ">"
+	mentioned := evt.Type == "app_mention" || evt.ChannelType == "im" || strings.Contains(evt.Text, mention)
+	envelop.Message = evt.Text
+	if mentioned {
+		envelop.Message = strings.TrimSpace(strings.Replace(envelop.Message, mention, "", -1))
+	}
+	envelop.NotMentioned = !mentioned
+	envelop.MessageId = evt.Ts
+	envelop.ThreadId = evt.ThreadTs
+	a.gubot.Receive(envelop)
+}
+
+func (a SlackEventsAdapter) isListenedChannel(channelName string) bool {
+	for _, channel := range a.config.SlackEventsChannels {
+		if strings.TrimPrefix(channel, "#") == channelName {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *SlackEventsAdapter) alreadySeen(key string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	now := time.Now()
+	for seenKey, seenAt := range a.seen {
+		if now.Sub(seenAt) > seenTTL {
+			delete(a.seen, seenKey)
+		}
+	}
+	if _, ok := a.seen[key]; ok {
+		return true
+	}
+	a.seen[key] = now
+	return false
+}
+
+func (a *SlackEventsAdapter) userEnvelop(teamId, userId, channelId string) robot.Envelop {
+	channelName := a.channelName(channelId)
+	return robot.Envelop{
+		ChannelId:   channelId,
+		ChannelName: channelName,
+		User: robot.UserEnvelop{
+			Id:          userId,
+			Name:        a.userName(userId),
+			ChannelId:   channelId,
+			ChannelName: channelName,
+		},
+		Properties: map[string]interface{}{
+			"team_id": teamId,
+		},
+	}
+}
+
+// userName give name of user from cache or from slack.
+func (a *SlackEventsAdapter) userName(userId string) string {
+	a.mutex.Lock()
+	name, ok := a.users[userId]
+	a.mutex.Unlock()
+	if ok {
+		return name
+	}
+	user, err := a.client.UsersInfo(userId)
+	if err != nil {
+		log.Errorf("Cannot get name of slack user '%s': %s", userId, err.Error())
+		return ""
+	}
+	a.mutex.Lock()
+	a.users[userId] = user.Name
+	a.mutex.Unlock()
+	return user.Name
+}
+
+// channelName give name of channel from cache or from slack, direct channels have no name.
+func (a *SlackEventsAdapter) channelName(channelId string) string {
+	a.mutex.Lock()
+	channel, ok := a.channels[channelId]
+	a.mutex.Unlock()
+	if ok {
+		return channel.Name
+	}
+	channel, err := a.client.ConversationsInfo(channelId)
+	if err != nil {
+		log.Errorf("Cannot get name of slack channel '%s': %s", channelId, err.Error())
+		return ""
+	}
+	a.mutex.Lock()
+	a.channels[channelId] = channel
+	a.mutex.Unlock()
+	return channel.Name
+}
+
+func (a SlackEventsAdapter) commandHandler(w http.ResponseWriter, req *http.Request) {
+	if a.stopper.IsStopped() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, err := a.readSignedBody(req)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		log.Error("Error with slack events adapter: " + err.Error())
+		return
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Error("Error when parsing slack command: " + err.Error())
+		return
+	}
+	if !a.stopper.Begin() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	slackapi.ServeCommand(w, a.gubot.HttpClient(), values.Get("response_url"), func() (interface{}, error) {
+		defer a.stopper.End()
+		return a.gubot.DispatchCommand(robot.SlashCommandToken{
+			CommandName: slackapi.CommandName(values),
+			AdapterName: a.Name(),
+		}, slackapi.CommandToEnvelop(values))
+	})
+}
+
+func (a SlackEventsAdapter) Format(message string) (interface{}, error) {
+	if message == "" {
+		return nil, nil
+	}
+	return slackapi.FormatCommandResponse(a.config.SlackEventsCommandResponseType, message), nil
+}
+
+// Register only log the url to set on slash command, slack apps commands are created in app settings.
+func (a SlackEventsAdapter) Register(slashCommand robot.SlashCommand) ([]robot.SlashCommandToken, error) {
+	log.Infof(
+		"Slack command '/%s' must be created in slack app settings with request url %s",
+		slashCommand.Trigger,
+		a.gubot.Host()+a.config.SlackEventsCommandEndpoint,
+	)
+	return []robot.SlashCommandToken{}, nil
+}
+
+func (a SlackEventsAdapter) Name() string {
+	return "slack_events"
+}
+
+func (a SlackEventsAdapter) Config() interface{} {
+	return SlackEventsConfig{}
+}
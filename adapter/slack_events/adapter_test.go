@@ -0,0 +1,133 @@
+package slack_events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter/slackapi"
+	"github.com/ArthurHlt/gubot/internal/testutil"
+	"github.com/ArthurHlt/gubot/robot"
+)
+
+const signingSecret = "secret"
+
+// stubSlack answer to slack web api methods used by adapter and give posted messages on a channel.
+func stubSlack(posted chan url.Values) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch strings.TrimPrefix(req.URL.Path, "/") {
+		case "auth.test":
+			w.Write([]byte(`{"ok": true, "user_id": "UBOT", "user": "gubot", "team_id": "T1"}`))
+		case "conversations.info":
+			w.Write([]byte(`{"ok": true, "channel": {"id": "C1", "name": "general"}}`))
+		case "users.info":
+			w.Write([]byte(`{"ok": true, "user": {"id": "U1", "name": "bob"}}`))
+		case "chat.postMessage":
+			posted <- req.PostForm
+			w.Write([]byte(`{"ok": true, "ts": "2.0"}`))
+		default:
+			w.Write([]byte(`{"ok": false, "error": "unknown_method"}`))
+		}
+	}))
+}
+
+func signedRequest(path string, body []byte) *http.Request {
+	req := httptest.NewRequest("POST", path, strings.NewReader(string(body)))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", slackapi.Sign(signingSecret, timestamp, body))
+	return req
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	posted := make(chan url.Values, 1)
+	server := stubSlack(posted)
+	defer server.Close()
+
+	gubot, cleanup := testutil.NewGubot(t)
+	defer cleanup()
+	err := gubot.RegisterScript(robot.Script{
+		Name:    "ping",
+		Matcher: "^ping$",
+		Type:    robot.Tsend,
+		Function: func(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
+			return []string{"pong"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adp := NewSlackEventsAdapter().(*SlackEventsAdapter)
+	gubot.RegisterAdapter(adp)
+	err = adp.Run(&SlackEventsConfig{
+		SlackEventsBotToken:      "xoxb-token",
+		SlackEventsSigningSecret: signingSecret,
+		SlackEventsApiUrl:        server.URL,
+	}, gubot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"type":     "event_callback",
+		"team_id":  "T1",
+		"event_id": "Ev1",
+		"event": map[string]interface{}{
+			"type":    "message",
+			"user":    "U1",
+			"channel": "C1",
+			"text":    "ping",
+			"ts":      "1.0",
+		},
+	})
+	recorder := httptest.NewRecorder()
+	gubot.Router().ServeHTTP(recorder, signedRequest("/slack/events", body))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected event to be acknowledged, got status %d", recorder.Code)
+	}
+	select {
+	case params := <-posted:
+		if params.Get("channel") != "C1" || params.Get("text") != "pong" {
+			t.Errorf("expected pong to be posted on C1, got %v", params)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message posted on slack")
+	}
+
+	err = adp.Stop(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder = httptest.NewRecorder()
+	gubot.Router().ServeHTTP(recorder, signedRequest("/slack/events", body))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected events to be refused after stop, got status %d", recorder.Code)
+	}
+}
+
+func TestEventsRefuseInvalidSignature(t *testing.T) {
+	adp := NewSlackEventsAdapter().(*SlackEventsAdapter)
+	adp.config = &SlackEventsConfig{SlackEventsSigningSecret: signingSecret}
+	body := []byte(`{"type": "url_verification", "challenge": "abc"}`)
+	req := signedRequest("/slack/events", body)
+	req.Header.Set("X-Slack-Signature", slackapi.Sign("other", req.Header.Get("X-Slack-Request-Timestamp"), body))
+	recorder := httptest.NewRecorder()
+	adp.eventsHandler(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	adp.eventsHandler(recorder, signedRequest("/slack/events", body))
+	if recorder.Body.String() != "abc" {
+		t.Errorf("expected challenge to be given back, got '%s'", recorder.Body.String())
+	}
+}
@@ -0,0 +1,77 @@
+package slack_events
+
+import (
+	"strings"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/adapter/slackapi"
+	"github.com/ArthurHlt/gubot/robot"
+)
+
+func (a SlackEventsAdapter) SendRich(envelop robot.Envelop, message robot.Message) error {
+	channelId, err := a.envelopChannelId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.postRichMessage(channelId, message)
+}
+
+func (a SlackEventsAdapter) ReplyRich(envelop robot.Envelop, message robot.Message) error {
+	if message.Markdown != "" {
+		message.Markdown = "<@" + envelop.User.Id + ">: " + message.Markdown
+	} else {
+		message.Text = "<@" + envelop.User.Id + ">: " + message.Text
+	}
+	if message.ThreadId == "" {
+		message.ThreadId = envelop.ThreadId
+	}
+	return a.SendRich(envelop, message)
+}
+
+func (a SlackEventsAdapter) SendDirectRich(envelop robot.Envelop, message robot.Message) error {
+	channelId, err := a.client.ConversationsOpen(envelop.User.Id)
+	if err != nil {
+		return err
+	}
+	return a.postRichMessage(channelId, message)
+}
+
+// postRichMessage upload files of message and send its text with attachments,
+// when text is too long first parts are sent as simple messages.
+func (a SlackEventsAdapter) postRichMessage(channelId string, message robot.Message) error {
+	links := make([]string, 0)
+	for _, file := range message.Files {
+		if len(file.Content) == 0 {
+			if file.Url != "" {
+				links = append(links, "<"+file.Url+"|"+file.Name+">")
+			}
+			continue
+		}
+		err := a.client.UploadFile(channelId, message.ThreadId, file.Name, file.Content)
+		if err != nil {
+			return err
+		}
+	}
+	text := message.Content()
+	if len(links) > 0 {
+		text = strings.TrimSpace(text + "\n" + strings.Join(links, "\n"))
+	}
+	messages := adapter.TruncateMessage(text, messageSizeMax)
+	for _, msg := range messages[:len(messages)-1] {
+		_, err := a.client.PostMessage(slackapi.PostMessage{
+			Channel:  channelId,
+			Text:     msg,
+			ThreadTs: message.ThreadId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := a.client.PostMessage(slackapi.PostMessage{
+		Channel:     channelId,
+		Text:        messages[len(messages)-1],
+		ThreadTs:    message.ThreadId,
+		Attachments: slackapi.MessageToAttachments(message),
+	})
+	return err
+}
@@ -0,0 +1,98 @@
+package slackapi
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ArthurHlt/gubot/robot"
+)
+
+// ActionsCallbackId is the callback id set on attachments having gubot actions.
+const ActionsCallbackId = "gubot_actions"
+
+type ActionPayload struct {
+	Type       string `json:"type"`
+	Token      string `json:"token"`
+	CallbackId string `json:"callback_id"`
+	Actions    []struct {
+		Name            string `json:"name"`
+		Type            string `json:"type"`
+		Value           string `json:"value"`
+		SelectedOptions []struct {
+			Value string `json:"value"`
+		} `json:"selected_options"`
+	} `json:"actions"`
+	Team struct {
+		Id     string `json:"id"`
+		Domain string `json:"domain"`
+	} `json:"team"`
+	Channel struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+	User struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+	MessageTs   string `json:"message_ts"`
+	ResponseUrl string `json:"response_url"`
+}
+
+type ActionResponse struct {
+	Text            string `json:"text"`
+	ResponseType    string `json:"response_type,omitempty"`
+	ReplaceOriginal bool   `json:"replace_original"`
+}
+
+// ParseActionPayload read the `payload` form value sent by slack for interactive messages.
+func ParseActionPayload(payload string) (ActionPayload, error) {
+	var actionPayload ActionPayload
+	err := json.Unmarshal([]byte(payload), &actionPayload)
+	if err != nil {
+		return ActionPayload{}, err
+	}
+	if len(actionPayload.Actions) == 0 {
+		return ActionPayload{}, errors.New("no action found in payload")
+	}
+	return actionPayload, nil
+}
+
+func (p ActionPayload) ToActionRequest() robot.ActionRequest {
+	action := p.Actions[0]
+	selected := ""
+	if len(action.SelectedOptions) > 0 {
+		selected = action.SelectedOptions[0].Value
+	}
+	envelop := robot.Envelop{
+		ChannelName: p.Channel.Name,
+		ChannelId:   p.Channel.Id,
+		MessageId:   p.MessageTs,
+		User: robot.UserEnvelop{
+			Id:          p.User.Id,
+			Name:        p.User.Name,
+			ChannelName: p.Channel.Name,
+			ChannelId:   p.Channel.Id,
+		},
+		Properties: map[string]interface{}{
+			"team_id":      p.Team.Id,
+			"response_url": p.ResponseUrl,
+		},
+	}
+	return robot.ActionRequest{
+		ActionId: action.Name,
+		Selected: selected,
+		Envelop:  envelop,
+	}
+}
+
+func FormatActionResponse(response robot.ActionResponse) ActionResponse {
+	if response.Update {
+		return ActionResponse{
+			Text:            response.Text,
+			ReplaceOriginal: true,
+		}
+	}
+	return ActionResponse{
+		Text:         response.Text,
+		ResponseType: "ephemeral",
+	}
+}
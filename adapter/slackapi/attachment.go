@@ -0,0 +1,106 @@
+package slackapi
+
+import (
+	"github.com/ArthurHlt/gubot/robot"
+)
+
+type Attachment struct {
+	CallbackId string             `json:"callback_id,omitempty"`
+	Fallback   string             `json:"fallback,omitempty"`
+	Color      string             `json:"color,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	TitleLink  string             `json:"title_link,omitempty"`
+	Text       string             `json:"text,omitempty"`
+	Fields     []AttachmentField  `json:"fields,omitempty"`
+	ImageURL   string             `json:"image_url,omitempty"`
+	Actions    []AttachmentAction `json:"actions,omitempty"`
+}
+
+type AttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type AttachmentAction struct {
+	Type    string             `json:"type"`
+	Name    string             `json:"name,omitempty"`
+	Text    string             `json:"text"`
+	Url     string             `json:"url,omitempty"`
+	Value   string             `json:"value,omitempty"`
+	Style   string             `json:"style,omitempty"`
+	Options []AttachmentOption `json:"options,omitempty"`
+}
+
+type AttachmentOption struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+// MessageToAttachments convert a rich message to slack attachments, text of the message is not included.
+func MessageToAttachments(message robot.Message) []Attachment {
+	attachments := make([]Attachment, 0)
+	main := Attachment{
+		Fallback:  message.PlainText(),
+		Color:     message.Color,
+		Title:     message.Title,
+		TitleLink: message.TitleUrl,
+	}
+	for _, field := range message.Fields {
+		main.Fields = append(main.Fields, AttachmentField{
+			Title: field.Title,
+			Value: field.Value,
+			Short: field.Short,
+		})
+	}
+	for _, button := range message.Buttons {
+		action := AttachmentAction{
+			Type:  "button",
+			Name:  button.Name,
+			Text:  button.Text,
+			Url:   button.Url,
+			Value: button.Value,
+			Style: button.Style,
+		}
+		if button.ActionId != "" {
+			// signed action id is given back by slack as action name
+			action.Name = button.ActionId
+			main.CallbackId = ActionsCallbackId
+		}
+		main.Actions = append(main.Actions, action)
+	}
+	for _, menu := range message.Menus {
+		if menu.ActionId == "" {
+			continue
+		}
+		action := AttachmentAction{
+			Type: "select",
+			Name: menu.ActionId,
+			Text: menu.Text,
+		}
+		for _, option := range menu.Options {
+			action.Options = append(action.Options, AttachmentOption{
+				Text:  option.Text,
+				Value: option.Value,
+			})
+		}
+		main.CallbackId = ActionsCallbackId
+		main.Actions = append(main.Actions, action)
+	}
+	images := message.Images
+	if len(images) > 0 {
+		main.ImageURL = images[0].Url
+		images = images[1:]
+	}
+	if main.Title != "" || main.ImageURL != "" || len(main.Fields) > 0 || len(main.Actions) > 0 {
+		attachments = append(attachments, main)
+	}
+	for _, image := range images {
+		attachments = append(attachments, Attachment{
+			Fallback: image.AltText,
+			Color:    message.Color,
+			ImageURL: image.Url,
+		})
+	}
+	return attachments
+}
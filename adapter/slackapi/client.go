@@ -0,0 +1,272 @@
+package slackapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const DEFAULT_API_URL = "https://slack.com/api"
+
+// Client call slack web api methods, BaseUrl can be changed to use a stub server.
+type Client struct {
+	Token      string
+	BaseUrl    string
+	HttpClient *http.Client
+}
+
+type Response struct {
+	Ok      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	Warning string `json:"warning,omitempty"`
+}
+
+func (r Response) Err() error {
+	if r.Ok {
+		return nil
+	}
+	if r.Error == "" {
+		return errors.New("slack api returned an unknown error")
+	}
+	return errors.New("slack api error: " + r.Error)
+}
+
+type responseWithErr interface {
+	Err() error
+}
+
+type AuthTestResponse struct {
+	Response
+	Url    string `json:"url"`
+	Team   string `json:"team"`
+	User   string `json:"user"`
+	TeamId string `json:"team_id"`
+	UserId string `json:"user_id"`
+	BotId  string `json:"bot_id"`
+}
+
+type User struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	RealName string `json:"real_name"`
+	IsBot    bool   `json:"is_bot"`
+}
+
+type Channel struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	IsIm      bool   `json:"is_im"`
+	IsPrivate bool   `json:"is_private"`
+	IsMember  bool   `json:"is_member"`
+}
+
+// PostMessage is the parameters of chat.postMessage.
+type PostMessage struct {
+	Channel     string
+	Text        string
+	ThreadTs    string
+	Attachments []Attachment
+	Username    string
+	IconUrl     string
+	IconEmoji   string
+}
+
+func NewClient(token, baseUrl string, httpClient *http.Client) *Client {
+	if baseUrl == "" {
+		baseUrl = DEFAULT_API_URL
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		Token:      token,
+		BaseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		HttpClient: httpClient,
+	}
+}
+
+// Call a web api method with form parameters and decode response in result.
+func (c Client) Call(method string, params url.Values, result responseWithErr) error {
+	return c.do(method, "application/x-www-form-urlencoded", strings.NewReader(params.Encode()), result)
+}
+
+func (c Client) do(method, contentType string, body io.Reader, result responseWithErr) error {
+	req, err := http.NewRequest("POST", c.BaseUrl+"/"+method, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack api method %s returned status %s", method, resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+func (c Client) AuthTest() (AuthTestResponse, error) {
+	var resp AuthTestResponse
+	err := c.Call("auth.test", url.Values{}, &resp)
+	return resp, err
+}
+
+// PostMessage send a message and give back its timestamp.
+func (c Client) PostMessage(msg PostMessage) (string, error) {
+	params := url.Values{}
+	params.Set("channel", msg.Channel)
+	params.Set("text", msg.Text)
+	if msg.ThreadTs != "" {
+		params.Set("thread_ts", msg.ThreadTs)
+	}
+	if len(msg.Attachments) > 0 {
+		b, err := json.Marshal(msg.Attachments)
+		if err != nil {
+			return "", err
+		}
+		params.Set("attachments", string(b))
+	}
+	if msg.Username != "" {
+		params.Set("username", msg.Username)
+	}
+	if msg.IconUrl != "" {
+		params.Set("icon_url", msg.IconUrl)
+	}
+	if msg.IconEmoji != "" {
+		params.Set("icon_emoji", msg.IconEmoji)
+	}
+	var resp struct {
+		Response
+		Ts string `json:"ts"`
+	}
+	err := c.Call("chat.postMessage", params, &resp)
+	return resp.Ts, err
+}
+
+func (c Client) PostEphemeral(channel, user, text string) error {
+	var resp Response
+	return c.Call("chat.postEphemeral", url.Values{
+		"channel": {channel},
+		"user":    {user},
+		"text":    {text},
+	}, &resp)
+}
+
+func (c Client) UsersInfo(userId string) (User, error) {
+	var resp struct {
+		Response
+		User User `json:"user"`
+	}
+	err := c.Call("users.info", url.Values{"user": {userId}}, &resp)
+	return resp.User, err
+}
+
+func (c Client) ConversationsInfo(channelId string) (Channel, error) {
+	var resp struct {
+		Response
+		Channel Channel `json:"channel"`
+	}
+	err := c.Call("conversations.info", url.Values{"channel": {channelId}}, &resp)
+	return resp.Channel, err
+}
+
+// ConversationsOpen open a direct message channel with users and give back its id.
+func (c Client) ConversationsOpen(userIds ...string) (string, error) {
+	var resp struct {
+		Response
+		Channel Channel `json:"channel"`
+	}
+	err := c.Call("conversations.open", url.Values{"users": {strings.Join(userIds, ",")}}, &resp)
+	return resp.Channel.Id, err
+}
+
+// FindConversation give the public or private channel with this name.
+func (c Client) FindConversation(name string) (Channel, error) {
+	name = strings.TrimPrefix(name, "#")
+	cursor := ""
+	for {
+		var resp struct {
+			Response
+			Channels         []Channel `json:"channels"`
+			ResponseMetadata struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"response_metadata"`
+		}
+		params := url.Values{
+			"types":            {"public_channel,private_channel"},
+			"exclude_archived": {"true"},
+			"limit":            {"200"},
+		}
+		if cursor != "" {
+			params.Set("cursor", cursor)
+		}
+		err := c.Call("conversations.list", params, &resp)
+		if err != nil {
+			return Channel{}, err
+		}
+		for _, channel := range resp.Channels {
+			if channel.Name == name {
+				return channel, nil
+			}
+		}
+		cursor = resp.ResponseMetadata.NextCursor
+		if cursor == "" {
+			return Channel{}, fmt.Errorf("channel '%s' not found", name)
+		}
+	}
+}
+
+// UploadFile upload a file in channel, in thread if threadTs is not empty.
+func (c Client) UploadFile(channel, threadTs, filename string, content []byte) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("channels", channel)
+	writer.WriteField("filename", filename)
+	if threadTs != "" {
+		writer.WriteField("thread_ts", threadTs)
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(content)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	var resp Response
+	return c.do("files.upload", writer.FormDataContentType(), body, &resp)
+}
+
+// PostResponseUrl send a delayed response to a slash command or an action.
+func PostResponseUrl(httpClient *http.Client, responseUrl string, response interface{}) error {
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(responseUrl, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("response url returned status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package slackapi
+
+import (
+	"encoding/json"
+	"github.com/ArthurHlt/gubot/robot"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ResponseInChannel = "in_channel"
+	ResponseEphemeral = "ephemeral"
+)
+
+// CommandResponse is the response to a slash command, it can also be posted later on response_url.
+type CommandResponse struct {
+	ResponseType string       `json:"response_type,omitempty"`
+	Text         string       `json:"text"`
+	Attachments  []Attachment `json:"attachments,omitempty"`
+}
+
+// CommandName give the trigger word of the slash command sent by slack without leading slash.
+func CommandName(values url.Values) string {
+	return strings.TrimPrefix(values.Get("command"), "/")
+}
+
+// CommandToEnvelop give envelop from slash command form sent by slack, response_url is kept in properties.
+func CommandToEnvelop(values url.Values) robot.Envelop {
+	return robot.Envelop{
+		Message:     strings.TrimSpace(values.Get("text")),
+		ChannelName: values.Get("channel_name"),
+		ChannelId:   values.Get("channel_id"),
+		User: robot.UserEnvelop{
+			Id:          values.Get("user_id"),
+			Name:        values.Get("user_name"),
+			ChannelName: values.Get("channel_name"),
+			ChannelId:   values.Get("channel_id"),
+		},
+		Properties: map[string]interface{}{
+			"team_id":      values.Get("team_id"),
+			"response_url": values.Get("response_url"),
+			"trigger_id":   values.Get("trigger_id"),
+		},
+	}
+}
+
+// COMMAND_RESPONSE_TIMEOUT is the time given to a slash command to answer before slack considers it failed,
+// after it the result is sent on response_url.
+const COMMAND_RESPONSE_TIMEOUT = 2500 * time.Millisecond
+
+type commandResult struct {
+	response interface{}
+	err      error
+}
+
+// ServeCommand write the result of dispatch as response of a slash command, when dispatch takes more than
+// COMMAND_RESPONSE_TIMEOUT slack is acknowledged and the result is posted later on responseUrl.
+func ServeCommand(w http.ResponseWriter, httpClient *http.Client, responseUrl string, dispatch func() (interface{}, error)) {
+	done := make(chan commandResult, 1)
+	go func() {
+		response, err := dispatch()
+		done <- commandResult{response, err}
+	}()
+	select {
+	case result := <-done:
+		if result.err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(result.err.Error()))
+			log.Error(result.err.Error())
+			return
+		}
+		if result.response == nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		b, _ := json.Marshal(result.response)
+		w.Write(b)
+	case <-time.After(COMMAND_RESPONSE_TIMEOUT):
+		w.WriteHeader(http.StatusOK)
+		go func() {
+			result := <-done
+			if result.err != nil {
+				log.Error(result.err.Error())
+				result.response = CommandResponse{
+					ResponseType: ResponseEphemeral,
+					Text:         "Error: " + result.err.Error(),
+				}
+			}
+			if result.response == nil || responseUrl == "" {
+				return
+			}
+			err := PostResponseUrl(httpClient, responseUrl, result.response)
+			if err != nil {
+				log.Error("Error when sending slash command response: " + err.Error())
+			}
+		}()
+	}
+}
+
+// FormatCommandResponse give the response of a slash command.
+func FormatCommandResponse(responseType, message string) CommandResponse {
+	return CommandResponse{
+		ResponseType: responseType,
+		Text:         message,
+	}
+}
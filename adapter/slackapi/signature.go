@@ -0,0 +1,46 @@
+package slackapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SIGNATURE_MAX_AGE is the maximum age of a signed request, older requests are considered as replay attacks.
+const SIGNATURE_MAX_AGE = 5 * time.Minute
+
+const signatureVersion = "v0"
+
+// Sign give the signature of a request body as slack computes it in header X-Slack-Signature.
+func Sign(signingSecret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte(signatureVersion + ":" + timestamp + ":"))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequest check headers X-Slack-Request-Timestamp and X-Slack-Signature of a request sent by slack
+// with its body already read.
+func VerifyRequest(signingSecret string, header http.Header, body []byte) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return errors.New("request is not signed")
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid request timestamp")
+	}
+	age := time.Since(time.Unix(ts, 0))
+	if age > SIGNATURE_MAX_AGE || age < -SIGNATURE_MAX_AGE {
+		return errors.New("request timestamp is too old")
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(signingSecret, timestamp, body))) {
+		return errors.New("invalid request signature")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package slackapi
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeader(secret, timestamp string, body []byte) http.Header {
+	header := make(http.Header)
+	header.Set("X-Slack-Request-Timestamp", timestamp)
+	header.Set("X-Slack-Signature", Sign(secret, timestamp, body))
+	return header
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := []byte("token=abc&text=hello")
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-2*SIGNATURE_MAX_AGE).Unix(), 10)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		valid  bool
+	}{
+		{"valid signature", signedHeader("secret", now, body), body, true},
+		{"signed with another secret", signedHeader("other", now, body), body, false},
+		{"body modified", signedHeader("secret", now, body), []byte("token=abc&text=bye"), false},
+		{"timestamp too old", signedHeader("secret", old, body), body, false},
+		{"invalid timestamp", signedHeader("secret", "yesterday", body), body, false},
+		{"not signed", make(http.Header), body, false},
+	}
+	for _, test := range tests {
+		err := VerifyRequest("secret", test.header, test.body)
+		if test.valid && err != nil {
+			t.Errorf("%s: expected request to be valid, got: %s", test.name, err.Error())
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected request to be refused", test.name)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// example given in slack documentation about verifying requests
+	body := []byte("token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&" +
+		"channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&" +
+		"response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&" +
+		"trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c")
+	signature := Sign("8f742231b10e8888abcd99yyyzzz85a5", "1531420618", body)
+	expected := "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+	if signature != expected {
+		t.Errorf("expected signature %s, got %s", expected, signature)
+	}
+}
@@ -0,0 +1,413 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewTeamsAdapter())
+}
+
+const messageSizeMax = 28000
+
+const (
+	conversationTypePersonal = "personal"
+	// threadSeparator separate channel id and root message id in conversation id of a channel thread.
+	threadSeparator = ";messageid="
+)
+
+type TeamsConfig struct {
+	TeamsAppId             string
+	TeamsAppPassword       string
+	TeamsTenantId          string
+	TeamsEndpoint          string `cloud-default:"/teams/messages"`
+	TeamsOpenIdMetadataUrl string
+	TeamsTokenUrl          string
+}
+
+// ConversationReference is stored for each conversation where bot received an activity,
+// it permits to send messages in this conversation later (proactive messaging).
+type ConversationReference struct {
+	ID         string `gorm:"primary_key"`
+	ServiceUrl string
+	TenantId   string
+	Type       string
+	Name       string `gorm:"index"`
+	UserId     string `gorm:"index"`
+	BotId      string
+	BotName    string
+	UpdatedAt  time.Time
+}
+
+func (ConversationReference) TableName() string {
+	return "teams_conversation_references"
+}
+
+type TeamsAdapter struct {
+	config    *TeamsConfig
+	gubot     *robot.Gubot
+	connector *connector
+	keySource KeySource
+	stopper   *adapter.Stopper
+}
+
+func NewTeamsAdapter() robot.Adapter {
+	return &TeamsAdapter{
+		stopper: adapter.NewStopper(),
+	}
+}
+
+// SetKeySource replace source of keys used to verify incoming activities, it must be called before Run.
+func (a *TeamsAdapter) SetKeySource(keySource KeySource) {
+	a.keySource = keySource
+}
+
+func (a *TeamsAdapter) Send(envelop robot.Envelop, message string) error {
+	ref, err := a.findReference(envelop)
+	if err != nil {
+		return err
+	}
+	return a.sendActivities(ref, envelop.ThreadId, "", nil, message)
+}
+
+// Reply mention user in answer of the message which triggered the reply.
+func (a *TeamsAdapter) Reply(envelop robot.Envelop, message string) error {
+	ref, err := a.findReference(envelop)
+	if err != nil {
+		return err
+	}
+	if ref.Type == conversationTypePersonal || envelop.User.Id == "" {
+		return a.sendActivities(ref, envelop.ThreadId, envelop.MessageId, nil, message)
+	}
+	mention := entity{
+		Type:      "mention",
+		Text:      "<at>" + envelop.User.Name + "</at>",
+		Mentioned: &channelAccount{Id: envelop.User.Id, Name: envelop.User.Name},
+	}
+	return a.sendActivities(ref, envelop.ThreadId, envelop.MessageId, &mention, mention.Text+" "+message)
+}
+
+// SendDirect send message in personal conversation with user, conversation is created if bot never talked with user.
+func (a *TeamsAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	if envelop.User.Id == "" {
+		return errors.New("You must provide a user id in envelop")
+	}
+	var ref ConversationReference
+	notFound := a.gubot.Store().Where(&ConversationReference{
+		Type:   conversationTypePersonal,
+		UserId: envelop.User.Id,
+	}).First(&ref).RecordNotFound()
+	if notFound {
+		var err error
+		ref, err = a.createPersonalConversation(envelop)
+		if err != nil {
+			return err
+		}
+	}
+	return a.sendActivities(ref, "", "", nil, message)
+}
+
+func (a *TeamsAdapter) createPersonalConversation(envelop robot.Envelop) (ConversationReference, error) {
+	// a known conversation is needed to find service url and tenant of user
+	source, err := a.findReference(envelop)
+	if err != nil {
+		if a.gubot.Store().Order("updated_at desc").First(&source).RecordNotFound() {
+			return source, errors.New("No conversation known, bot must have received a message before sending direct messages")
+		}
+	}
+	params := conversationParameters{
+		Bot:      channelAccount{Id: source.BotId, Name: source.BotName},
+		Members:  []channelAccount{{Id: envelop.User.Id}},
+		TenantId: source.TenantId,
+	}
+	if source.TenantId != "" {
+		params.ChannelData = map[string]interface{}{
+			"tenant": map[string]string{"id": source.TenantId},
+		}
+	}
+	conversationId, err := a.connector.createConversation(source.ServiceUrl, params)
+	if err != nil {
+		return ConversationReference{}, err
+	}
+	ref := ConversationReference{
+		ID:         conversationId,
+		ServiceUrl: source.ServiceUrl,
+		TenantId:   source.TenantId,
+		Type:       conversationTypePersonal,
+		UserId:     envelop.User.Id,
+		BotId:      source.BotId,
+		BotName:    source.BotName,
+	}
+	err = a.gubot.Store().Save(&ref).Error
+	if err != nil {
+		return ref, err
+	}
+	return ref, nil
+}
+
+func (a *TeamsAdapter) findReference(envelop robot.Envelop) (ConversationReference, error) {
+	var ref ConversationReference
+	if envelop.ChannelId != "" {
+		channelId, _ := splitConversationId(envelop.ChannelId)
+		if a.gubot.Store().Where("id = ?", channelId).First(&ref).RecordNotFound() {
+			return ref, fmt.Errorf("Conversation '%s' not found, bot must have received a message from it first", envelop.ChannelId)
+		}
+		return ref, nil
+	}
+	if envelop.ChannelName == "" {
+		return ref, errors.New("You must provide a channel name or channel id in envelop")
+	}
+	if a.gubot.Store().Where("name = ?", envelop.ChannelName).First(&ref).RecordNotFound() {
+		return ref, fmt.Errorf("Conversation '%s' not found, bot must have received a message from it first", envelop.ChannelName)
+	}
+	return ref, nil
+}
+
+// sendActivities send message in parts of teams max size, first part is a reply to replyToId if not empty.
+func (a *TeamsAdapter) sendActivities(ref ConversationReference, threadId, replyToId string, mention *entity, message string) error {
+	conversationId := ref.ID
+	if threadId != "" {
+		conversationId += threadSeparator + threadId
+	}
+	for i, msg := range adapter.TruncateMessage(message, messageSizeMax) {
+		act := activity{
+			Type:         "message",
+			From:         &channelAccount{Id: ref.BotId, Name: ref.BotName},
+			Conversation: &conversationAccount{Id: conversationId},
+			Text:         msg,
+		}
+		replyTo := ""
+		if i == 0 {
+			replyTo = replyToId
+			act.ReplyToId = replyToId
+			if mention != nil {
+				act.Entities = []entity{*mention}
+			}
+		}
+		err := a.connector.sendActivity(ref.ServiceUrl, conversationId, replyTo, act)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *TeamsAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*TeamsConfig)
+	a.gubot = gubot
+	if conf.TeamsAppId == "" {
+		return errors.New("teams_app_id config param is required")
+	}
+	if conf.TeamsAppPassword == "" {
+		return errors.New("teams_app_password config param is required")
+	}
+	if conf.TeamsEndpoint == "" {
+		conf.TeamsEndpoint = "/teams/messages"
+	}
+	if conf.TeamsTokenUrl == "" {
+		conf.TeamsTokenUrl = DEFAULT_TOKEN_URL
+		if conf.TeamsTenantId != "" {
+			conf.TeamsTokenUrl = "https://login.microsoftonline.com/" + conf.TeamsTenantId + "/oauth2/v2.0/token"
+		}
+	}
+	a.config = conf
+	if a.keySource == nil {
+		a.keySource = NewOpenIdKeySource(conf.TeamsOpenIdMetadataUrl, gubot.HttpClient())
+	}
+	a.connector = &connector{
+		tokens:     newTokenProvider(conf.TeamsTokenUrl, conf.TeamsAppId, conf.TeamsAppPassword, gubot.HttpClient()),
+		httpClient: gubot.HttpClient(),
+	}
+	err := gubot.Store().AutoMigrate(&ConversationReference{}).Error
+	if err != nil {
+		return err
+	}
+	gubot.Router().HandleFunc(conf.TeamsEndpoint, a.activityHandler).Methods("POST")
+	return nil
+}
+
+// Stop refuse new activities and wait for activities being processed.
+func (a *TeamsAdapter) Stop(ctx context.Context) error {
+	return a.stopper.Stop(ctx)
+}
+
+func (a *TeamsAdapter) activityHandler(w http.ResponseWriter, req *http.Request) {
+	if a.stopper.IsStopped() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	authorization := req.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		w.WriteHeader(http.StatusUnauthorized)
+		log.Error("Error with teams adapter: no bearer token given.")
+		return
+	}
+	var act activity
+	err := json.NewDecoder(req.Body).Decode(&act)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Error("Error when unmarshalling teams activity: " + err.Error())
+		return
+	}
+	claims, err := verifyToken(strings.TrimPrefix(authorization, "Bearer "), a.keySource, a.config.TeamsAppId, time.Now())
+	if err == nil && claims.ServiceUrl != "" && claims.ServiceUrl != act.ServiceUrl {
+		err = errors.New("Token service url doesn't match activity service url")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		log.Error("Error with teams adapter: " + err.Error())
+		return
+	}
+	if !a.stopper.Begin() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	go func() {
+		defer a.stopper.End()
+		a.handleActivity(act)
+	}()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *TeamsAdapter) handleActivity(act activity) {
+	if act.Conversation == nil || act.Recipient == nil {
+		return
+	}
+	err := a.saveReference(act)
+	if err != nil {
+		log.Error("Error when saving teams conversation reference: " + err.Error())
+	}
+	switch act.Type {
+	case "conversationUpdate":
+		for _, member := range act.MembersAdded {
+			if member.Id == act.Recipient.Id {
+				continue
+			}
+			a.gubot.Emit(robot.GubotEvent{
+				Name:    robot.EVENT_ROBOT_CHANNEL_ENTER,
+				Envelop: a.toEnvelop(act, member),
+			})
+		}
+		for _, member := range act.MembersRemoved {
+			if member.Id == act.Recipient.Id {
+				continue
+			}
+			a.gubot.Emit(robot.GubotEvent{
+				Name:    robot.EVENT_ROBOT_CHANNEL_LEAVE,
+				Envelop: a.toEnvelop(act, member),
+			})
+		}
+	case "message":
+		if act.From == nil || act.From.Id == act.Recipient.Id {
+			return
+		}
+		a.receive(act)
+	}
+}
+
+func (a *TeamsAdapter) receive(act activity) {
+	envelop := a.toEnvelop(act, *act.From)
+	envelop.MessageId = act.Id
+	mentioned := act.Conversation.ConversationType == conversationTypePersonal
+	text := act.Text
+	for _, e := range act.Entities {
+		if e.Type != "mention" || e.Mentioned == nil || e.Mentioned.Id != act.Recipient.Id {
+			continue
+		}
+		mentioned = true
+		if e.Text != "" {
+			text = strings.Replace(text, e.Text, "", -1)
+		}
+	}
+	envelop.Message = strings.TrimSpace(text)
+	envelop.NotMentioned = !mentioned
+	a.gubot.Receive(envelop)
+}
+
+func (a *TeamsAdapter) saveReference(act activity) error {
+	conversationId, _ := splitConversationId(act.Conversation.Id)
+	ref := ConversationReference{
+		ID:         conversationId,
+		ServiceUrl: act.ServiceUrl,
+		TenantId:   tenantId(act),
+		Type:       act.Conversation.ConversationType,
+		Name:       conversationName(act),
+		BotId:      act.Recipient.Id,
+		BotName:    act.Recipient.Name,
+	}
+	if ref.Type == conversationTypePersonal && act.From != nil {
+		ref.UserId = act.From.Id
+	}
+	return a.gubot.Store().Save(&ref).Error
+}
+
+func (a *TeamsAdapter) toEnvelop(act activity, from channelAccount) robot.Envelop {
+	channelId, threadId := splitConversationId(act.Conversation.Id)
+	channelName := conversationName(act)
+	envelop := robot.Envelop{
+		ChannelId:   channelId,
+		ChannelName: channelName,
+		ThreadId:    threadId,
+		User: robot.UserEnvelop{
+			Id:          from.Id,
+			Name:        from.Name,
+			ChannelId:   channelId,
+			ChannelName: channelName,
+		},
+		Properties: map[string]interface{}{
+			"conversation_type": act.Conversation.ConversationType,
+			"tenant_id":         tenantId(act),
+		},
+	}
+	if from.AadObjectId != "" {
+		envelop.Properties["aad_object_id"] = from.AadObjectId
+	}
+	return envelop
+}
+
+// splitConversationId give channel id and root message id of thread if conversation is a channel thread.
+func splitConversationId(conversationId string) (string, string) {
+	parts := strings.SplitN(conversationId, threadSeparator, 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func conversationName(act activity) string {
+	if act.Conversation.Name != "" {
+		return act.Conversation.Name
+	}
+	if act.ChannelData != nil && act.ChannelData.Channel != nil {
+		return act.ChannelData.Channel.Name
+	}
+	return ""
+}
+
+func tenantId(act activity) string {
+	if act.Conversation.TenantId != "" {
+		return act.Conversation.TenantId
+	}
+	if act.ChannelData != nil && act.ChannelData.Tenant != nil {
+		return act.ChannelData.Tenant.Id
+	}
+	return ""
+}
+
+func (a TeamsAdapter) Name() string {
+	return "teams"
+}
+
+func (a TeamsAdapter) Config() interface{} {
+	return TeamsConfig{}
+}
@@ -0,0 +1,279 @@
+package teams
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_OPENID_METADATA_URL = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+	DEFAULT_TOKEN_URL           = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+	tokenIssuer                 = "https://api.botframework.com"
+	tokenScope                  = "https://api.botframework.com/.default"
+	// clockSkew is the tolerance given on token expiration and activation dates.
+	clockSkew = 5 * time.Minute
+	// keysRefreshInterval is the minimum time between two retrievals of signing keys.
+	keysRefreshInterval = 5 * time.Minute
+)
+
+// KeySource give public keys used to sign tokens sent by the bot framework.
+// It can be replaced in adapter with SetKeySource, e.g. for testing with self-signed tokens.
+type KeySource interface {
+	PublicKey(kid string) (*rsa.PublicKey, error)
+}
+
+// OpenIdKeySource retrieve signing keys from an openid metadata document and cache them.
+type OpenIdKeySource struct {
+	metadataUrl string
+	httpClient  *http.Client
+	mutex       *sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+}
+
+func NewOpenIdKeySource(metadataUrl string, httpClient *http.Client) *OpenIdKeySource {
+	if metadataUrl == "" {
+		metadataUrl = DEFAULT_OPENID_METADATA_URL
+	}
+	return &OpenIdKeySource{
+		metadataUrl: metadataUrl,
+		httpClient:  httpClient,
+		mutex:       new(sync.Mutex),
+		keys:        make(map[string]*rsa.PublicKey),
+	}
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// PublicKey give key with id kid, keys are retrieved again when kid is unknown.
+func (s *OpenIdKeySource) PublicKey(kid string) (*rsa.PublicKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(s.fetchedAt) < keysRefreshInterval {
+		return nil, fmt.Errorf("Unknown signing key '%s'", kid)
+	}
+	err := s.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("Unknown signing key '%s'", kid)
+}
+
+func (s *OpenIdKeySource) fetchKeys() error {
+	var metadata struct {
+		JwksUri string `json:"jwks_uri"`
+	}
+	err := s.getJson(s.metadataUrl, &metadata)
+	if err != nil {
+		return err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = s.getJson(metadata.JwksUri, &jwks)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return err
+		}
+		keys[jwk.Kid] = key
+	}
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+func (s *OpenIdKeySource) getJson(url string, result interface{}) error {
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Cannot retrieve '%s': %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (k jsonWebKey) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid modulus for key '%s': %s", k.Kid, err.Error())
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid exponent for key '%s': %s", k.Kid, err.Error())
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+type tokenClaims struct {
+	Issuer     string      `json:"iss"`
+	Audience   interface{} `json:"aud"`
+	ExpiresAt  int64       `json:"exp"`
+	NotBefore  int64       `json:"nbf"`
+	ServiceUrl string      `json:"serviceurl"`
+}
+
+func (c tokenClaims) hasAudience(audience string) bool {
+	switch aud := c.Audience.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyToken check signature and claims of a token sent by the bot framework.
+func verifyToken(token string, keySource KeySource, appId string, now time.Time) (tokenClaims, error) {
+	var claims tokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("Token is malformed")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return claims, err
+	}
+	if header.Alg != "RS256" {
+		return claims, fmt.Errorf("Token algorithm '%s' is not supported", header.Alg)
+	}
+	key, err := keySource.PublicKey(header.Kid)
+	if err != nil {
+		return claims, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, errors.New("Token signature is malformed")
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature)
+	if err != nil {
+		return claims, errors.New("Token signature is not valid")
+	}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return claims, err
+	}
+	if claims.Issuer != tokenIssuer {
+		return claims, fmt.Errorf("Token issuer '%s' is not valid", claims.Issuer)
+	}
+	if !claims.hasAudience(appId) {
+		return claims, errors.New("Token audience is not valid")
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return claims, errors.New("Token has expired")
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-clockSkew)) {
+		return claims, errors.New("Token is not valid yet")
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("Token is malformed")
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return errors.New("Token is malformed")
+	}
+	return nil
+}
+
+// tokenProvider give an access token to call the bot connector, token is renewed before it expires.
+type tokenProvider struct {
+	tokenUrl    string
+	appId       string
+	appPassword string
+	httpClient  *http.Client
+	mutex       *sync.Mutex
+	token       string
+	expiresAt   time.Time
+}
+
+func newTokenProvider(tokenUrl, appId, appPassword string, httpClient *http.Client) *tokenProvider {
+	return &tokenProvider{
+		tokenUrl:    tokenUrl,
+		appId:       appId,
+		appPassword: appPassword,
+		httpClient:  httpClient,
+		mutex:       new(sync.Mutex),
+	}
+}
+
+func (p *tokenProvider) accessToken() (string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.token != "" && time.Now().Before(p.expiresAt) {
+		return p.token, nil
+	}
+	resp, err := p.httpClient.PostForm(p.tokenUrl, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {p.appId},
+		"client_secret": {p.appPassword},
+		"scope":         {tokenScope},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		ErrorDescription string `json:"error_description"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		if result.ErrorDescription != "" {
+			return "", fmt.Errorf("Cannot get bot framework token: %s", result.ErrorDescription)
+		}
+		return "", fmt.Errorf("Cannot get bot framework token: %s", resp.Status)
+	}
+	p.token = result.AccessToken
+	// token is renewed a bit before its real expiration
+	p.expiresAt = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - clockSkew)
+	return p.token, nil
+}
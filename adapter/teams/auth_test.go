@@ -0,0 +1,110 @@
+package teams
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+const testAppId = "app-id"
+
+// staticKeySource give keys generated by tests.
+type staticKeySource map[string]*rsa.PublicKey
+
+func (s staticKeySource) PublicKey(kid string) (*rsa.PublicKey, error) {
+	key, ok := s[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown signing key '%s'", kid)
+	}
+	return key, nil
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signToken create a RS256 token signed with key as the bot framework does.
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	unsigned := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":        tokenIssuer,
+		"aud":        testAppId,
+		"exp":        now.Add(time.Hour).Unix(),
+		"nbf":        now.Add(-time.Minute).Unix(),
+		"serviceurl": "https://smba.trafficmanager.net/emea/",
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySource := staticKeySource{"key1": &key.PublicKey}
+	now := time.Now()
+
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims(now)
+		claims[name] = value
+		return claims
+	}
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid token", signToken(t, key, "key1", validClaims(now)), true},
+		{"audience in a list", signToken(t, key, "key1", withClaim("aud", []string{"other", testAppId})), true},
+		{"signed with another key", signToken(t, otherKey, "key1", validClaims(now)), false},
+		{"unknown key id", signToken(t, key, "key2", validClaims(now)), false},
+		{"invalid issuer", signToken(t, key, "key1", withClaim("iss", "https://evil.example.com")), false},
+		{"invalid audience", signToken(t, key, "key1", withClaim("aud", "other")), false},
+		{"expired", signToken(t, key, "key1", withClaim("exp", now.Add(-time.Hour).Unix())), false},
+		{"not valid yet", signToken(t, key, "key1", withClaim("nbf", now.Add(time.Hour).Unix())), false},
+		{"malformed", "not-a-token", false},
+	}
+	for _, test := range tests {
+		claims, err := verifyToken(test.token, keySource, testAppId, now)
+		if test.valid && err != nil {
+			t.Errorf("%s: expected token to be valid, got: %s", test.name, err.Error())
+		}
+		if test.valid && claims.ServiceUrl != "https://smba.trafficmanager.net/emea/" {
+			t.Errorf("%s: expected service url to be read from claims, got '%s'", test.name, claims.ServiceUrl)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected token to be refused", test.name)
+		}
+	}
+}
+
+func TestVerifyTokenRefuseOtherAlgorithm(t *testing.T) {
+	claims := encodeSegment(t, validClaims(time.Now()))
+	token := encodeSegment(t, map[string]string{"alg": "none", "kid": "key1"}) + "." + claims + "."
+	_, err := verifyToken(token, staticKeySource{}, testAppId, time.Now())
+	if err == nil {
+		t.Error("expected unsigned token to be refused")
+	}
+}
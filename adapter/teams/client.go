@@ -0,0 +1,132 @@
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type channelAccount struct {
+	Id          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	AadObjectId string `json:"aadObjectId,omitempty"`
+}
+
+type conversationAccount struct {
+	Id               string `json:"id"`
+	Name             string `json:"name,omitempty"`
+	ConversationType string `json:"conversationType,omitempty"`
+	TenantId         string `json:"tenantId,omitempty"`
+	IsGroup          bool   `json:"isGroup,omitempty"`
+}
+
+type entity struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Mentioned *channelAccount `json:"mentioned,omitempty"`
+}
+
+type channelData struct {
+	Tenant *struct {
+		Id string `json:"id"`
+	} `json:"tenant,omitempty"`
+	Channel *struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel,omitempty"`
+}
+
+type activity struct {
+	Type           string               `json:"type"`
+	Id             string               `json:"id,omitempty"`
+	ServiceUrl     string               `json:"serviceUrl,omitempty"`
+	ChannelId      string               `json:"channelId,omitempty"`
+	From           *channelAccount      `json:"from,omitempty"`
+	Conversation   *conversationAccount `json:"conversation,omitempty"`
+	Recipient      *channelAccount      `json:"recipient,omitempty"`
+	Text           string               `json:"text,omitempty"`
+	TextFormat     string               `json:"textFormat,omitempty"`
+	ReplyToId      string               `json:"replyToId,omitempty"`
+	Entities       []entity             `json:"entities,omitempty"`
+	MembersAdded   []channelAccount     `json:"membersAdded,omitempty"`
+	MembersRemoved []channelAccount     `json:"membersRemoved,omitempty"`
+	ChannelData    *channelData         `json:"channelData,omitempty"`
+}
+
+type conversationParameters struct {
+	Bot         channelAccount   `json:"bot"`
+	Members     []channelAccount `json:"members"`
+	IsGroup     bool             `json:"isGroup"`
+	TenantId    string           `json:"tenantId,omitempty"`
+	ChannelData interface{}      `json:"channelData,omitempty"`
+}
+
+// connector call the bot connector api of a service url given in activities.
+type connector struct {
+	tokens     *tokenProvider
+	httpClient *http.Client
+}
+
+func (c connector) do(serviceUrl, path string, body interface{}, result interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	token, err := c.tokens.accessToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(serviceUrl, "/")+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(respBody, &apiErr)
+		if apiErr.Error.Message != "" {
+			return fmt.Errorf("bot connector error %s: %s", apiErr.Error.Code, apiErr.Error.Message)
+		}
+		return fmt.Errorf("bot connector returned status %s", resp.Status)
+	}
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+// sendActivity send activity in conversation, as a reply to replyToId if not empty.
+func (c connector) sendActivity(serviceUrl, conversationId, replyToId string, a activity) error {
+	path := "/v3/conversations/" + url.PathEscape(conversationId) + "/activities"
+	if replyToId != "" {
+		path += "/" + url.PathEscape(replyToId)
+	}
+	return c.do(serviceUrl, path, a, nil)
+}
+
+func (c connector) createConversation(serviceUrl string, params conversationParameters) (string, error) {
+	var result struct {
+		Id string `json:"id"`
+	}
+	err := c.do(serviceUrl, "/v3/conversations", params, &result)
+	return result.Id, err
+}
@@ -0,0 +1,393 @@
+package telegram
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewTelegramAdapter())
+}
+
+const messageSizeMax = 4096
+
+const (
+	retryDelay            = 5 * time.Second
+	secretTokenHeader     = "X-Telegram-Bot-Api-Secret-Token"
+	commandDescriptionMax = 256
+)
+
+var commandRegex = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+type TelegramConfig struct {
+	TelegramToken                string
+	TelegramApiUrl               string
+	TelegramWebhook              bool
+	TelegramWebhookEndpoint      string `cloud-default:"/telegram"`
+	TelegramWebhookSecret        string
+	TelegramPollTimeoutInSeconds int `cloud-default:"30"`
+}
+
+type TelegramAdapter struct {
+	client   *client
+	config   *TelegramConfig
+	gubot    *robot.Gubot
+	me       user
+	mention  *regexp.Regexp
+	mutex    *sync.Mutex
+	commands []botCommand
+	cancel   context.CancelFunc
+	stopper  *adapter.Stopper
+}
+
+func NewTelegramAdapter() robot.Adapter {
+	return &TelegramAdapter{
+		mutex:   new(sync.Mutex),
+		stopper: adapter.NewStopper(),
+	}
+}
+
+func (a *TelegramAdapter) Send(envelop robot.Envelop, message string) error {
+	chatId, err := envelopChatId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.sendMessages(chatId, envelop.ThreadId, "", message)
+}
+
+// Reply answer to the message which triggered the reply.
+func (a *TelegramAdapter) Reply(envelop robot.Envelop, message string) error {
+	chatId, err := envelopChatId(envelop)
+	if err != nil {
+		return err
+	}
+	return a.sendMessages(chatId, envelop.ThreadId, envelop.MessageId, message)
+}
+
+// SendDirect send message in private chat with user, user must have started a conversation with the bot.
+func (a *TelegramAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	if envelop.User.Id == "" {
+		return errors.New("You must provide a user id in envelop")
+	}
+	return a.sendMessages(envelop.User.Id, "", "", message)
+}
+
+func envelopChatId(envelop robot.Envelop) (string, error) {
+	if envelop.ChannelId != "" {
+		return envelop.ChannelId, nil
+	}
+	// public channels and groups can be addressed by their username
+	if strings.HasPrefix(envelop.ChannelName, "@") {
+		return envelop.ChannelName, nil
+	}
+	return "", errors.New("You must provide a channel id or a channel name starting with @ in envelop")
+}
+
+func (a *TelegramAdapter) sendMessages(chatId, threadId, replyTo, message string) error {
+	threadIdInt, _ := strconv.ParseInt(threadId, 10, 64)
+	replyToInt, _ := strconv.ParseInt(replyTo, 10, 64)
+	for i, msg := range adapter.TruncateMessage(message, messageSizeMax) {
+		params := sendMessage{
+			ChatId:          chatId,
+			Text:            msg,
+			MessageThreadId: threadIdInt,
+		}
+		if i == 0 {
+			params.ReplyToMessageId = replyToInt
+		}
+		err := a.client.sendMessage(params)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *TelegramAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*TelegramConfig)
+	a.gubot = gubot
+	if conf.TelegramToken == "" {
+		return errors.New("telegram_token config param is required")
+	}
+	if conf.TelegramWebhookEndpoint == "" {
+		conf.TelegramWebhookEndpoint = "/telegram"
+	}
+	if conf.TelegramPollTimeoutInSeconds <= 0 {
+		conf.TelegramPollTimeoutInSeconds = 30
+	}
+	// telegram can't give gubot api tokens, its secret token is the only way to authenticate webhook requests
+	if conf.TelegramWebhook && conf.TelegramWebhookSecret == "" {
+		return errors.New("telegram_webhook_secret config param is required when telegram_webhook is set")
+	}
+	a.config = conf
+	a.client = newClient(conf.TelegramApiUrl, conf.TelegramToken, gubot.HttpClient())
+	me, err := a.client.getMe()
+	if err != nil {
+		return err
+	}
+	a.me = me
+	if me.Username != "" {
+		a.mention = regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(me.Username))
+	}
+	if conf.TelegramWebhook {
+		gubot.Router().HandleFunc(conf.TelegramWebhookEndpoint, a.webhookHandler).Methods("POST")
+		return a.client.setWebhook(gubot.Host()+conf.TelegramWebhookEndpoint, conf.TelegramWebhookSecret)
+	}
+	// updates can't be polled while a webhook is set
+	err = a.client.deleteWebhook()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	if a.stopper.Begin() {
+		go a.pollLoop(ctx)
+	}
+	return nil
+}
+
+// Stop cancel polling and wait for updates being processed.
+func (a *TelegramAdapter) Stop(ctx context.Context) error {
+	if !a.stopper.Close() {
+		return nil
+	}
+	if a.cancel != nil {
+		a.cancel()
+	}
+	return a.stopper.Wait(ctx)
+}
+
+func (a *TelegramAdapter) pollLoop(ctx context.Context) {
+	defer a.stopper.End()
+	var offset int64
+	for {
+		updates, err := a.client.getUpdates(ctx, offset, a.config.TelegramPollTimeoutInSeconds)
+		if a.stopper.IsStopped() {
+			return
+		}
+		if err != nil {
+			log.Error("Error when getting telegram updates: " + err.Error())
+			select {
+			case <-a.stopper.Stopped():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateId + 1
+			a.handleUpdate(u)
+		}
+	}
+}
+
+func (a *TelegramAdapter) webhookHandler(w http.ResponseWriter, req *http.Request) {
+	if !hmac.Equal([]byte(req.Header.Get(secretTokenHeader)), []byte(a.config.TelegramWebhookSecret)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		log.Error("Error with telegram adapter: secret token given is not valid.")
+		return
+	}
+	var u update
+	err := json.NewDecoder(req.Body).Decode(&u)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Error("Error when unmarshalling telegram update: " + err.Error())
+		return
+	}
+	if !a.stopper.Begin() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	go func() {
+		defer a.stopper.End()
+		a.handleUpdate(u)
+	}()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *TelegramAdapter) handleUpdate(u update) {
+	msg := u.Message
+	if msg == nil || msg.From == nil {
+		return
+	}
+	for _, member := range msg.NewChatMembers {
+		if member.Id == a.me.Id {
+			continue
+		}
+		a.gubot.Emit(robot.GubotEvent{
+			Name:    robot.EVENT_ROBOT_CHANNEL_ENTER,
+			Envelop: a.toEnvelop(*msg, member),
+		})
+	}
+	if msg.LeftChatMember != nil && msg.LeftChatMember.Id != a.me.Id {
+		a.gubot.Emit(robot.GubotEvent{
+			Name:    robot.EVENT_ROBOT_CHANNEL_LEAVE,
+			Envelop: a.toEnvelop(*msg, *msg.LeftChatMember),
+		})
+	}
+	if msg.Text == "" || adapter.IsFromBot(strconv.FormatInt(msg.From.Id, 10), strconv.FormatInt(a.me.Id, 10), msg.From.IsBot) {
+		return
+	}
+	envelop := a.toEnvelop(*msg, *msg.From)
+	envelop.MessageId = strconv.FormatInt(msg.MessageId, 10)
+	if command, text, ok := a.parseCommand(*msg); ok {
+		if a.isSlashCommand(command) {
+			a.dispatchCommand(command, text, envelop)
+			return
+		}
+		// other commands are given to scripts as messages sent to the bot, e.g.: /help
+		envelop.Message = strings.TrimSpace(command + " " + text)
+		a.gubot.Receive(envelop)
+		return
+	}
+	mentioned := msg.Chat.Type == "private"
+	if msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil && msg.ReplyToMessage.From.Id == a.me.Id {
+		mentioned = true
+	}
+	text := msg.Text
+	if a.mention != nil && a.mention.MatchString(text) {
+		mentioned = true
+		text = a.mention.ReplaceAllString(text, "")
+		text = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(text), ":,"))
+	}
+	envelop.Message = text
+	envelop.NotMentioned = !mentioned
+	a.gubot.Receive(envelop)
+}
+
+// parseCommand give command and its text when message starts with a bot command addressed to this bot.
+func (a *TelegramAdapter) parseCommand(msg message) (string, string, bool) {
+	if len(msg.Entities) == 0 || msg.Entities[0].Type != "bot_command" || msg.Entities[0].Offset != 0 {
+		return "", "", false
+	}
+	// offsets are in utf-16 units but a command only contains ascii characters
+	length := msg.Entities[0].Length
+	if length > len(msg.Text) {
+		length = len(msg.Text)
+	}
+	command := strings.TrimPrefix(msg.Text[:length], "/")
+	if index := strings.Index(command, "@"); index != -1 {
+		if !strings.EqualFold(command[index+1:], a.me.Username) {
+			return "", "", false
+		}
+		command = command[:index]
+	}
+	return command, strings.TrimSpace(msg.Text[length:]), true
+}
+
+func (a *TelegramAdapter) isSlashCommand(command string) bool {
+	for _, slashCommand := range a.gubot.GetSlashCommands() {
+		if strings.EqualFold(slashCommand.Trigger, command) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *TelegramAdapter) dispatchCommand(command, text string, envelop robot.Envelop) {
+	for _, slashCommand := range a.gubot.GetSlashCommands() {
+		if strings.EqualFold(slashCommand.Trigger, command) {
+			command = slashCommand.Trigger
+		}
+	}
+	envelop.Message = text
+	result, err := a.gubot.DispatchCommand(robot.SlashCommandToken{
+		CommandName: command,
+		AdapterName: a.Name(),
+	}, envelop)
+	if err != nil {
+		log.Error(err.Error())
+		result = "Error: " + err.Error()
+	}
+	message, ok := result.(string)
+	if !ok || message == "" {
+		return
+	}
+	err = a.Reply(envelop, message)
+	if err != nil {
+		log.Error("Error when sending telegram command response: " + err.Error())
+	}
+}
+
+func (a *TelegramAdapter) toEnvelop(msg message, from user) robot.Envelop {
+	chatId := strconv.FormatInt(msg.Chat.Id, 10)
+	chatName := msg.Chat.Title
+	if chatName == "" {
+		chatName = msg.Chat.Username
+	}
+	name := from.Username
+	if name == "" {
+		name = from.FirstName
+	}
+	envelop := robot.Envelop{
+		ChannelId:   chatId,
+		ChannelName: chatName,
+		User: robot.UserEnvelop{
+			Id:          strconv.FormatInt(from.Id, 10),
+			Name:        name,
+			ChannelId:   chatId,
+			ChannelName: chatName,
+		},
+		Properties: map[string]interface{}{
+			"chat_type": msg.Chat.Type,
+		},
+	}
+	if msg.MessageThreadId != 0 {
+		envelop.ThreadId = strconv.FormatInt(msg.MessageThreadId, 10)
+	}
+	return envelop
+}
+
+func (a *TelegramAdapter) Format(message string) (interface{}, error) {
+	return message, nil
+}
+
+// Register set the commands menu of the bot from the list of gubot slash commands.
+func (a *TelegramAdapter) Register(slashCommand robot.SlashCommand) ([]robot.SlashCommandToken, error) {
+	commands := make([]botCommand, 0)
+	for _, cmd := range a.gubot.GetSlashCommands() {
+		name := strings.ToLower(cmd.Trigger)
+		if !commandRegex.MatchString(name) {
+			log.Warnf("Slash command '%s' can't be used on telegram, only letters, digits and underscores are allowed", cmd.Trigger)
+			continue
+		}
+		description := cmd.Description
+		if description == "" {
+			description = cmd.Title
+		}
+		description = adapter.TruncateText(description, commandDescriptionMax)
+		commands = append(commands, botCommand{Command: name, Description: description})
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if reflect.DeepEqual(commands, a.commands) {
+		return []robot.SlashCommandToken{}, nil
+	}
+	err := a.client.setMyCommands(commands)
+	if err != nil {
+		return nil, err
+	}
+	a.commands = commands
+	return []robot.SlashCommandToken{}, nil
+}
+
+func (a TelegramAdapter) Name() string {
+	return "telegram"
+}
+
+func (a TelegramAdapter) Config() interface{} {
+	return TelegramConfig{}
+}
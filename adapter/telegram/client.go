@@ -0,0 +1,158 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const DEFAULT_API_URL = "https://api.telegram.org"
+
+// client call the telegram bot api.
+type client struct {
+	apiUrl     string
+	token      string
+	httpClient *http.Client
+}
+
+type apiResponse struct {
+	Ok          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+	ErrorCode   int             `json:"error_code"`
+}
+
+type user struct {
+	Id        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username"`
+}
+
+type chat struct {
+	Id       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Username string `json:"username"`
+}
+
+type messageEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+type message struct {
+	MessageId       int64           `json:"message_id"`
+	MessageThreadId int64           `json:"message_thread_id"`
+	From            *user           `json:"from"`
+	Chat            chat            `json:"chat"`
+	Text            string          `json:"text"`
+	Entities        []messageEntity `json:"entities"`
+	ReplyToMessage  *struct {
+		From *user `json:"from"`
+	} `json:"reply_to_message"`
+	NewChatMembers []user `json:"new_chat_members"`
+	LeftChatMember *user  `json:"left_chat_member"`
+}
+
+type update struct {
+	UpdateId int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+type sendMessage struct {
+	ChatId           string `json:"chat_id"`
+	Text             string `json:"text"`
+	MessageThreadId  int64  `json:"message_thread_id,omitempty"`
+	ReplyToMessageId int64  `json:"reply_to_message_id,omitempty"`
+}
+
+type botCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+func newClient(apiUrl, token string, httpClient *http.Client) *client {
+	if apiUrl == "" {
+		apiUrl = DEFAULT_API_URL
+	}
+	return &client{
+		apiUrl:     strings.TrimSuffix(apiUrl, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+func (c client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.apiUrl+"/bot"+c.token+"/"+method, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// error contains url with token
+		return errors.New(strings.Replace(err.Error(), c.token, "<token>", -1))
+	}
+	defer resp.Body.Close()
+	var apiResp apiResponse
+	err = json.NewDecoder(resp.Body).Decode(&apiResp)
+	if err != nil {
+		return fmt.Errorf("telegram api method %s returned status %s", method, resp.Status)
+	}
+	if !apiResp.Ok {
+		return fmt.Errorf("telegram api error %d: %s", apiResp.ErrorCode, apiResp.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(apiResp.Result, result)
+}
+
+func (c client) getMe() (user, error) {
+	var me user
+	err := c.call(context.Background(), "getMe", struct{}{}, &me)
+	return me, err
+}
+
+func (c client) getUpdates(ctx context.Context, offset int64, timeout int) ([]update, error) {
+	var updates []update
+	err := c.call(ctx, "getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         timeout,
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+func (c client) sendMessage(params sendMessage) error {
+	return c.call(context.Background(), "sendMessage", params, nil)
+}
+
+func (c client) setWebhook(url, secretToken string) error {
+	return c.call(context.Background(), "setWebhook", map[string]interface{}{
+		"url":             url,
+		"secret_token":    secretToken,
+		"allowed_updates": []string{"message"},
+	}, nil)
+}
+
+func (c client) deleteWebhook() error {
+	return c.call(context.Background(), "deleteWebhook", struct{}{}, nil)
+}
+
+func (c client) setMyCommands(commands []botCommand) error {
+	return c.call(context.Background(), "setMyCommands", map[string]interface{}{
+		"commands": commands,
+	}, nil)
+}
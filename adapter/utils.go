@@ -1,6 +1,15 @@
 package adapter
 
-import "strings"
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
 
 func TruncateMessage(message string, sizeMax int) []string {
 	message = strings.TrimSpace(message)
@@ -20,3 +29,121 @@ func TruncateMessage(message string, sizeMax int) []string {
 
 	return allMessages
 }
+
+// TruncateText cut text to sizeMax characters, multi-byte characters are never split.
+func TruncateText(text string, sizeMax int) string {
+	runes := []rune(text)
+	if len(runes) <= sizeMax {
+		return text
+	}
+	return string(runes[:sizeMax])
+}
+
+// Stopper stop an adapter gracefully, tasks begun before Stop are waited for and no task can begin after it.
+type Stopper struct {
+	stop  chan struct{}
+	mutex *sync.Mutex
+	wg    *sync.WaitGroup
+}
+
+func NewStopper() *Stopper {
+	return &Stopper{
+		stop:  make(chan struct{}),
+		mutex: new(sync.Mutex),
+		wg:    new(sync.WaitGroup),
+	}
+}
+
+// Stopped give a channel closed when adapter is stopped.
+func (s *Stopper) Stopped() <-chan struct{} {
+	return s.stop
+}
+
+func (s *Stopper) IsStopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Begin register a task, it returns false when adapter is stopped and task must not be run.
+// End must be called when a begun task is finished.
+func (s *Stopper) Begin() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.IsStopped() {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Stopper) End() {
+	s.wg.Done()
+}
+
+// Close prevent tasks to begin without waiting for running ones,
+// it returns false when adapter was already stopped.
+func (s *Stopper) Close() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.IsStopped() {
+		return false
+	}
+	close(s.stop)
+	return true
+}
+
+// Wait wait for running tasks until ctx is done.
+func (s *Stopper) Wait(ctx context.Context) error {
+	return Wait(ctx, s.wg)
+}
+
+// Stop prevent tasks to begin and wait for running ones until ctx is done.
+func (s *Stopper) Stop(ctx context.Context) error {
+	s.Close()
+	return s.Wait(ctx)
+}
+
+// Wait wait for wg until ctx is done.
+func Wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TlsConfig give a tls config for serverName which skip verification when httpClient does.
+func TlsConfig(httpClient *http.Client, serverName string) *tls.Config {
+	config := &tls.Config{
+		ServerName: serverName,
+	}
+	transport, ok := httpClient.Transport.(*http.Transport)
+	if ok && transport.TLSClientConfig != nil {
+		config.InsecureSkipVerify = transport.TLSClientConfig.InsecureSkipVerify
+	}
+	return config
+}
+
+// IsFromBot tell if a message must be ignored because it was sent by a bot, we don't talk with other bots.
+func IsFromBot(senderId, botId string, senderIsBot bool) bool {
+	return senderIsBot || senderId == botId
+}
+
+// WebsocketDialer give a websocket dialer using proxy from environment and tls verification of httpClient.
+func WebsocketDialer(httpClient *http.Client) *websocket.Dialer {
+	return &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 30 * time.Second,
+		TLSClientConfig:  TlsConfig(httpClient, ""),
+	}
+}
@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	"github.com/hashicorp/go-multierror"
+	"github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewWebhookAdapter())
+}
+
+// signatureMaxAge is the maximum age of a signed incoming request, it prevents replay attacks.
+const signatureMaxAge = 5 * time.Minute
+
+// queueSize is the number of messages waiting to be posted on an outgoing webhook before sending blocks.
+const queueSize = 100
+
+type WebhookConfig struct {
+	WebhookOutgoings      []OutgoingWebhook
+	WebhookEndpoint       string `cloud-default:"/webhook"`
+	WebhookSecret         string
+	WebhookMaxRetries     int `cloud-default:"3"`
+	WebhookRetryDelayInMs int `cloud-default:"1000"`
+}
+
+type delivery struct {
+	event string
+	body  []byte
+}
+
+type WebhookAdapter struct {
+	config      *WebhookConfig
+	gubot       *robot.Gubot
+	outgoings   []*outgoing
+	queues      []chan delivery
+	queuesMutex *sync.RWMutex
+	closed      bool
+	workers     *sync.WaitGroup
+	stopper     *adapter.Stopper
+}
+
+func NewWebhookAdapter() robot.Adapter {
+	return &WebhookAdapter{
+		queuesMutex: new(sync.RWMutex),
+		workers:     new(sync.WaitGroup),
+		stopper:     adapter.NewStopper(),
+	}
+}
+
+func (a *WebhookAdapter) Send(envelop robot.Envelop, message string) error {
+	return a.deliver(EVENT_SEND, envelop, message)
+}
+
+func (a *WebhookAdapter) Reply(envelop robot.Envelop, message string) error {
+	return a.deliver(EVENT_REPLY, envelop, message)
+}
+
+func (a *WebhookAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	return a.deliver(EVENT_SEND_DIRECT, envelop, message)
+}
+
+// deliver queue message for every outgoing webhook listening event, messages are posted in background
+// in the order they were sent.
+func (a *WebhookAdapter) deliver(event string, envelop robot.Envelop, message string) error {
+	a.queuesMutex.RLock()
+	defer a.queuesMutex.RUnlock()
+	if a.closed {
+		return errors.New("Webhook adapter is stopped, message can't be delivered")
+	}
+	payload := Payload{
+		Event:   event,
+		Message: message,
+		Envelop: envelop,
+	}
+	var result error
+	for i, out := range a.outgoings {
+		if !out.accept(event) {
+			continue
+		}
+		body, err := out.body(payload)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		a.queues[i] <- delivery{event: event, body: body}
+	}
+	return result
+}
+
+// postLoop post messages queued for an outgoing webhook until its queue is closed.
+func (a *WebhookAdapter) postLoop(out *outgoing, queue chan delivery) {
+	defer a.workers.Done()
+	for d := range queue {
+		err := a.postWithRetry(out, d.event, d.body)
+		if err != nil {
+			log.Error("Error when posting on outgoing webhook: " + err.Error())
+		}
+	}
+}
+
+// postWithRetry post body and retry with an exponential delay when error is temporary.
+func (a *WebhookAdapter) postWithRetry(out *outgoing, event string, body []byte) error {
+	deliveryId := uuid.NewV4().String()
+	delay := time.Duration(a.config.WebhookRetryDelayInMs) * time.Millisecond
+	for i := 0; ; i++ {
+		err := out.post(a.gubot.HttpClient(), event, deliveryId, body)
+		if _, ok := err.(retryableError); !ok || i >= a.config.WebhookMaxRetries {
+			return err
+		}
+		log.Warnf("Error when posting on outgoing webhook, retrying in %s: %s", delay, err.Error())
+		select {
+		case <-a.stopper.Stopped():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (a *WebhookAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*WebhookConfig)
+	a.gubot = gubot
+	if conf.WebhookEndpoint == "" {
+		conf.WebhookEndpoint = "/webhook"
+	}
+	if conf.WebhookMaxRetries < 0 {
+		conf.WebhookMaxRetries = 0
+	}
+	a.config = conf
+	a.outgoings = make([]*outgoing, 0)
+	for _, webhook := range conf.WebhookOutgoings {
+		out, err := newOutgoing(webhook)
+		if err != nil {
+			return err
+		}
+		a.outgoings = append(a.outgoings, out)
+	}
+	a.queues = make([]chan delivery, len(a.outgoings))
+	for i, out := range a.outgoings {
+		a.queues[i] = make(chan delivery, queueSize)
+		a.workers.Add(1)
+		go a.postLoop(out, a.queues[i])
+	}
+	var handler http.Handler = http.HandlerFunc(a.incomingHandler)
+	if conf.WebhookSecret == "" {
+		// without secret, incoming requests must give a gubot token like on default routes
+		handler = gubot.ApiAuthMatcher()(handler)
+	}
+	gubot.Router().Handle(conf.WebhookEndpoint, handler).Methods("POST")
+	return nil
+}
+
+// Stop refuse new incoming messages, wait for incoming messages being processed and then for queued messages
+// to be posted, pending retries are cancelled.
+func (a *WebhookAdapter) Stop(ctx context.Context) error {
+	err := a.stopper.Stop(ctx)
+	if err != nil {
+		return err
+	}
+	a.queuesMutex.Lock()
+	if !a.closed {
+		a.closed = true
+		for _, queue := range a.queues {
+			close(queue)
+		}
+	}
+	a.queuesMutex.Unlock()
+	return adapter.Wait(ctx, a.workers)
+}
+
+func (a *WebhookAdapter) verifySignature(req *http.Request, body []byte) error {
+	timestamp := req.Header.Get(headerTimestamp)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("timestamp is missing or invalid")
+	}
+	age := time.Since(time.Unix(ts, 0))
+	if age > signatureMaxAge || age < -signatureMaxAge {
+		return errors.New("timestamp is too old")
+	}
+	expected := sign(a.config.WebhookSecret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(req.Header.Get(headerSignature))) {
+		return errors.New("signature is not valid")
+	}
+	return nil
+}
+
+// incomingHandler receive an envelop in json, message is dispatched to scripts like messages from other adapters.
+func (a *WebhookAdapter) incomingHandler(w http.ResponseWriter, req *http.Request) {
+	if a.stopper.IsStopped() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if a.config.WebhookSecret != "" {
+		err = a.verifySignature(req, body)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			log.Error("Error with webhook adapter: " + err.Error())
+			return
+		}
+	}
+	var envelop robot.Envelop
+	err = json.Unmarshal(body, &envelop)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if envelop.Message == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("message is required"))
+		return
+	}
+	if !a.stopper.Begin() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	go func() {
+		defer a.stopper.End()
+		a.gubot.Receive(envelop)
+	}()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a WebhookAdapter) Name() string {
+	return "webhook"
+}
+
+func (a WebhookAdapter) Config() interface{} {
+	return WebhookConfig{}
+}
@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/ArthurHlt/gubot/robot"
+)
+
+const (
+	EVENT_SEND        = "send"
+	EVENT_REPLY       = "reply"
+	EVENT_SEND_DIRECT = "send_direct"
+)
+
+const (
+	headerEvent     = "X-Gubot-Event"
+	headerDelivery  = "X-Gubot-Delivery"
+	headerTimestamp = "X-Gubot-Timestamp"
+	headerSignature = "X-Gubot-Signature"
+)
+
+// OutgoingWebhook is an url where messages sent by gubot are posted.
+type OutgoingWebhook struct {
+	// Url where payloads are posted
+	Url string
+	// Secret used to sign payloads, signature is not sent if empty
+	Secret string
+	// Events is the list of events sent to this url (send, reply or send_direct), all events are sent if empty
+	Events []string
+	// Templates are json body templates by event, default payload is used for events without template
+	Templates map[string]string
+	// Headers are added to each request
+	Headers map[string]string
+}
+
+// Payload is the data given to templates and the default json body.
+type Payload struct {
+	Event   string        `json:"event"`
+	Message string        `json:"message"`
+	Envelop robot.Envelop `json:"envelop"`
+}
+
+var templateFuncs = template.FuncMap{
+	// json permits to write a value in json body, e.g.: {"text": {{ json .Message }}}
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// outgoing is an OutgoingWebhook with its templates parsed.
+type outgoing struct {
+	OutgoingWebhook
+	templates map[string]*template.Template
+}
+
+func newOutgoing(webhook OutgoingWebhook) (*outgoing, error) {
+	if webhook.Url == "" {
+		return nil, fmt.Errorf("url is required on outgoing webhooks")
+	}
+	out := &outgoing{
+		OutgoingWebhook: webhook,
+		templates:       make(map[string]*template.Template),
+	}
+	for event, tplText := range webhook.Templates {
+		tpl, err := template.New(event).Funcs(templateFuncs).Parse(tplText)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid template for event '%s' on outgoing webhook '%s': %s", event, webhook.Url, err.Error())
+		}
+		out.templates[event] = tpl
+	}
+	return out, nil
+}
+
+func (o outgoing) accept(event string) bool {
+	if len(o.Events) == 0 {
+		return true
+	}
+	for _, e := range o.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// body give json to send for payload, from template of the event if exists.
+func (o outgoing) body(payload Payload) ([]byte, error) {
+	tpl, ok := o.templates[payload.Event]
+	if !ok {
+		return json.Marshal(payload)
+	}
+	buf := &bytes.Buffer{}
+	err := tpl.Execute(buf, payload)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("Template for event '%s' on outgoing webhook '%s' doesn't give a valid json", payload.Event, o.Url)
+	}
+	return buf.Bytes(), nil
+}
+
+// sign give signature of body at timestamp, it is the hex encoded hmac sha256 of "<timestamp>.<body>".
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryableError is an error after which request can be sent again.
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (o outgoing) post(httpClient *http.Client, event, deliveryId string, body []byte) error {
+	req, err := http.NewRequest("POST", o.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range o.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set(headerEvent, event)
+	req.Header.Set(headerDelivery, deliveryId)
+	if o.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(headerTimestamp, timestamp)
+		req.Header.Set(headerSignature, sign(o.Secret, timestamp, body))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return retryableError{err}
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("Outgoing webhook '%s' returned status %s", o.Url, resp.Status)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return retryableError{err}
+	}
+	return err
+}
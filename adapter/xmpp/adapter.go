@@ -0,0 +1,520 @@
+package xmpp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArthurHlt/gubot/adapter"
+	"github.com/ArthurHlt/gubot/robot"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	robot.RegisterAdapter(NewXmppAdapter())
+}
+
+const (
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 5 * time.Minute
+)
+
+type XmppConfig struct {
+	XmppJid                   string
+	XmppPassword              string
+	XmppServer                string
+	XmppDirectTls             bool
+	XmppInsecure              bool
+	XmppNick                  string
+	XmppRooms                 []string
+	XmppAcceptSubscriptions   bool
+	XmppPingIntervalInSeconds int `cloud-default:"60"`
+}
+
+// jid is a parsed xmpp address local@domain/resource.
+type jid struct {
+	local    string
+	domain   string
+	resource string
+}
+
+func parseJid(s string) jid {
+	var j jid
+	if i := strings.Index(s, "/"); i != -1 {
+		j.resource = s[i+1:]
+		s = s[:i]
+	}
+	if i := strings.Index(s, "@"); i != -1 {
+		j.local = s[:i]
+		s = s[i+1:]
+	}
+	j.domain = s
+	return j
+}
+
+func (j jid) bare() string {
+	if j.local == "" {
+		return j.domain
+	}
+	return j.local + "@" + j.domain
+}
+
+type room struct {
+	nick      string
+	joined    bool
+	occupants map[string]string
+}
+
+type XmppAdapter struct {
+	config   *XmppConfig
+	gubot    *robot.Gubot
+	jid      jid
+	mutex    *sync.Mutex
+	stream   *stream
+	rooms    map[string]*room
+	contacts map[string]bool
+	stopper  *adapter.Stopper
+}
+
+func NewXmppAdapter() robot.Adapter {
+	return &XmppAdapter{
+		mutex:    new(sync.Mutex),
+		rooms:    make(map[string]*room),
+		contacts: make(map[string]bool),
+		stopper:  adapter.NewStopper(),
+	}
+}
+
+func (a *XmppAdapter) Send(envelop robot.Envelop, message string) error {
+	to := envelop.ChannelId
+	if to == "" {
+		to = envelop.ChannelName
+	}
+	if to == "" {
+		return errors.New("You must provide a channel name or channel id in envelop")
+	}
+	if a.isRoom(to) {
+		return a.sendMessage(to, "groupchat", message)
+	}
+	return a.sendMessage(to, "chat", message)
+}
+
+// Reply mention user in rooms and answer in direct chat otherwise.
+func (a *XmppAdapter) Reply(envelop robot.Envelop, message string) error {
+	to := envelop.ChannelId
+	if to == "" {
+		to = envelop.ChannelName
+	}
+	if a.isRoom(to) {
+		return a.sendMessage(to, "groupchat", envelop.User.Name+": "+message)
+	}
+	return a.SendDirect(envelop, message)
+}
+
+// SendDirect send a chat message to user, it is a private message in room when user id is an occupant address.
+func (a *XmppAdapter) SendDirect(envelop robot.Envelop, message string) error {
+	if envelop.User.Id == "" {
+		return errors.New("You must provide a user id in envelop")
+	}
+	return a.sendMessage(envelop.User.Id, "chat", message)
+}
+
+func (a *XmppAdapter) isRoom(address string) bool {
+	if strings.Contains(address, "/") {
+		return false
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	_, ok := a.rooms[address]
+	return ok
+}
+
+func (a *XmppAdapter) currentStream() (*stream, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.stream == nil {
+		return nil, errors.New("xmpp adapter is not connected")
+	}
+	return a.stream, nil
+}
+
+func (a *XmppAdapter) sendMessage(to, messageType, body string) error {
+	s, err := a.currentStream()
+	if err != nil {
+		return err
+	}
+	return s.write(fmt.Sprintf(`<message to="%s" type="%s" id="%s"><body>%s</body></message>`,
+		escape(to), messageType, s.nextId(), escape(body)))
+}
+
+func (a *XmppAdapter) Run(config interface{}, gubot *robot.Gubot) error {
+	conf := config.(*XmppConfig)
+	a.gubot = gubot
+	if conf.XmppJid == "" {
+		return errors.New("xmpp_jid config param is required")
+	}
+	if conf.XmppPassword == "" {
+		return errors.New("xmpp_password config param is required")
+	}
+	a.jid = parseJid(conf.XmppJid)
+	if a.jid.local == "" {
+		return errors.New("xmpp_jid config param must be in the form user@domain")
+	}
+	if a.jid.resource == "" {
+		a.jid.resource = "gubot"
+	}
+	if conf.XmppNick == "" {
+		conf.XmppNick = gubot.Name()
+	}
+	if conf.XmppPingIntervalInSeconds <= 0 {
+		conf.XmppPingIntervalInSeconds = 60
+	}
+	a.config = conf
+	for _, roomJid := range conf.XmppRooms {
+		a.rooms[parseJid(roomJid).bare()] = &room{
+			nick:      conf.XmppNick,
+			occupants: make(map[string]string),
+		}
+	}
+	go a.connectLoop()
+	return nil
+}
+
+func (a *XmppAdapter) Stop(ctx context.Context) error {
+	if !a.stopper.Close() {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.stream != nil {
+		return a.stream.close()
+	}
+	return nil
+}
+
+// connectLoop keep a session opened, it reconnects with an exponential delay when session is lost.
+func (a *XmppAdapter) connectLoop() {
+	delay := minReconnectDelay
+	for {
+		established, err := a.session()
+		if a.stopper.IsStopped() {
+			return
+		}
+		if established {
+			delay = minReconnectDelay
+		}
+		log.Errorf("Xmpp connection lost, reconnecting in %s: %s", delay, err.Error())
+		select {
+		case <-a.stopper.Stopped():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func (a *XmppAdapter) tlsConfig() *tls.Config {
+	serverName := a.jid.domain
+	if a.config.XmppServer != "" {
+		serverName = strings.Split(a.config.XmppServer, ":")[0]
+	}
+	return adapter.TlsConfig(a.gubot.HttpClient(), serverName)
+}
+
+// session connect, join rooms and read stanzas until connection is lost.
+func (a *XmppAdapter) session() (bool, error) {
+	s, err := dialStream(streamConfig{
+		jid:       a.jid,
+		password:  a.config.XmppPassword,
+		server:    a.config.XmppServer,
+		directTls: a.config.XmppDirectTls,
+		insecure:  a.config.XmppInsecure,
+		tlsConfig: a.tlsConfig(),
+		resource:  a.jid.resource,
+	})
+	if err != nil {
+		return false, err
+	}
+	a.mutex.Lock()
+	a.stream = s
+	a.mutex.Unlock()
+	defer func() {
+		a.mutex.Lock()
+		a.stream = nil
+		for _, r := range a.rooms {
+			r.joined = false
+			r.occupants = make(map[string]string)
+		}
+		a.contacts = make(map[string]bool)
+		a.mutex.Unlock()
+		s.close()
+	}()
+	if a.stopper.IsStopped() {
+		return true, errors.New("xmpp adapter is stopped")
+	}
+	log.Infof("Connected on xmpp as %s", s.jid)
+
+	err = s.write("<presence/>")
+	if err != nil {
+		return true, err
+	}
+	a.mutex.Lock()
+	for roomJid, r := range a.rooms {
+		// history is not requested, old messages would trigger scripts again
+		err = s.write(fmt.Sprintf(`<presence to="%s"><x xmlns="%s"><history maxstanzas="0"/></x></presence>`,
+			escape(roomJid+"/"+r.nick), nsMuc))
+		if err != nil {
+			break
+		}
+	}
+	a.mutex.Unlock()
+	if err != nil {
+		return true, err
+	}
+
+	interval := time.Duration(a.config.XmppPingIntervalInSeconds) * time.Second
+	closed := make(chan struct{})
+	defer close(closed)
+	go a.ping(s, interval, closed)
+	for {
+		// server answers to pings, connection is considered lost without any data after two intervals
+		s.conn.SetReadDeadline(time.Now().Add(2 * interval))
+		start, err := s.next()
+		if err != nil {
+			return true, err
+		}
+		switch start.Name.Local {
+		case "message":
+			var m message
+			if err := s.decoder.DecodeElement(&m, &start); err == nil {
+				go a.handleMessage(m)
+			}
+		case "presence":
+			var p presence
+			if err := s.decoder.DecodeElement(&p, &start); err == nil {
+				a.handlePresence(s, p)
+			}
+		case "iq":
+			var i iq
+			if err := s.decoder.DecodeElement(&i, &start); err == nil {
+				a.handleIq(s, i)
+			}
+		default:
+			s.decoder.Skip()
+		}
+	}
+}
+
+func (a *XmppAdapter) ping(s *stream, interval time.Duration, closed chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			s.write(fmt.Sprintf(`<iq type="get" to="%s" id="%s"><ping xmlns="%s"/></iq>`, escape(a.jid.domain), s.nextId(), nsPing))
+		}
+	}
+}
+
+func (a *XmppAdapter) handleIq(s *stream, i iq) {
+	if i.Type != "get" && i.Type != "set" {
+		return
+	}
+	if i.Ping != nil {
+		s.write(fmt.Sprintf(`<iq type="result" to="%s" id="%s"/>`, escape(i.From), escape(i.Id)))
+		return
+	}
+	s.write(fmt.Sprintf(`<iq type="error" to="%s" id="%s"><error type="cancel"><service-unavailable xmlns="urn:ietf:params:xml:ns:xmpp-stanzas"/></error></iq>`,
+		escape(i.From), escape(i.Id)))
+}
+
+func (a *XmppAdapter) handlePresence(s *stream, p presence) {
+	from := parseJid(p.From)
+	switch p.Type {
+	case "subscribe":
+		if a.config.XmppAcceptSubscriptions {
+			s.write(fmt.Sprintf(`<presence to="%s" type="subscribed"/>`, escape(from.bare())))
+			s.write(fmt.Sprintf(`<presence to="%s" type="subscribe"/>`, escape(from.bare())))
+		}
+		return
+	case "error":
+		log.Errorf("Xmpp presence error from '%s'", p.From)
+		return
+	case "", "unavailable":
+	default:
+		return
+	}
+	a.mutex.Lock()
+	r, isRoom := a.rooms[from.bare()]
+	a.mutex.Unlock()
+	if isRoom {
+		a.handleRoomPresence(r, from, p)
+		return
+	}
+	if from.bare() == a.jid.bare() {
+		return
+	}
+	online := p.Type != "unavailable"
+	a.mutex.Lock()
+	wasOnline := a.contacts[from.bare()]
+	a.contacts[from.bare()] = online
+	a.mutex.Unlock()
+	if online == wasOnline {
+		return
+	}
+	event := robot.EVENT_ROBOT_USER_OFFLINE
+	if online {
+		event = robot.EVENT_ROBOT_USER_ONLINE
+	}
+	a.gubot.Emit(robot.GubotEvent{
+		Name: event,
+		Envelop: robot.Envelop{
+			ChannelId:   from.bare(),
+			ChannelName: from.bare(),
+			User: robot.UserEnvelop{
+				Id:          from.bare(),
+				Name:        from.local,
+				ChannelId:   from.bare(),
+				ChannelName: from.bare(),
+			},
+			Properties: map[string]interface{}{
+				"show":   p.Show,
+				"status": p.Status,
+			},
+		},
+	})
+}
+
+// handleRoomPresence track occupants of room, enter and leave events are emitted only once room is joined.
+func (a *XmppAdapter) handleRoomPresence(r *room, from jid, p presence) {
+	if p.MucUser == nil || from.resource == "" {
+		return
+	}
+	nick := from.resource
+	realJid := ""
+	if len(p.MucUser.Items) > 0 {
+		realJid = parseJid(p.MucUser.Items[0].Jid).bare()
+	}
+	a.mutex.Lock()
+	if p.MucUser.hasStatus("110") {
+		// presence of the bot itself, nick may have been changed by the room
+		r.joined = p.Type != "unavailable"
+		r.nick = nick
+		a.mutex.Unlock()
+		if r.joined {
+			log.Infof("Joined xmpp room %s as %s", from.bare(), nick)
+		}
+		return
+	}
+	_, known := r.occupants[nick]
+	joined := r.joined
+	if p.Type == "unavailable" {
+		delete(r.occupants, nick)
+	} else {
+		r.occupants[nick] = realJid
+	}
+	a.mutex.Unlock()
+	if !joined {
+		return
+	}
+	envelop := robot.Envelop{
+		ChannelId:   from.bare(),
+		ChannelName: from.bare(),
+		User: robot.UserEnvelop{
+			Id:          p.From,
+			Name:        nick,
+			ChannelId:   from.bare(),
+			ChannelName: from.bare(),
+		},
+		Properties: map[string]interface{}{
+			"real_jid": realJid,
+		},
+	}
+	if p.Type == "unavailable" {
+		a.gubot.Emit(robot.GubotEvent{Name: robot.EVENT_ROBOT_CHANNEL_LEAVE, Envelop: envelop})
+		return
+	}
+	if !known {
+		a.gubot.Emit(robot.GubotEvent{Name: robot.EVENT_ROBOT_CHANNEL_ENTER, Envelop: envelop})
+	}
+}
+
+func (a *XmppAdapter) handleMessage(m message) {
+	// delayed messages are history or offline messages which have already been answered or are outdated
+	if m.Type == "error" || m.Body == "" || m.Delay != nil {
+		return
+	}
+	from := parseJid(m.From)
+	a.mutex.Lock()
+	r, isRoom := a.rooms[from.bare()]
+	var nick, realJid string
+	if isRoom {
+		nick = r.nick
+		realJid = r.occupants[from.resource]
+	}
+	a.mutex.Unlock()
+
+	envelop := robot.Envelop{
+		Message:    strings.TrimSpace(m.Body),
+		MessageId:  m.Id,
+		Properties: make(map[string]interface{}),
+	}
+	if realJid != "" {
+		envelop.Properties["real_jid"] = realJid
+	}
+	switch {
+	case isRoom && m.Type == "groupchat":
+		// messages from room itself or from the bot are ignored
+		if from.resource == "" || from.resource == nick {
+			return
+		}
+		envelop.ChannelId = from.bare()
+		envelop.ChannelName = from.bare()
+		envelop.User = robot.UserEnvelop{
+			Id:   m.From,
+			Name: from.resource,
+		}
+		mention := regexp.MustCompile(`(?i)^\s*@?` + regexp.QuoteMeta(nick) + `\s*[:,]?\s*`)
+		if mention.MatchString(envelop.Message) {
+			envelop.Message = mention.ReplaceAllString(envelop.Message, "")
+		} else if !strings.Contains(strings.ToLower(envelop.Message), strings.ToLower(nick)) {
+			envelop.NotMentioned = true
+		}
+	case isRoom:
+		// private message from a room occupant
+		envelop.ChannelId = m.From
+		envelop.ChannelName = m.From
+		envelop.User = robot.UserEnvelop{
+			Id:   m.From,
+			Name: from.resource,
+		}
+	default:
+		envelop.ChannelId = from.bare()
+		envelop.ChannelName = from.bare()
+		envelop.User = robot.UserEnvelop{
+			Id:   from.bare(),
+			Name: from.local,
+		}
+	}
+	envelop.User.ChannelId = envelop.ChannelId
+	envelop.User.ChannelName = envelop.ChannelName
+	a.gubot.Receive(envelop)
+}
+
+func (a XmppAdapter) Name() string {
+	return "xmpp"
+}
+
+func (a XmppAdapter) Config() interface{} {
+	return XmppConfig{}
+}
@@ -0,0 +1,333 @@
+package xmpp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	nsClient  = "jabber:client"
+	nsStream  = "http://etherx.jabber.org/streams"
+	nsTls     = "urn:ietf:params:xml:ns:xmpp-tls"
+	nsSasl    = "urn:ietf:params:xml:ns:xmpp-sasl"
+	nsBind    = "urn:ietf:params:xml:ns:xmpp-bind"
+	nsSession = "urn:ietf:params:xml:ns:xmpp-session"
+	nsMuc     = "http://jabber.org/protocol/muc"
+	nsPing    = "urn:xmpp:ping"
+)
+
+const dialTimeout = 30 * time.Second
+
+type streamFeatures struct {
+	StartTls   *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms []string  `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms>mechanism"`
+	Bind       *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+	Session    *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-session session"`
+}
+
+type message struct {
+	From    string    `xml:"from,attr"`
+	To      string    `xml:"to,attr"`
+	Type    string    `xml:"type,attr"`
+	Id      string    `xml:"id,attr"`
+	Body    string    `xml:"body"`
+	Subject *string   `xml:"subject"`
+	Delay   *struct{} `xml:"urn:xmpp:delay delay"`
+}
+
+type mucUser struct {
+	Items []struct {
+		Jid  string `xml:"jid,attr"`
+		Nick string `xml:"nick,attr"`
+		Role string `xml:"role,attr"`
+	} `xml:"item"`
+	Statuses []struct {
+		Code string `xml:"code,attr"`
+	} `xml:"status"`
+}
+
+func (m mucUser) hasStatus(code string) bool {
+	for _, status := range m.Statuses {
+		if status.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+type presence struct {
+	From    string   `xml:"from,attr"`
+	To      string   `xml:"to,attr"`
+	Type    string   `xml:"type,attr"`
+	Show    string   `xml:"show"`
+	Status  string   `xml:"status"`
+	MucUser *mucUser `xml:"http://jabber.org/protocol/muc#user x"`
+}
+
+type iq struct {
+	From string `xml:"from,attr"`
+	To   string `xml:"to,attr"`
+	Type string `xml:"type,attr"`
+	Id   string `xml:"id,attr"`
+	Bind *struct {
+		Jid string `xml:"jid"`
+	} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+	Ping  *struct{} `xml:"urn:xmpp:ping ping"`
+	Inner []byte    `xml:",innerxml"`
+}
+
+// stream is a negotiated xmpp client stream.
+type stream struct {
+	conn       net.Conn
+	decoder    *xml.Decoder
+	domain     string
+	jid        string
+	writeMutex *sync.Mutex
+	id         int64
+}
+
+type streamConfig struct {
+	jid       jid
+	password  string
+	server    string
+	directTls bool
+	insecure  bool
+	tlsConfig *tls.Config
+	resource  string
+}
+
+// dialStream connect to server and negotiate tls, authentication and resource binding.
+func dialStream(conf streamConfig) (*stream, error) {
+	server := conf.server
+	if server == "" {
+		server = net.JoinHostPort(conf.jid.domain, "5222")
+	}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if conf.directTls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", server, conf.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &stream{
+		conn:       conn,
+		domain:     conf.jid.domain,
+		writeMutex: new(sync.Mutex),
+	}
+	err = s.negotiate(conf)
+	if err != nil {
+		s.conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *stream) negotiate(conf streamConfig) error {
+	s.conn.SetDeadline(time.Now().Add(dialTimeout))
+	defer s.conn.SetDeadline(time.Time{})
+	features, err := s.open()
+	if err != nil {
+		return err
+	}
+	if _, isTls := s.conn.(*tls.Conn); !isTls {
+		if features.StartTls == nil && !conf.insecure {
+			return errors.New("xmpp server doesn't support starttls")
+		}
+		if features.StartTls != nil {
+			features, err = s.startTls(conf.tlsConfig)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !contains(features.Mechanisms, "PLAIN") {
+		return fmt.Errorf("xmpp server doesn't support PLAIN authentication (available: %v)", features.Mechanisms)
+	}
+	features, err = s.authenticate(conf.jid.local, conf.password)
+	if err != nil {
+		return err
+	}
+	if features.Bind == nil {
+		return errors.New("xmpp server doesn't support resource binding")
+	}
+	var bind iq
+	err = s.request(fmt.Sprintf(`<iq type="set" id="%s"><bind xmlns="%s"><resource>%s</resource></bind></iq>`,
+		s.nextId(), nsBind, escape(conf.resource)), &bind)
+	if err != nil {
+		return err
+	}
+	if bind.Bind == nil || bind.Bind.Jid == "" {
+		return errors.New("xmpp server didn't bind resource")
+	}
+	s.jid = bind.Bind.Jid
+	if features.Session != nil {
+		var session iq
+		err = s.request(fmt.Sprintf(`<iq type="set" id="%s"><session xmlns="%s"/></iq>`, s.nextId(), nsSession), &session)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open start a new stream and give features announced by server.
+func (s *stream) open() (streamFeatures, error) {
+	var features streamFeatures
+	s.decoder = xml.NewDecoder(s.conn)
+	err := s.write(fmt.Sprintf(`<?xml version="1.0"?><stream:stream to="%s" xmlns="%s" xmlns:stream="%s" version="1.0">`,
+		escape(s.domain), nsClient, nsStream))
+	if err != nil {
+		return features, err
+	}
+	start, err := s.next()
+	if err != nil {
+		return features, err
+	}
+	if start.Name.Space != nsStream || start.Name.Local != "stream" {
+		return features, fmt.Errorf("xmpp server sent <%s> instead of stream", start.Name.Local)
+	}
+	start, err = s.next()
+	if err != nil {
+		return features, err
+	}
+	if start.Name.Space != nsStream || start.Name.Local != "features" {
+		return features, fmt.Errorf("xmpp server sent <%s> instead of stream features", start.Name.Local)
+	}
+	err = s.decoder.DecodeElement(&features, &start)
+	return features, err
+}
+
+func (s *stream) startTls(tlsConfig *tls.Config) (streamFeatures, error) {
+	err := s.write(`<starttls xmlns="` + nsTls + `"/>`)
+	if err != nil {
+		return streamFeatures{}, err
+	}
+	start, err := s.next()
+	if err != nil {
+		return streamFeatures{}, err
+	}
+	if start.Name.Local != "proceed" {
+		return streamFeatures{}, errors.New("xmpp server refused starttls")
+	}
+	tlsConn := tls.Client(s.conn, tlsConfig)
+	err = tlsConn.Handshake()
+	if err != nil {
+		return streamFeatures{}, err
+	}
+	s.conn = tlsConn
+	return s.open()
+}
+
+func (s *stream) authenticate(user, password string) (streamFeatures, error) {
+	credentials := base64.StdEncoding.EncodeToString([]byte("\x00" + user + "\x00" + password))
+	err := s.write(`<auth xmlns="` + nsSasl + `" mechanism="PLAIN">` + credentials + `</auth>`)
+	if err != nil {
+		return streamFeatures{}, err
+	}
+	start, err := s.next()
+	if err != nil {
+		return streamFeatures{}, err
+	}
+	if start.Name.Local != "success" {
+		var failure struct {
+			Inner []byte `xml:",innerxml"`
+		}
+		s.decoder.DecodeElement(&failure, &start)
+		return streamFeatures{}, fmt.Errorf("xmpp authentication failed: %s", string(failure.Inner))
+	}
+	s.decoder.Skip()
+	return s.open()
+}
+
+// request send iq and decode response.
+func (s *stream) request(data string, result *iq) error {
+	err := s.write(data)
+	if err != nil {
+		return err
+	}
+	start, err := s.next()
+	if err != nil {
+		return err
+	}
+	if start.Name.Local != "iq" {
+		return fmt.Errorf("xmpp server sent <%s> instead of iq", start.Name.Local)
+	}
+	err = s.decoder.DecodeElement(result, &start)
+	if err != nil {
+		return err
+	}
+	if result.Type != "result" {
+		return fmt.Errorf("xmpp request failed: %s", string(result.Inner))
+	}
+	return nil
+}
+
+// next give next top level element, an error is returned when stream is closed.
+func (s *stream) next() (xml.StartElement, error) {
+	for {
+		token, err := s.decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space == nsStream && t.Name.Local == "error" {
+				var streamErr struct {
+					Inner []byte `xml:",innerxml"`
+				}
+				s.decoder.DecodeElement(&streamErr, &t)
+				return t, fmt.Errorf("xmpp stream error: %s", string(streamErr.Inner))
+			}
+			return t, nil
+		case xml.EndElement:
+			if t.Name.Space == nsStream && t.Name.Local == "stream" {
+				return xml.StartElement{}, io.EOF
+			}
+		}
+	}
+}
+
+func (s *stream) write(data string) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	_, err := io.WriteString(s.conn, data)
+	return err
+}
+
+func (s *stream) nextId() string {
+	return "gubot" + strconv.FormatInt(atomic.AddInt64(&s.id, 1), 10)
+}
+
+func (s *stream) close() error {
+	s.write("</stream:stream>")
+	return s.conn.Close()
+}
+
+func escape(s string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+// Package testutil give helpers shared by tests of adapters.
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ArthurHlt/gubot/robot"
+	"github.com/jinzhu/gorm"
+)
+
+// NewGubot give a gubot with a sqlite store in a temporary directory, cleanup close the store and remove the directory.
+func NewGubot(t *testing.T) (*robot.Gubot, func()) {
+	dir, err := ioutil.TempDir("", "gubot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := gorm.Open("sqlite3", filepath.Join(dir, robot.SQLITE_DB))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	gubot := robot.NewGubot()
+	err = gubot.UseStore(store)
+	if err != nil {
+		store.Close()
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return gubot, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
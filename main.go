@@ -7,14 +7,26 @@ import (
 	// adapters
 	_ "github.com/ArthurHlt/gubot/adapter/shell"
 	// _ "github.com/ArthurHlt/gubot/adapter/slack"
+	// _ "github.com/ArthurHlt/gubot/adapter/slack_events"
 	_ "github.com/ArthurHlt/gubot/adapter/tts"
 	// _ "github.com/ArthurHlt/gubot/adapter/mattermost_user"
+	// _ "github.com/ArthurHlt/gubot/adapter/irc"
+	// _ "github.com/ArthurHlt/gubot/adapter/matrix"
+	// _ "github.com/ArthurHlt/gubot/adapter/discord"
+	// _ "github.com/ArthurHlt/gubot/adapter/telegram"
+	// _ "github.com/ArthurHlt/gubot/adapter/teams"
+	// _ "github.com/ArthurHlt/gubot/adapter/webhook"
+	// _ "github.com/ArthurHlt/gubot/adapter/email"
+	// _ "github.com/ArthurHlt/gubot/adapter/xmpp"
+	// _ "github.com/ArthurHlt/gubot/adapter/rocketchat"
 
 	// scripts
 	_ "github.com/ArthurHlt/gubot/scripts"
 
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -24,5 +36,17 @@ func main() {
 		addr = ":" + port
 	}
 	robot.Use(&middleware.AuthorizeMiddleware{})
-	log.Fatal(robot.Start(addr))
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		err := robot.Shutdown()
+		if err != nil {
+			log.Print(err)
+		}
+	}()
+	err := robot.Start(addr)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -81,14 +81,18 @@ func (g AccessControl) HasAccess(userEnvelop robot.UserEnvelop, groups Groups, c
 
 type AuthorizeMiddleware struct{}
 
-func (AuthorizeMiddleware) ScriptMiddleware(script robot.Script, next robot.EnvelopHandler) robot.EnvelopHandler {
+// CanUseScript is used by help to hide scripts that user can't use.
+func (AuthorizeMiddleware) CanUseScript(script robot.Script, envelop robot.Envelop) bool {
+	ac := authorizeConfig.GetAccessControl(script.Name)
+	if ac.Name == "" {
+		return true
+	}
+	return ac.HasAccess(envelop.User, authorizeConfig.Groups, envelop.ChannelName, envelop.ChannelId)
+}
+
+func (m AuthorizeMiddleware) ScriptMiddleware(script robot.Script, next robot.EnvelopHandler) robot.EnvelopHandler {
 	return func(envelop robot.Envelop, submatch [][]string) ([]string, error) {
-		ac := authorizeConfig.GetAccessControl(script.Name)
-		groups := authorizeConfig.Groups
-		if ac.Name == "" {
-			return next(envelop, submatch)
-		}
-		if ac.HasAccess(envelop.User, groups, envelop.ChannelName, envelop.ChannelId) {
+		if m.CanUseScript(script, envelop) {
 			return next(envelop, submatch)
 		}
 		return []string{}, nil
@@ -0,0 +1,335 @@
+package robot
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ACTION_TOKEN_TTL is the time during which an interactive element of a message can be triggered.
+const ACTION_TOKEN_TTL = 7 * 24 * time.Hour
+
+const actionsSecretKey = "gubot_actions_secret"
+
+// ActionCallback is given to an action handler when a user triggered an interactive element of a message.
+type ActionCallback struct {
+	Action string
+	// Value is the value given to the button or menu when message was sent
+	Value string
+	// Selected is the option chosen by user in a menu
+	Selected string
+	// Envelop contains the user who triggered the action and the channel of the message
+	Envelop Envelop
+}
+
+// ActionResponse is sent back to the chat, Text replace the message containing the action when Update is true,
+// otherwise it is only shown to the user who triggered the action (if adapter support it).
+type ActionResponse struct {
+	Text   string
+	Update bool
+}
+
+type ActionHandler func(callback ActionCallback) (ActionResponse, error)
+
+// ActionRequest is an action callback parsed by an adapter.
+type ActionRequest struct {
+	ActionId string
+	Selected string
+	Envelop  Envelop
+}
+
+// ActionAdapter is an adapter which receive callbacks of interactive messages on ActionUrl.
+type ActionAdapter interface {
+	ParseAction(req *http.Request) (ActionRequest, error)
+	FormatActionResponse(response ActionResponse) (interface{}, error)
+}
+
+type actions struct {
+	handlers map[string]ActionHandler
+	secret   []byte
+	mutex    *sync.Mutex
+}
+
+func newActions() *actions {
+	return &actions{
+		handlers: make(map[string]ActionHandler),
+		mutex:    new(sync.Mutex),
+	}
+}
+
+// RegisterAction register a handler called when a user trigger a button or a menu having this action name.
+func (g *Gubot) RegisterAction(name string, handler ActionHandler) error {
+	if name == "" || handler == nil {
+		return errors.New("Action can't have name or handler empty.")
+	}
+	g.actions.mutex.Lock()
+	defer g.actions.mutex.Unlock()
+	if _, ok := g.actions.handlers[name]; ok {
+		return fmt.Errorf("Action '%s' already registered", name)
+	}
+	g.actions.handlers[name] = handler
+	log.Debugf("Action '%s' registered.", name)
+	return nil
+}
+
+func (g *Gubot) UnregisterAction(name string) {
+	g.actions.mutex.Lock()
+	defer g.actions.mutex.Unlock()
+	delete(g.actions.handlers, name)
+}
+
+func (g *Gubot) actionHandler(name string) (ActionHandler, bool) {
+	g.actions.mutex.Lock()
+	defer g.actions.mutex.Unlock()
+	handler, ok := g.actions.handlers[name]
+	return handler, ok
+}
+
+// ActionUrl is the url where an adapter must send callbacks of interactive messages.
+func (g Gubot) ActionUrl(adapterName string) string {
+	return g.Host() + "/actions/" + adapterName
+}
+
+// SetActionsSecret set the key used to sign action ids, when not set a key is generated and kept in brain.
+func (g *Gubot) SetActionsSecret(secret string) {
+	g.actions.mutex.Lock()
+	defer g.actions.mutex.Unlock()
+	g.actions.secret = []byte(secret)
+}
+
+func (g *Gubot) actionsSecret() ([]byte, error) {
+	g.actions.mutex.Lock()
+	defer g.actions.mutex.Unlock()
+	if len(g.actions.secret) > 0 {
+		return g.actions.secret, nil
+	}
+	if g.brain != nil {
+		secret, found, err := g.brain.Get(GlobalScope(), actionsSecretKey)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			g.actions.secret = []byte(secret)
+			return g.actions.secret, nil
+		}
+	}
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	secret := hex.EncodeToString(b)
+	if g.brain != nil {
+		err = g.brain.Set(GlobalScope(), actionsSecretKey, secret, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	g.actions.secret = []byte(secret)
+	return g.actions.secret, nil
+}
+
+func (g *Gubot) signActionId(id string) (string, error) {
+	secret, err := g.actionsSecret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id))
+	return id + "." + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// actionBinding is where a message with actions is sent, callbacks from elsewhere are refused.
+type actionBinding struct {
+	channel string
+	user    string
+}
+
+// newActionBinding bind actions to user of envelop for direct messages and to channel of envelop otherwise.
+func newActionBinding(envelop Envelop, direct bool) actionBinding {
+	if direct {
+		return actionBinding{user: UserScope(envelop).Id}
+	}
+	return actionBinding{channel: ChannelScope(envelop).Id}
+}
+
+// isBoundTo check that callback envelop comes from channel or user which received the action,
+// channel and user can be identified by their id or their name.
+func (t ActionToken) isBoundTo(envelop Envelop) bool {
+	matches := func(bound string, values ...string) bool {
+		bound = strings.TrimPrefix(bound, "#")
+		for _, value := range values {
+			if value != "" && strings.TrimPrefix(value, "#") == bound {
+				return true
+			}
+		}
+		return false
+	}
+	if t.Channel != "" && !matches(t.Channel, envelop.ChannelId, envelop.ChannelName) {
+		return false
+	}
+	if t.Recipient != "" && !matches(t.Recipient, envelop.User.Id, envelop.User.Name) {
+		return false
+	}
+	return true
+}
+
+// createActionId store action and value and give back a signed id to send with the message.
+func (g *Gubot) createActionId(action, value string, binding actionBinding) (string, error) {
+	if g.store == nil {
+		return "", errors.New("Store must be loaded to use actions")
+	}
+	expiresAt := time.Now().Add(ACTION_TOKEN_TTL)
+	token := ActionToken{
+		ID:        uuid.NewV4().String(),
+		Action:    action,
+		Value:     value,
+		Channel:   binding.channel,
+		Recipient: binding.user,
+		ExpiresAt: &expiresAt,
+	}
+	err := g.store.Create(&token).Error
+	if err != nil {
+		return "", err
+	}
+	return g.signActionId(token.ID)
+}
+
+// signActions set a signed action id on buttons and menus of message which have an action.
+func (g *Gubot) signActions(message *Message, binding actionBinding) error {
+	if !message.hasActions() {
+		return nil
+	}
+	g.store.Where("expires_at < ?", time.Now()).Delete(&ActionToken{})
+	for i, button := range message.Buttons {
+		if button.Action == "" {
+			continue
+		}
+		actionId, err := g.createActionId(button.Action, button.Value, binding)
+		if err != nil {
+			return err
+		}
+		message.Buttons[i].ActionId = actionId
+	}
+	for i, menu := range message.Menus {
+		if menu.Action == "" {
+			continue
+		}
+		actionId, err := g.createActionId(menu.Action, menu.Value, binding)
+		if err != nil {
+			return err
+		}
+		message.Menus[i].ActionId = actionId
+	}
+	return nil
+}
+
+// verifyActionId check signature of action id and give back the stored action.
+func (g *Gubot) verifyActionId(actionId string) (ActionToken, error) {
+	index := strings.LastIndex(actionId, ".")
+	if index == -1 {
+		return ActionToken{}, errors.New("Action id is not signed")
+	}
+	expected, err := g.signActionId(actionId[:index])
+	if err != nil {
+		return ActionToken{}, err
+	}
+	if !hmac.Equal([]byte(expected), []byte(actionId)) {
+		return ActionToken{}, errors.New("Action id has an invalid signature")
+	}
+	var token ActionToken
+	err = g.store.Where("id = ?", actionId[:index]).First(&token).Error
+	if err != nil {
+		return ActionToken{}, errors.New("Action not found: " + err.Error())
+	}
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return ActionToken{}, errors.New("Action has expired")
+	}
+	return token, nil
+}
+
+func (g *Gubot) actionCallback(w http.ResponseWriter, req *http.Request) {
+	if g.IsStopping() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	adapterName := mux.Vars(req)["adapter"]
+	var actionAdapter ActionAdapter
+	for _, adp := range g.adapters {
+		if adp.Name() != adapterName {
+			continue
+		}
+		if adp, ok := adp.(ActionAdapter); ok {
+			actionAdapter = adp
+		}
+	}
+	if actionAdapter == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("No adapter found for actions " + adapterName))
+		return
+	}
+	actionReq, err := actionAdapter.ParseAction(req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		log.Errorf("Error when parsing action from adapter '%s': %s", adapterName, err.Error())
+		return
+	}
+	token, err := g.verifyActionId(actionReq.ActionId)
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(err.Error()))
+		log.Errorf("Error when verifying action from adapter '%s': %s", adapterName, err.Error())
+		return
+	}
+	if !token.isBoundTo(actionReq.Envelop) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Action can't be triggered from this channel or by this user"))
+		log.Errorf("Action '%s' from adapter '%s' refused: it was not sent to this channel or user", token.Action, adapterName)
+		return
+	}
+	handler, ok := g.actionHandler(token.Action)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("No handler found for action " + token.Action))
+		return
+	}
+	g.Emit(GubotEvent{
+		Name:    EVENT_ROBOT_ACTION_TRIGGERED,
+		Envelop: actionReq.Envelop,
+		Message: token.Action,
+	})
+	resp, err := handler(ActionCallback{
+		Action:   token.Action,
+		Value:    token.Value,
+		Selected: actionReq.Selected,
+		Envelop:  actionReq.Envelop,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		log.Errorf("Error on action '%s': %s", token.Action, err.Error())
+		return
+	}
+	result, err := actionAdapter.FormatActionResponse(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		log.Error(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	b, _ := json.Marshal(result)
+	w.Write(b)
+}
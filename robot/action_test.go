@@ -0,0 +1,58 @@
+package robot
+
+import (
+	"testing"
+)
+
+func TestSendRichMessagesSignActionsOnce(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+	store.AutoMigrate(&ActionToken{})
+	g := NewGubot()
+	g.store = store
+	first := newRichRecordAdapter("first")
+	second := newRichRecordAdapter("second")
+	plain := newRecordAdapter()
+	g.RegisterAdapter(first)
+	g.RegisterAdapter(second)
+	g.RegisterAdapter(plain)
+	message := Message{
+		Text: "Who wants a coffee?",
+		Buttons: []MessageButton{
+			{Text: "Me!", Action: "coffee", Value: "yes"},
+			{Text: "Menu", Url: "https://example.com"},
+		},
+	}
+
+	err := g.SendRichMessages(Envelop{ChannelName: "general"}, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	store.Model(&ActionToken{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected one action token for all adapters, got %d", count)
+	}
+	if message.Buttons[0].ActionId != "" {
+		t.Error("expected message given to not be modified")
+	}
+	firstSent, secondSent := first.sentRich(), second.sentRich()
+	if len(firstSent) != 1 || len(secondSent) != 1 {
+		t.Fatalf("expected one message on each rich adapter, got %v and %v", firstSent, secondSent)
+	}
+	actionId := firstSent[0].Buttons[0].ActionId
+	if actionId == "" || secondSent[0].Buttons[0].ActionId != actionId {
+		t.Errorf("expected same action id on each adapter, got '%s' and '%s'", actionId, secondSent[0].Buttons[0].ActionId)
+	}
+	token, err := g.verifyActionId(actionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Action != "coffee" || token.Value != "yes" || !token.isBoundTo(Envelop{ChannelName: "general"}) {
+		t.Errorf("unexpected action token %v", token)
+	}
+	if len(plain.sent()) != 1 {
+		t.Errorf("expected plain text on adapter without rich messages, got %v", plain.sent())
+	}
+}
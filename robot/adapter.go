@@ -1,5 +1,7 @@
 package robot
 
+import "context"
+
 type Adapter interface {
 	Name() string
 	Send(Envelop, string) error
@@ -16,3 +18,8 @@ type SlashCommandAdapter interface {
 	Register(slashCommand SlashCommand) ([]SlashCommandToken, error)
 	Format(message string) (interface{}, error)
 }
+
+// StoppableAdapter is an adapter which must release its resources when gubot stop.
+type StoppableAdapter interface {
+	Stop(ctx context.Context) error
+}
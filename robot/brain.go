@@ -0,0 +1,229 @@
+package robot
+
+import (
+	"github.com/jinzhu/gorm"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	BrainScopeGlobal  BrainScopeType = "global"
+	BrainScopeUser    BrainScopeType = "user"
+	BrainScopeChannel BrainScopeType = "channel"
+)
+
+type BrainScopeType string
+
+type BrainScope struct {
+	Type BrainScopeType
+	Id   string
+}
+
+func (s BrainScope) String() string {
+	if s.Type == BrainScopeGlobal {
+		return string(BrainScopeGlobal)
+	}
+	return string(s.Type) + ":" + s.Id
+}
+
+func GlobalScope() BrainScope {
+	return BrainScope{Type: BrainScopeGlobal}
+}
+
+func UserScope(envelop Envelop) BrainScope {
+	id := envelop.User.Id
+	if id == "" {
+		id = envelop.User.Name
+	}
+	return BrainScope{Type: BrainScopeUser, Id: id}
+}
+
+func ChannelScope(envelop Envelop) BrainScope {
+	id := envelop.ChannelId
+	if id == "" {
+		id = envelop.ChannelName
+	}
+	return BrainScope{Type: BrainScopeChannel, Id: id}
+}
+
+// Brain is a key/value store for scripts and adapters,
+// a ttl of 0 means that the value never expires.
+type Brain interface {
+	Get(scope BrainScope, key string) (string, bool, error)
+	Set(scope BrainScope, key, value string, ttl time.Duration) error
+	Delete(scope BrainScope, key string) error
+	List(scope BrainScope) (map[string]string, error)
+}
+
+func expiresAt(ttl time.Duration) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+	expire := time.Now().Add(ttl)
+	return &expire
+}
+
+func isExpired(expire *time.Time) bool {
+	return expire != nil && time.Now().After(*expire)
+}
+
+type StoreBrain struct {
+	store *gorm.DB
+}
+
+func NewStoreBrain(store *gorm.DB) *StoreBrain {
+	return &StoreBrain{store}
+}
+
+// entryConditions give conditions to find an entry, a map is used because gorm ignores zero values
+// in struct conditions (an empty key would match every entry of the scope).
+func entryConditions(scope BrainScope, key string) map[string]interface{} {
+	return map[string]interface{}{
+		"scope": scope.String(),
+		"key":   key,
+	}
+}
+
+func (b StoreBrain) Get(scope BrainScope, key string) (string, bool, error) {
+	var entries []BrainEntry
+	err := b.store.Where(entryConditions(scope, key)).Find(&entries).Error
+	if err != nil {
+		return "", false, err
+	}
+	if len(entries) == 0 {
+		return "", false, nil
+	}
+	entry := entries[0]
+	if isExpired(entry.ExpiresAt) {
+		return "", false, b.Delete(scope, key)
+	}
+	return entry.Value, true, nil
+}
+
+// Set update the entry or create it when it doesn't exist,
+// the unique index on scope and key refuse an entry created meanwhile by another Set which is then updated.
+func (b StoreBrain) Set(scope BrainScope, key, value string, ttl time.Duration) error {
+	updates := map[string]interface{}{
+		"value":      value,
+		"expires_at": expiresAt(ttl),
+	}
+	result := b.store.Model(&BrainEntry{}).Where(entryConditions(scope, key)).Updates(updates)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	err := b.store.Create(&BrainEntry{
+		Scope:     scope.String(),
+		Key:       key,
+		Value:     value,
+		ExpiresAt: expiresAt(ttl),
+	}).Error
+	if err == nil {
+		return nil
+	}
+	result = b.store.Model(&BrainEntry{}).Where(entryConditions(scope, key)).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return err
+	}
+	return nil
+}
+
+func (b StoreBrain) Delete(scope BrainScope, key string) error {
+	return b.store.Unscoped().Where(entryConditions(scope, key)).Delete(BrainEntry{}).Error
+}
+
+func (b StoreBrain) List(scope BrainScope) (map[string]string, error) {
+	var entries []BrainEntry
+	err := b.store.Where(map[string]interface{}{"scope": scope.String()}).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, entry := range entries {
+		if isExpired(entry.ExpiresAt) {
+			b.store.Unscoped().Delete(&entry)
+			continue
+		}
+		values[entry.Key] = entry.Value
+	}
+	return values, nil
+}
+
+type memoryBrainEntry struct {
+	value     string
+	expiresAt *time.Time
+}
+
+// MemoryBrain is a brain which is lost when gubot stop, it is mainly useful for tests.
+type MemoryBrain struct {
+	entries map[string]map[string]memoryBrainEntry
+	mutex   *sync.Mutex
+}
+
+func NewMemoryBrain() *MemoryBrain {
+	return &MemoryBrain{
+		entries: make(map[string]map[string]memoryBrainEntry),
+		mutex:   new(sync.Mutex),
+	}
+}
+
+func (b *MemoryBrain) Get(scope BrainScope, key string) (string, bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	entry, ok := b.entries[scope.String()][key]
+	if !ok {
+		return "", false, nil
+	}
+	if isExpired(entry.expiresAt) {
+		delete(b.entries[scope.String()], key)
+		return "", false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (b *MemoryBrain) Set(scope BrainScope, key, value string, ttl time.Duration) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.entries[scope.String()]; !ok {
+		b.entries[scope.String()] = make(map[string]memoryBrainEntry)
+	}
+	b.entries[scope.String()][key] = memoryBrainEntry{
+		value:     value,
+		expiresAt: expiresAt(ttl),
+	}
+	return nil
+}
+
+func (b *MemoryBrain) Delete(scope BrainScope, key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.entries[scope.String()], key)
+	return nil
+}
+
+func (b *MemoryBrain) List(scope BrainScope) (map[string]string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	values := make(map[string]string)
+	for key, entry := range b.entries[scope.String()] {
+		if isExpired(entry.expiresAt) {
+			delete(b.entries[scope.String()], key)
+			continue
+		}
+		values[key] = entry.value
+	}
+	return values, nil
+}
+
+// SortedKeys returns keys of a list given by a brain in alphabetical order.
+func SortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package robot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// newTestStore give a sqlite store in a temporary directory removed by cleanup.
+func newTestStore(t *testing.T) (*gorm.DB, func()) {
+	dir, err := ioutil.TempDir("", "gubot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := gorm.Open("sqlite3", filepath.Join(dir, SQLITE_DB))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	store.AutoMigrate(&BrainEntry{}, &ScheduledJob{})
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func testBrain(t *testing.T, name string, brain Brain) {
+	scope := UserScope(Envelop{User: UserEnvelop{Id: "user1"}})
+	otherScope := UserScope(Envelop{User: UserEnvelop{Id: "user2"}})
+
+	if _, found, err := brain.Get(scope, "color"); err != nil || found {
+		t.Errorf("%s: expected no value before set, got found=%t err=%v", name, found, err)
+	}
+	if err := brain.Set(scope, "color", "blue", 0); err != nil {
+		t.Fatalf("%s: %s", name, err.Error())
+	}
+	if err := brain.Set(scope, "color", "red", 0); err != nil {
+		t.Fatalf("%s: %s", name, err.Error())
+	}
+	if value, found, _ := brain.Get(scope, "color"); !found || value != "red" {
+		t.Errorf("%s: expected value to be overwritten with red, got '%s' (found=%t)", name, value, found)
+	}
+	if _, found, _ := brain.Get(otherScope, "color"); found {
+		t.Errorf("%s: expected value to be kept in its scope", name)
+	}
+	if _, found, _ := brain.Get(scope, ""); found {
+		t.Errorf("%s: expected empty key to not match entries of the scope", name)
+	}
+
+	brain.Set(scope, "size", "xl", 0)
+	brain.Set(scope, "token", "secret", time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	if _, found, _ := brain.Get(scope, "token"); found {
+		t.Errorf("%s: expected value to expire after its ttl", name)
+	}
+	values, err := brain.List(scope)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err.Error())
+	}
+	if len(values) != 2 || values["color"] != "red" || values["size"] != "xl" {
+		t.Errorf("%s: expected list of unexpired values, got %v", name, values)
+	}
+
+	if err := brain.Delete(scope, "color"); err != nil {
+		t.Fatalf("%s: %s", name, err.Error())
+	}
+	if _, found, _ := brain.Get(scope, "color"); found {
+		t.Errorf("%s: expected value to be deleted", name)
+	}
+	if _, found, _ := brain.Get(scope, "size"); !found {
+		t.Errorf("%s: expected other values of scope to be kept on delete", name)
+	}
+}
+
+func TestMemoryBrain(t *testing.T) {
+	testBrain(t, "memory brain", NewMemoryBrain())
+}
+
+func TestStoreBrain(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+	testBrain(t, "store brain", NewStoreBrain(store))
+}
+
+func TestStoreBrainConcurrentSet(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+	brain := NewStoreBrain(store)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := brain.Set(GlobalScope(), "counter", fmt.Sprint(i), 0)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+	var count int
+	store.Model(&BrainEntry{}).Where("key = ?", "counter").Count(&count)
+	if count != 1 {
+		t.Errorf("expected concurrent sets to keep one entry, got %d", count)
+	}
+}
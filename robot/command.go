@@ -0,0 +1,377 @@
+package robot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Command is a declarative definition of a script triggered by words with positional arguments and flags,
+// e.g.: `deploy <app> [env] --force --instances=<int>`.
+// When a script has a command, matcher is generated and arguments are parsed and given to ArgsFunction.
+type Command struct {
+	Name        string
+	Description string
+	Args        []CommandArg
+	Flags       []CommandFlag
+}
+
+type CommandArg struct {
+	Name        string
+	Type        string
+	Description string
+	Optional    bool
+	Default     interface{}
+}
+
+type CommandFlag struct {
+	Name        string
+	Short       string
+	Type        string
+	Description string
+	Default     interface{}
+}
+
+// Args contains arguments and flags parsed by a command, values are typed by their definition.
+type Args map[string]interface{}
+
+type ArgsHandler func(envelop Envelop, args Args) ([]string, error)
+
+func (a Args) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+func (a Args) String(name string) string {
+	v, ok := a[name]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func (a Args) Int(name string) int {
+	v, _ := a[name].(int)
+	return v
+}
+
+func (a Args) Float(name string) float64 {
+	v, _ := a[name].(float64)
+	return v
+}
+
+func (a Args) Bool(name string) bool {
+	v, _ := a[name].(bool)
+	return v
+}
+
+func (a CommandArg) isOptional() bool {
+	return a.Optional || a.Default != nil
+}
+
+func (a CommandArg) argType() string {
+	if a.Type == "" {
+		return ArgString
+	}
+	return a.Type
+}
+
+func (f CommandFlag) flagType() string {
+	if f.Type == "" {
+		return ArgBool
+	}
+	return f.Type
+}
+
+func (c Command) check() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("command can't have an empty name")
+	}
+	optionalFound := false
+	for i, arg := range c.Args {
+		if _, ok := argPatterns[arg.argType()]; !ok {
+			return fmt.Errorf("unknown type '%s' for argument '%s'", arg.Type, arg.Name)
+		}
+		if arg.argType() == ArgText && i != len(c.Args)-1 {
+			return fmt.Errorf("argument '%s' of type text must be the last one", arg.Name)
+		}
+		if optionalFound && !arg.isOptional() {
+			return fmt.Errorf("required argument '%s' can't be after an optional argument", arg.Name)
+		}
+		optionalFound = optionalFound || arg.isOptional()
+	}
+	for _, flag := range c.Flags {
+		if _, ok := argPatterns[flag.flagType()]; !ok || flag.flagType() == ArgText {
+			return fmt.Errorf("invalid type '%s' for flag '%s'", flag.Type, flag.Name)
+		}
+	}
+	return nil
+}
+
+// Usage give the command syntax, e.g.: `deploy <app> [env] [--force] [--instances=<int>]`.
+func (c Command) Usage() string {
+	usage := strings.Join(strings.Fields(c.Name), " ")
+	for _, arg := range c.Args {
+		name := arg.Name
+		if arg.argType() == ArgText {
+			name += "..."
+		}
+		if arg.isOptional() {
+			usage += " [" + name + "]"
+			continue
+		}
+		usage += " <" + name + ">"
+	}
+	for _, flag := range c.Flags {
+		if flag.flagType() == ArgBool {
+			usage += " [--" + flag.Name + "]"
+			continue
+		}
+		usage += " [--" + flag.Name + "=<" + flag.flagType() + ">]"
+	}
+	return usage
+}
+
+// Help give usage with description of each argument and flag.
+func (c Command) Help() string {
+	help := "Usage: `" + c.Usage() + "`"
+	if c.Description != "" {
+		help += "\n" + c.Description
+	}
+	for _, arg := range c.Args {
+		help += fmt.Sprintf("\n- `%s` (%s", arg.Name, arg.argType())
+		if arg.Default != nil {
+			help += fmt.Sprintf(", default: %v", arg.Default)
+		} else if arg.isOptional() {
+			help += ", optional"
+		}
+		help += ")"
+		if arg.Description != "" {
+			help += ": " + arg.Description
+		}
+	}
+	for _, flag := range c.Flags {
+		help += "\n- `--" + flag.Name + "`"
+		if flag.Short != "" {
+			help += ", `-" + flag.Short + "`"
+		}
+		help += " (" + flag.flagType()
+		if flag.Default != nil {
+			help += fmt.Sprintf(", default: %v", flag.Default)
+		}
+		help += ")"
+		if flag.Description != "" {
+			help += ": " + flag.Description
+		}
+	}
+	return help
+}
+
+// rest returns the text after command name, ok is false if content doesn't start with command name.
+func (c Command) rest(content string) (string, bool) {
+	remaining := strings.TrimSpace(content)
+	for _, word := range strings.Fields(c.Name) {
+		if len(remaining) < len(word) || !strings.EqualFold(remaining[:len(word)], word) {
+			return "", false
+		}
+		remaining = remaining[len(word):]
+		if remaining != "" && !unicode.IsSpace(rune(remaining[0])) {
+			return "", false
+		}
+		remaining = strings.TrimSpace(remaining)
+	}
+	return remaining, true
+}
+
+func (c Command) Match(content string) bool {
+	_, ok := c.rest(content)
+	return ok
+}
+
+func (c Command) SubMatch(content string) [][]string {
+	rest, ok := c.rest(content)
+	if !ok {
+		return [][]string{}
+	}
+	return [][]string{append([]string{content}, splitCommandLine(rest)...)}
+}
+
+func (c Command) flag(name string) (CommandFlag, bool) {
+	for _, flag := range c.Flags {
+		if flag.Name == name || (flag.Short != "" && flag.Short == name) {
+			return flag, true
+		}
+	}
+	return CommandFlag{}, false
+}
+
+// Parse give typed arguments and flags from a message, an error is returned if message doesn't respect usage.
+func (c Command) Parse(content string) (Args, error) {
+	rest, ok := c.rest(content)
+	if !ok {
+		return nil, fmt.Errorf("message doesn't start with '%s'", c.Name)
+	}
+	tokens := splitCommandLine(rest)
+	args := make(Args)
+	for _, flag := range c.Flags {
+		if flag.Default != nil {
+			args[flag.Name] = flag.Default
+		} else if flag.flagType() == ArgBool {
+			args[flag.Name] = false
+		}
+	}
+	positionals := make([]string, 0)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if !strings.HasPrefix(token, "-") || len(token) < 2 || isNumber(token) {
+			positionals = append(positionals, token)
+			continue
+		}
+		name := strings.TrimLeft(token, "-")
+		value := ""
+		hasValue := false
+		if idx := strings.Index(name, "="); idx != -1 {
+			name, value, hasValue = name[:idx], name[idx+1:], true
+		}
+		flag, found := c.flag(name)
+		if !found {
+			return nil, fmt.Errorf("unknown flag '%s'", token)
+		}
+		if !hasValue && flag.flagType() == ArgBool {
+			args[flag.Name] = true
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("flag '--%s' needs a value", flag.Name)
+			}
+			i++
+			value = tokens[i]
+		}
+		typed, err := convertArg(value, flag.flagType())
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for flag '--%s': %s", flag.Name, err.Error())
+		}
+		args[flag.Name] = typed
+	}
+	for i, arg := range c.Args {
+		if i >= len(positionals) {
+			if !arg.isOptional() {
+				return nil, fmt.Errorf("missing argument '%s'", arg.Name)
+			}
+			if arg.Default != nil {
+				args[arg.Name] = arg.Default
+			}
+			continue
+		}
+		value := positionals[i]
+		if arg.argType() == ArgText {
+			value = strings.Join(positionals[i:], " ")
+			positionals = positionals[:i+1]
+		}
+		typed, err := convertArg(value, arg.argType())
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for argument '%s': %s", arg.Name, err.Error())
+		}
+		args[arg.Name] = typed
+	}
+	if len(positionals) > len(c.Args) {
+		return nil, fmt.Errorf("too many arguments given")
+	}
+	return args, nil
+}
+
+// argsParser give typed arguments from a message, it is a Command or a CommandMatcher.
+type argsParser interface {
+	Parse(content string) (Args, error)
+	Help() string
+}
+
+// toEnvelopHandler give parsed arguments to handler or answer with usage when message can't be parsed.
+func toEnvelopHandler(parser argsParser, handler ArgsHandler) EnvelopHandler {
+	return func(envelop Envelop, subMatch [][]string) ([]string, error) {
+		content := envelop.Message
+		if len(subMatch) > 0 && len(subMatch[0]) > 0 {
+			content = subMatch[0][0]
+		}
+		args, err := parser.Parse(content)
+		if err != nil {
+			return []string{fmt.Sprintf("Invalid command: %s.\n%s", err.Error(), parser.Help())}, nil
+		}
+		return handler(envelop, args)
+	}
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// boolArgs are values accepted for a bool argument, the ArgBool pattern of command matcher accept the same.
+var boolArgs = map[string]bool{
+	"true":  true,
+	"yes":   true,
+	"on":    true,
+	"1":     true,
+	"false": false,
+	"no":    false,
+	"off":   false,
+	"0":     false,
+}
+
+func convertArg(value, argType string) (interface{}, error) {
+	switch argType {
+	case ArgInt:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not an integer", value)
+		}
+		return i, nil
+	case ArgFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", value)
+		}
+		return f, nil
+	case ArgBool:
+		b, ok := boolArgs[strings.ToLower(value)]
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a boolean", value)
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+// splitCommandLine split text on spaces but keep text between quotes together.
+func splitCommandLine(text string) []string {
+	tokens := make([]string, 0)
+	current := ""
+	inToken := false
+	var quote rune
+	for _, r := range text {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current += string(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inToken = true
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, current)
+			}
+			current = ""
+			inToken = false
+		default:
+			current += string(r)
+			inToken = true
+		}
+	}
+	if inToken {
+		tokens = append(tokens, current)
+	}
+	return tokens
+}
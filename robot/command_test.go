@@ -0,0 +1,178 @@
+package robot
+
+import (
+	"reflect"
+	"testing"
+)
+
+var deployCommand = Command{
+	Name: "deploy",
+	Args: []CommandArg{
+		{Name: "app"},
+		{Name: "env", Default: "staging"},
+	},
+	Flags: []CommandFlag{
+		{Name: "force", Short: "f"},
+		{Name: "instances", Short: "i", Type: ArgInt, Default: 1},
+		{Name: "ratio", Type: ArgFloat},
+	},
+}
+
+func TestCommandParse(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected Args
+	}{
+		{
+			"deploy myapp",
+			Args{"app": "myapp", "env": "staging", "force": false, "instances": 1},
+		},
+		{
+			"Deploy myapp production --force",
+			Args{"app": "myapp", "env": "production", "force": true, "instances": 1},
+		},
+		{
+			"deploy myapp -f --instances=3 --ratio 0.5",
+			Args{"app": "myapp", "env": "staging", "force": true, "instances": 3, "ratio": 0.5},
+		},
+		{
+			"deploy -i 2 \"my app\" 'prod env'",
+			Args{"app": "my app", "env": "prod env", "force": false, "instances": 2},
+		},
+		{
+			"deploy myapp --force=false",
+			Args{"app": "myapp", "env": "staging", "force": false, "instances": 1},
+		},
+	}
+	for _, test := range tests {
+		args, err := deployCommand.Parse(test.content)
+		if err != nil {
+			t.Errorf("'%s': unexpected error: %s", test.content, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("'%s': expected %v, got %v", test.content, test.expected, args)
+		}
+	}
+}
+
+func TestCommandParseErrors(t *testing.T) {
+	contents := []string{
+		"undeploy myapp",
+		"deployment myapp",
+		"deploy",
+		"deploy myapp production extra",
+		"deploy myapp --unknown",
+		"deploy myapp --instances",
+		"deploy myapp --instances=many",
+		"deploy myapp --force=maybe",
+	}
+	for _, content := range contents {
+		if _, err := deployCommand.Parse(content); err == nil {
+			t.Errorf("'%s': expected an error", content)
+		}
+	}
+}
+
+func TestCommandParseTextAndNumbers(t *testing.T) {
+	cmd := Command{
+		Name: "add note",
+		Args: []CommandArg{
+			{Name: "priority", Type: ArgInt},
+			{Name: "text", Type: ArgText},
+		},
+	}
+	args, err := cmd.Parse("add   note -2 buy milk and   bread")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Int("priority") != -2 {
+		t.Errorf("expected negative number to be an argument, got %v", args["priority"])
+	}
+	if args.String("text") != "buy milk and bread" {
+		t.Errorf("expected remaining words as text, got '%s'", args.String("text"))
+	}
+	if _, err := cmd.Parse("add notes 1 text"); err == nil {
+		t.Error("expected command name to match whole words")
+	}
+}
+
+func TestCommandCheck(t *testing.T) {
+	invalids := []Command{
+		{Name: " "},
+		{Name: "cmd", Args: []CommandArg{{Name: "a", Type: "date"}}},
+		{Name: "cmd", Args: []CommandArg{{Name: "a", Type: ArgText}, {Name: "b"}}},
+		{Name: "cmd", Args: []CommandArg{{Name: "a", Optional: true}, {Name: "b"}}},
+		{Name: "cmd", Flags: []CommandFlag{{Name: "f", Type: ArgText}}},
+	}
+	for _, cmd := range invalids {
+		if err := cmd.check(); err == nil {
+			t.Errorf("expected command %v to be refused", cmd)
+		}
+	}
+	if err := deployCommand.check(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	expected := "deploy <app> [env] [--force] [--instances=<int>] [--ratio=<float>]"
+	if deployCommand.Usage() != expected {
+		t.Errorf("expected usage '%s', got '%s'", expected, deployCommand.Usage())
+	}
+}
+
+func TestArgBoolPatternAgreesWithConversion(t *testing.T) {
+	matcher, err := NewCommandMatcher("set <value:bool>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []string{"true", "False", "yes", "no", "on", "OFF", "1", "0", "2", "maybe", "y", "truth"}
+	for value := range boolArgs {
+		values = append(values, value)
+	}
+	for _, value := range values {
+		_, convErr := convertArg(value, ArgBool)
+		if matcher.Match("set "+value) != (convErr == nil) {
+			t.Errorf("'%s': pattern and conversion of bool argument don't agree", value)
+		}
+	}
+}
+
+func TestCommandMatcherArgsFunction(t *testing.T) {
+	g := NewGubot()
+	var given Args
+	err := g.RegisterScript(Script{
+		Name:        "scale",
+		Matcher:     "scale <app> to <instances:int> <force:bool>",
+		MatcherType: MatcherCommand,
+		ArgsFunction: func(envelop Envelop, args Args) ([]string, error) {
+			given = args
+			return []string{}, nil
+		},
+		Type: Tsend,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := g.GetScripts()[0]
+	content := "scale myapp to 3 yes"
+	_, err = script.Function(Envelop{Message: content}, script.allSubMatch(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Args{"app": "myapp", "instances": 3, "force": true}
+	if !reflect.DeepEqual(given, expected) {
+		t.Errorf("expected arguments %v, got %v", expected, given)
+	}
+
+	err = g.RegisterScript(Script{
+		Name:         "regex with args",
+		Matcher:      "^scale$",
+		ArgsFunction: func(envelop Envelop, args Args) ([]string, error) { return []string{}, nil },
+		Type:         Tsend,
+	})
+	if err == nil {
+		t.Error("expected ArgsFunction to be refused without a command")
+	}
+}
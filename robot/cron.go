@@ -0,0 +1,167 @@
+package robot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type cronField struct {
+	min int
+	max int
+}
+
+var cronFields = []cronField{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week
+}
+
+// CronSchedule is a standard 5 fields cron expression (minute hour day-of-month month day-of-week).
+type CronSchedule struct {
+	expr        string
+	minute      uint64
+	hour        uint64
+	dayOfMonth  uint64
+	month       uint64
+	dayOfWeek   uint64
+	anyDayMonth bool
+	anyDayWeek  bool
+}
+
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	finalExpr := expr
+	if shortcut, ok := cronShortcuts[strings.ToLower(expr)]; ok {
+		finalExpr = shortcut
+	}
+	parts := strings.Fields(finalExpr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("Invalid cron expression '%s': expected 5 fields, got %d", expr, len(parts))
+	}
+	bits := make([]uint64, len(parts))
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cron expression '%s': %s", expr, err.Error())
+		}
+		bits[i] = b
+	}
+	// 7 is also sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	cron := &CronSchedule{
+		expr:        expr,
+		minute:      bits[0],
+		hour:        bits[1],
+		dayOfMonth:  bits[2],
+		month:       bits[3],
+		dayOfWeek:   bits[4],
+		anyDayMonth: parts[2] == "*",
+		anyDayWeek:  parts[4] == "*",
+	}
+	// e.g.: 30th february
+	if cron.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("Invalid cron expression '%s': it never matches", expr)
+	}
+	return cron, nil
+}
+
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	max := bounds.max
+	if bounds.max == 6 {
+		max = 7
+	}
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i != -1 {
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in '%s'", item)
+			}
+			item = item[:i]
+		}
+		start, end := bounds.min, bounds.max
+		if item != "*" {
+			rangeParts := strings.SplitN(item, "-", 2)
+			var err error
+			start, err = strconv.Atoi(rangeParts[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", item)
+			}
+			end = start
+			if len(rangeParts) == 2 {
+				end, err = strconv.Atoi(rangeParts[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid value '%s'", item)
+				}
+			} else if step > 1 {
+				end = bounds.max
+			}
+		}
+		if start < bounds.min || end > max || start > end {
+			return 0, fmt.Errorf("value '%s' out of range [%d-%d]", item, bounds.min, bounds.max)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c CronSchedule) String() string {
+	return c.expr
+}
+
+func (c CronSchedule) matchDay(t time.Time) bool {
+	domMatch := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	// as in standard cron, when both day fields are restricted only one of them need to match
+	if !c.anyDayMonth && !c.anyDayWeek {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next returns the first time after t matching the cron expression.
+func (c CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// a cron expression always match in less than 5 years (29th february on a specific weekday)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
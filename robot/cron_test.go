@@ -0,0 +1,124 @@
+package robot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// 1st january 2019 is a tuesday
+	from := time.Date(2019, 1, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"*/15 * * * *", time.Date(2019, 1, 1, 10, 45, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2019, 1, 2, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2019, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 9,18 * * *", time.Date(2019, 1, 1, 18, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2019, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2019, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// when both day fields are restricted, one of them must match: sunday comes before 1st of february
+		{"0 0 1 * 0", time.Date(2019, 1, 6, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		cron, err := ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("'%s': unexpected error: %s", test.expr, err.Error())
+			continue
+		}
+		next := cron.Next(from)
+		if !next.Equal(test.expected) {
+			t.Errorf("'%s': expected next run at %s, got %s", test.expr, test.expected, next)
+		}
+	}
+}
+
+func TestCronNextIsAfterGivenTime(t *testing.T) {
+	cron, err := ParseCron("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2019, 1, 1, 10, 30, 20, 0, time.UTC)
+	expected := time.Date(2019, 1, 1, 10, 31, 0, 0, time.UTC)
+	if next := cron.Next(from); !next.Equal(expected) {
+		t.Errorf("expected next run at %s, got %s", expected, next)
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	exprs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"a * * * *",
+		"5-1 * * * *",
+		"@never",
+		// days which never exist
+		"0 0 30 2 *",
+		"0 0 31 4 *",
+	}
+	for _, expr := range exprs {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("'%s': expected cron expression to be refused", expr)
+		}
+	}
+}
+
+func TestCronString(t *testing.T) {
+	cron, err := ParseCron(" @Weekly ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cron.String() != "@Weekly" {
+		t.Errorf("expected expression to be kept as given, got '%s'", cron.String())
+	}
+}
+
+func TestFireJobRemovesNeverMatchingCron(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+	g := NewGubot()
+	g.store = store
+	job := ScheduledJob{Cron: "0 0 30 2 *", NextRun: time.Now(), Type: string(Tsend), Message: "never", Envelop: "{}"}
+	store.Create(&job)
+
+	g.fireJob(job, time.Now())
+	var count int
+	store.Model(&ScheduledJob{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected job which never matches to be removed, %d job left", count)
+	}
+}
+
+func TestFireJobDoesNotRestoreCancelledJob(t *testing.T) {
+	store, cleanup := newTestStore(t)
+	defer cleanup()
+	g := NewGubot()
+	g.store = store
+	envelop := Envelop{ChannelId: "channel1"}
+	job, err := g.ScheduleEvery(envelop, time.Minute, Tsend, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.CancelJob(envelop, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.fireJob(job, time.Now())
+	var count int
+	store.Unscoped().Model(&ScheduledJob{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected cancelled job to stay deleted, %d job left", count)
+	}
+}
@@ -1,6 +1,9 @@
 package robot
 
-import "github.com/jinzhu/gorm"
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
 
 type User struct {
 	gorm.Model
@@ -12,8 +15,9 @@ type User struct {
 type RemoteScript struct {
 	gorm.Model
 	Script
-	Url  string `json:"url"`
-	Type string `json:"type"`
+	Url              string `json:"url"`
+	Type             string `json:"type"`
+	TimeoutInSeconds int    `json:"timeout_in_seconds"`
 }
 
 func (r RemoteScript) ToScript() Script {
@@ -21,9 +25,13 @@ func (r RemoteScript) ToScript() Script {
 		Name:             r.Name,
 		Description:      r.Description,
 		Example:          r.Example,
+		Category:         r.Category,
+		Source:           SourceRemote,
 		Matcher:          r.Matcher,
+		MatcherType:      r.MatcherType,
 		TriggerOnMention: r.TriggerOnMention,
 		Type:             TypeScript(r.Type),
+		Timeout:          time.Duration(r.TimeoutInSeconds) * time.Second,
 	}
 }
 
@@ -32,3 +40,36 @@ type SlashCommandToken struct {
 	CommandName string
 	AdapterName string
 }
+
+type BrainEntry struct {
+	gorm.Model
+	Scope     string `gorm:"unique_index:idx_brain_entry"`
+	Key       string `gorm:"unique_index:idx_brain_entry"`
+	Value     string `gorm:"type:text"`
+	ExpiresAt *time.Time
+}
+
+type ScheduledJob struct {
+	gorm.Model
+	Cron     string        `json:"cron,omitempty"`
+	Interval time.Duration `json:"interval,omitempty"`
+	NextRun  time.Time     `json:"next_run" gorm:"index"`
+	Type     string        `json:"type"`
+	Message  string        `json:"message" gorm:"type:text"`
+	Channel  string        `json:"channel" gorm:"index"`
+	Owner    string        `json:"owner"`
+	Envelop  string        `json:"-" gorm:"type:text"`
+}
+
+// ActionToken is an interactive element of a message sent, its signed id is given back by adapters in callbacks.
+type ActionToken struct {
+	ID     string `gorm:"primary_key"`
+	Action string
+	Value  string `gorm:"type:text"`
+	// Channel is the channel where message was sent, action can only be triggered from it
+	Channel string
+	// Recipient is the user who received message in direct, only this user can trigger action
+	Recipient string
+	CreatedAt time.Time
+	ExpiresAt *time.Time `gorm:"index"`
+}
@@ -0,0 +1,196 @@
+package robot
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DIALOG_DEFAULT_TIMEOUT = 5 * time.Minute
+
+var DialogDefaultCancelWords = []string{"cancel", "stop", "abort"}
+
+// DialogHandler receive the next message sent by a user engaged in a dialog,
+// it can call Ask again to continue the conversation.
+type DialogHandler func(envelop Envelop) ([]string, error)
+
+type Dialog struct {
+	Handler        DialogHandler
+	Type           TypeScript
+	Timeout        time.Duration
+	CancelWords    []string
+	CancelMessage  string
+	TimeoutMessage string
+	Sanitizer      func(text string) string
+}
+
+type pendingDialog struct {
+	dialog  Dialog
+	envelop Envelop
+	timer   *time.Timer
+}
+
+type dialogs struct {
+	pending map[string]*pendingDialog
+	mutex   *sync.Mutex
+}
+
+func newDialogs() *dialogs {
+	return &dialogs{
+		pending: make(map[string]*pendingDialog),
+		mutex:   new(sync.Mutex),
+	}
+}
+
+func dialogKey(envelop Envelop) string {
+	user := envelop.User.Id
+	if user == "" {
+		user = envelop.User.Name
+	}
+	channel := envelop.ChannelId
+	if channel == "" {
+		channel = envelop.ChannelName
+	}
+	return user + "|" + channel
+}
+
+func (d *dialogs) set(key string, pending *pendingDialog) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if old, ok := d.pending[key]; ok {
+		old.timer.Stop()
+	}
+	d.pending[key] = pending
+}
+
+func (d *dialogs) pop(key string) *pendingDialog {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	pending, ok := d.pending[key]
+	if !ok {
+		return nil
+	}
+	pending.timer.Stop()
+	delete(d.pending, key)
+	return pending
+}
+
+func (d *dialogs) clear() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for key, pending := range d.pending {
+		pending.timer.Stop()
+		delete(d.pending, key)
+	}
+}
+
+func (d *dialogs) has(key string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	_, ok := d.pending[key]
+	return ok
+}
+
+// Ask register a follow-up handler for the user and channel given in envelop,
+// the next message received from them will be given to this handler instead of scripts.
+func (g *Gubot) Ask(envelop Envelop, dialog Dialog) error {
+	if dialog.Handler == nil {
+		return errors.New("Dialog can't have an empty handler.")
+	}
+	if envelop.User.Id == "" && envelop.User.Name == "" {
+		return errors.New("You must provide a user id or a user name in envelop to start a dialog")
+	}
+	if dialog.Type == "" {
+		dialog.Type = Trespond
+	}
+	if dialog.Timeout <= 0 {
+		dialog.Timeout = DIALOG_DEFAULT_TIMEOUT
+	}
+	if dialog.CancelWords == nil {
+		dialog.CancelWords = DialogDefaultCancelWords
+	}
+	if dialog.Sanitizer == nil {
+		dialog.Sanitizer = SanitizeDefault
+	}
+	key := dialogKey(envelop)
+	pending := &pendingDialog{
+		dialog:  dialog,
+		envelop: envelop,
+	}
+	pending.timer = time.AfterFunc(dialog.Timeout, func() {
+		if g.dialogs.pop(key) != pending {
+			return
+		}
+		log.Debugf("Dialog with '%s' timed out.", key)
+		if dialog.TimeoutMessage == "" {
+			return
+		}
+		err := g.sendByType(pending.envelop, dialog.Type, dialog.TimeoutMessage)
+		if err != nil {
+			log.Error("Error when sending dialog timeout message: " + err.Error())
+		}
+	})
+	g.dialogs.set(key, pending)
+	log.Debugf("Dialog with '%s' is waiting for an answer.", key)
+	return nil
+}
+
+// CancelDialog remove the pending dialog for user and channel given in envelop,
+// it returns false if there was no dialog.
+func (g *Gubot) CancelDialog(envelop Envelop) bool {
+	return g.dialogs.pop(dialogKey(envelop)) != nil
+}
+
+func (g *Gubot) InDialog(envelop Envelop) bool {
+	return g.dialogs.has(dialogKey(envelop))
+}
+
+// receiveDialog give envelop to the pending dialog if one exists and returns true when envelop was consumed.
+func (g *Gubot) receiveDialog(envelop Envelop) bool {
+	if envelop.User.Id == "" && envelop.User.Name == "" {
+		return false
+	}
+	pending := g.dialogs.pop(dialogKey(envelop))
+	if pending == nil {
+		return false
+	}
+	dialog := pending.dialog
+	envelop.Message = dialog.Sanitizer(envelop.Message)
+	for _, word := range dialog.CancelWords {
+		if !strings.EqualFold(envelop.Message, word) {
+			continue
+		}
+		log.Debugf("Dialog with '%s' cancelled.", dialogKey(envelop))
+		if dialog.CancelMessage == "" {
+			return true
+		}
+		err := g.sendByType(envelop, dialog.Type, dialog.CancelMessage)
+		if err != nil {
+			log.Error("Error when sending dialog cancel message: " + err.Error())
+		}
+		return true
+	}
+	messages, err := dialog.Handler(envelop)
+	if err != nil {
+		log.Error(fmt.Sprintf("Error on dialog with '%s': %s", dialogKey(envelop), err.Error()))
+		return true
+	}
+	err = g.sendByType(envelop, dialog.Type, messages...)
+	if err != nil {
+		log.Error("Error when sending dialog messages: " + err.Error())
+	}
+	return true
+}
+
+func (g *Gubot) sendByType(envelop Envelop, typeScript TypeScript, messages ...string) error {
+	switch typeScript {
+	case Trespond:
+		return g.RespondMessages(envelop, messages...)
+	case Tdirect:
+		return g.SendDirectMessages(envelop, messages...)
+	}
+	return g.SendMessages(envelop, messages...)
+}
@@ -0,0 +1,146 @@
+package robot
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordAdapterConfig struct{}
+
+// recordAdapter keep messages sent by gubot, replies are prefixed by `reply: `.
+type recordAdapter struct {
+	mutex    *sync.Mutex
+	messages []string
+}
+
+func newRecordAdapter() *recordAdapter {
+	return &recordAdapter{
+		mutex:    new(sync.Mutex),
+		messages: make([]string, 0),
+	}
+}
+
+func (a *recordAdapter) Name() string {
+	return "record"
+}
+
+func (a *recordAdapter) Send(envelop Envelop, message string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.messages = append(a.messages, message)
+	return nil
+}
+
+func (a *recordAdapter) Reply(envelop Envelop, message string) error {
+	return a.Send(envelop, "reply: "+message)
+}
+
+func (a *recordAdapter) Run(config interface{}, gubot *Gubot) error {
+	return nil
+}
+
+func (a *recordAdapter) Config() interface{} {
+	return recordAdapterConfig{}
+}
+
+func (a *recordAdapter) sent() []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return append([]string{}, a.messages...)
+}
+
+func newDialogTestGubot(t *testing.T) (*Gubot, *recordAdapter) {
+	g := NewGubot()
+	adp := newRecordAdapter()
+	g.RegisterAdapter(adp)
+	err := g.RegisterScript(Script{
+		Name:    "echo",
+		Matcher: "^echo (.+)$",
+		Function: func(envelop Envelop, subMatch [][]string) ([]string, error) {
+			return []string{subMatch[0][1]}, nil
+		},
+		Type: Tsend,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, adp
+}
+
+func TestDialogCaptureNextMessage(t *testing.T) {
+	g, adp := newDialogTestGubot(t)
+	bob := Envelop{User: UserEnvelop{Name: "bob"}, ChannelName: "general"}
+	var received []string
+	err := g.Ask(bob, Dialog{
+		Handler: func(envelop Envelop) ([]string, error) {
+			received = append(received, envelop.Message)
+			return []string{"got " + envelop.Message}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := Envelop{User: UserEnvelop{Name: "alice"}, ChannelName: "general", Message: "echo alice"}
+	g.Receive(alice)
+	answer := bob
+	answer.Message = "echo answer"
+	g.Receive(answer)
+	g.Receive(answer)
+
+	if !reflect.DeepEqual(received, []string{"echo answer"}) {
+		t.Errorf("expected dialog to capture only the next message of bob, got %v", received)
+	}
+	expected := []string{"alice", "reply: got echo answer", "answer"}
+	if !reflect.DeepEqual(adp.sent(), expected) {
+		t.Errorf("expected messages %v, got %v", expected, adp.sent())
+	}
+	if g.InDialog(bob) {
+		t.Error("expected dialog to be done after the answer")
+	}
+}
+
+func TestDialogCancel(t *testing.T) {
+	g, adp := newDialogTestGubot(t)
+	bob := Envelop{User: UserEnvelop{Name: "bob"}, ChannelName: "general", Message: "Cancel"}
+	called := false
+	g.Ask(bob, Dialog{
+		Handler: func(envelop Envelop) ([]string, error) {
+			called = true
+			return []string{}, nil
+		},
+		CancelMessage: "cancelled",
+	})
+	g.Receive(bob)
+
+	if called {
+		t.Error("expected handler to not be called on a cancel word")
+	}
+	if !reflect.DeepEqual(adp.sent(), []string{"reply: cancelled"}) {
+		t.Errorf("expected cancel message, got %v", adp.sent())
+	}
+}
+
+func TestDialogTimeout(t *testing.T) {
+	g, adp := newDialogTestGubot(t)
+	bob := Envelop{User: UserEnvelop{Name: "bob"}, ChannelName: "general", Message: "echo late"}
+	g.Ask(bob, Dialog{
+		Handler: func(envelop Envelop) ([]string, error) {
+			return []string{"too late"}, nil
+		},
+		Timeout:        10 * time.Millisecond,
+		TimeoutMessage: "timed out",
+	})
+	time.Sleep(50 * time.Millisecond)
+	if g.InDialog(bob) {
+		t.Error("expected dialog to be removed after timeout")
+	}
+	g.Receive(bob)
+
+	expected := []string{"reply: timed out", "late"}
+	if !reflect.DeepEqual(adp.sent(), expected) {
+		t.Errorf("expected messages %v, got %v", expected, adp.sent())
+	}
+}
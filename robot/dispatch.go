@@ -0,0 +1,124 @@
+package robot
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const DEFAULT_SCRIPT_WORKERS = 4
+
+type scriptRun struct {
+	script   Script
+	message  string
+	messages []string
+	err      error
+	latency  time.Duration
+}
+
+type dispatchResult struct {
+	toSends   []string
+	toReplies []string
+	toDirect  []string
+}
+
+func (r dispatchResult) isEmpty() bool {
+	return len(r.toSends) == 0 && len(r.toReplies) == 0 && len(r.toDirect) == 0
+}
+
+// SetScriptWorkers set the maximum number of scripts running at the same time for one envelop.
+func (g *Gubot) SetScriptWorkers(workers int) {
+	if workers <= 0 {
+		workers = DEFAULT_SCRIPT_WORKERS
+	}
+	g.scriptWorkers = workers
+}
+
+func (g Gubot) matchingScripts(envelop Envelop) []*scriptRun {
+	scripts := g.copyScripts()
+	runs := make([]*scriptRun, 0)
+	for _, typeScript := range []TypeScript{Tsend, Trespond, Tdirect} {
+		for _, script := range scripts.ListFromType(typeScript) {
+			if script.TriggerOnMention && envelop.NotMentioned {
+				continue
+			}
+			message := script.Sanitizer(envelop.Message)
+			if !script.match(message) {
+				continue
+			}
+			runs = append(runs, &scriptRun{
+				script:  script,
+				message: message,
+			})
+		}
+	}
+	return runs
+}
+
+// dispatch run all scripts matching envelop on a pool of workers,
+// messages are given back in the order of scripts registration whatever the time they take.
+func (g Gubot) dispatch(envelop Envelop) dispatchResult {
+	runs := g.matchingScripts(envelop)
+	workers := g.scriptWorkers
+	if workers <= 0 {
+		workers = DEFAULT_SCRIPT_WORKERS
+	}
+	if workers > len(runs) {
+		workers = len(runs)
+	}
+	runChan := make(chan *scriptRun)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for run := range runChan {
+				g.runScript(envelop, run)
+			}
+		}()
+	}
+	for _, run := range runs {
+		runChan <- run
+	}
+	close(runChan)
+	wg.Wait()
+
+	result := dispatchResult{
+		toSends:   make([]string, 0),
+		toReplies: make([]string, 0),
+		toDirect:  make([]string, 0),
+	}
+	for _, run := range runs {
+		g.Emit(GubotEvent{
+			Name:    EVENT_ROBOT_SCRIPT_EXECUTED,
+			Envelop: envelop,
+			Script:  run.script.Name,
+			Latency: run.latency,
+		})
+		if run.err != nil {
+			log.Error(fmt.Sprintf("Error on script '%s': %s", run.script.Name, run.err.Error()))
+			continue
+		}
+		switch run.script.Type {
+		case Tsend:
+			result.toSends = append(result.toSends, run.messages...)
+		case Trespond:
+			result.toReplies = append(result.toReplies, run.messages...)
+		case Tdirect:
+			result.toDirect = append(result.toDirect, run.messages...)
+		}
+	}
+	return result
+}
+
+func (g Gubot) runScript(envelop Envelop, run *scriptRun) {
+	log.Debugf("%s respond on envelop=%v", run.script.String(), envelop)
+	start := time.Now()
+	ctx, cancel := g.scriptContext(run.script)
+	defer cancel()
+	function := g.generateScriptFunction(run.script, g.scriptHandler(ctx, run.script))
+	run.messages, run.err = runWithContext(ctx, function, envelop, run.script.allSubMatch(run.message))
+	run.latency = time.Since(start)
+	log.WithField("latency", run.latency).Debugf("%s executed.", run.script.String())
+}
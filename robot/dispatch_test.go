@@ -0,0 +1,82 @@
+package robot
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatchKeepOrderOfScripts(t *testing.T) {
+	g := NewGubot()
+	g.SetScriptWorkers(2)
+	running := 0
+	maxRunning := 0
+	mutex := new(sync.Mutex)
+	for i := 0; i < 5; i++ {
+		delay := time.Duration(5-i) * 5 * time.Millisecond
+		name := fmt.Sprintf("script %d", i)
+		err := g.RegisterScript(Script{
+			Name:    name,
+			Matcher: "^go$",
+			Function: func(envelop Envelop, subMatch [][]string) ([]string, error) {
+				mutex.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mutex.Unlock()
+				time.Sleep(delay)
+				mutex.Lock()
+				running--
+				mutex.Unlock()
+				return []string{name + " " + envelop.Message}, nil
+			},
+			Type: Tsend,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := g.dispatch(Envelop{User: UserEnvelop{Name: "bob"}, Message: "go"})
+
+	expected := []string{"script 0 go", "script 1 go", "script 2 go", "script 3 go", "script 4 go"}
+	if !reflect.DeepEqual(result.toSends, expected) {
+		t.Errorf("expected messages in order of registration %v, got %v", expected, result.toSends)
+	}
+	if maxRunning != 2 {
+		t.Errorf("expected scripts to run on 2 workers, %d ran at the same time", maxRunning)
+	}
+}
+
+func TestDispatchKeepOrderOfUserMessages(t *testing.T) {
+	g := NewGubot()
+	adp := newRecordAdapter()
+	g.RegisterAdapter(adp)
+	err := g.RegisterScript(Script{
+		Name:    "slow first",
+		Matcher: "^(first|second)$",
+		Function: func(envelop Envelop, subMatch [][]string) ([]string, error) {
+			if envelop.Message == "first" {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return []string{envelop.Message}, nil
+		},
+		Type: Tsend,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob := Envelop{User: UserEnvelop{Name: "bob"}}
+	for _, message := range []string{"first", "second"} {
+		bob.Message = message
+		g.Receive(bob)
+	}
+
+	if !reflect.DeepEqual(adp.sent(), []string{"first", "second"}) {
+		t.Errorf("expected messages of a user to be answered in order, got %v", adp.sent())
+	}
+}
@@ -5,6 +5,8 @@ type Envelop struct {
 	ChannelName  string                 `json:"channel_name"`
 	ChannelId    string                 `json:"channel_id"`
 	IconUrl      string                 `json:"icon_url"`
+	MessageId    string                 `json:"message_id"`
+	ThreadId     string                 `json:"thread_id"`
 	NotMentioned bool                   `json:"not_mentioned"`
 	User         UserEnvelop            `json:"user"`
 	Properties   map[string]interface{} `json:"properties"`
@@ -18,3 +20,8 @@ type UserEnvelop struct {
 	ChannelId   string                 `json:"channel_id"`
 	Properties  map[string]interface{} `json:"properties"`
 }
+
+// InThread returns true if message was sent in a thread, replies will be sent in the same thread.
+func (e Envelop) InThread() bool {
+	return e.ThreadId != ""
+}
@@ -2,11 +2,13 @@ package robot
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"os"
 	"os/exec"
+	"time"
 )
 
 const (
@@ -21,8 +23,11 @@ type ProgramDefinition struct {
 	Name             string `json:"name"`
 	Description      string `json:"description"`
 	Example          string `json:"example"`
+	Category         string `json:"category"`
 	Matcher          string `json:"matcher"`
+	MatcherType      string `json:"matcher_type"`
 	TriggerOnMention bool   `json:"trigger_on_mention"`
+	TimeoutInSeconds int    `json:"timeout_in_seconds"`
 }
 
 type ProgramAction struct {
@@ -37,7 +42,11 @@ func (d ProgramDefinition) ToScript() Script {
 		Description:      d.Description,
 		TriggerOnMention: d.TriggerOnMention,
 		Matcher:          d.Matcher,
+		MatcherType:      d.MatcherType,
 		Example:          d.Example,
+		Category:         d.Category,
+		Source:           SourceProgram,
+		Timeout:          time.Duration(d.TimeoutInSeconds) * time.Second,
 	}
 }
 
@@ -52,7 +61,9 @@ func (g *Gubot) registerProgramScripts(programs []ProgramScript) error {
 }
 
 func (g *Gubot) registerProgramScript(program ProgramScript) error {
-	bufResp, err := sendToProgram(program, ProgramAction{
+	ctx, cancel := g.scriptContext(Script{})
+	defer cancel()
+	bufResp, err := sendToProgram(ctx, program, ProgramAction{
 		Action: ProgramActionRegister,
 	})
 	if err != nil {
@@ -65,8 +76,8 @@ func (g *Gubot) registerProgramScript(program ProgramScript) error {
 	}
 	for _, sd := range scriptDefinitions {
 		script := sd.ToScript()
-		script.Function = func(envelop Envelop, submatch [][]string) ([]string, error) {
-			return g.sendEnvelopToProgram(envelop, submatch, program)
+		script.ContextFunction = func(ctx context.Context, envelop Envelop, submatch [][]string) ([]string, error) {
+			return g.sendEnvelopToProgram(ctx, envelop, submatch, program)
 		}
 		err := g.RegisterScript(script)
 		if err != nil {
@@ -76,7 +87,7 @@ func (g *Gubot) registerProgramScript(program ProgramScript) error {
 	return nil
 }
 
-func (g *Gubot) sendEnvelopToProgram(envelop Envelop, subMatch [][]string, program ProgramScript) ([]string, error) {
+func (g *Gubot) sendEnvelopToProgram(ctx context.Context, envelop Envelop, subMatch [][]string, program ProgramScript) ([]string, error) {
 	dataToSend := struct {
 		Envelop
 		SubMatch [][]string `json:"sub_match"`
@@ -84,7 +95,7 @@ func (g *Gubot) sendEnvelopToProgram(envelop Envelop, subMatch [][]string, progr
 
 	messages := make([]string, 0)
 
-	bufResp, err := sendToProgram(program, ProgramAction{
+	bufResp, err := sendToProgram(ctx, program, ProgramAction{
 		Action: ProgramActionReceive,
 		Data:   dataToSend,
 	})
@@ -95,12 +106,12 @@ func (g *Gubot) sendEnvelopToProgram(envelop Envelop, subMatch [][]string, progr
 	return messages, err
 }
 
-func sendToProgram(program ProgramScript, data interface{}) (reader io.Reader, err error) {
+func sendToProgram(ctx context.Context, program ProgramScript, data interface{}) (reader io.Reader, err error) {
 	jsonMessage, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
-	cmd := exec.Command(program.Path, program.Args...)
+	cmd := exec.CommandContext(ctx, program.Path, program.Args...)
 
 	bufStdin := bytes.NewBuffer(jsonMessage)
 	bufResp := &bytes.Buffer{}
@@ -1,10 +1,12 @@
 package robot
 
 import (
+	"context"
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	"github.com/olebedev/emitter"
 	"net/http"
+	"time"
 )
 
 var robot *Gubot = NewGubot()
@@ -54,9 +56,39 @@ func GetConfig(config interface{}) error {
 func Store() *gorm.DB {
 	return robot.Store()
 }
+func GetBrain() Brain {
+	return robot.Brain()
+}
+func SetBrain(brain Brain) {
+	robot.SetBrain(brain)
+}
 func Receive(envelop Envelop) {
 	robot.Receive(envelop)
 }
+func Ask(envelop Envelop, dialog Dialog) error {
+	return robot.Ask(envelop, dialog)
+}
+func CancelDialog(envelop Envelop) bool {
+	return robot.CancelDialog(envelop)
+}
+func InDialog(envelop Envelop) bool {
+	return robot.InDialog(envelop)
+}
+func ScheduleIn(envelop Envelop, delay time.Duration, typeScript TypeScript, message string) (ScheduledJob, error) {
+	return robot.ScheduleIn(envelop, delay, typeScript, message)
+}
+func ScheduleEvery(envelop Envelop, interval time.Duration, typeScript TypeScript, message string) (ScheduledJob, error) {
+	return robot.ScheduleEvery(envelop, interval, typeScript, message)
+}
+func ScheduleCron(envelop Envelop, expr string, typeScript TypeScript, message string) (ScheduledJob, error) {
+	return robot.ScheduleCron(envelop, expr, typeScript, message)
+}
+func CancelJob(envelop Envelop, id uint) error {
+	return robot.CancelJob(envelop, id)
+}
+func ListJobs(envelop Envelop) ([]ScheduledJob, error) {
+	return robot.ListJobs(envelop)
+}
 func SendMessages(envelop Envelop, toSends ...string) error {
 	return robot.SendMessages(envelop, toSends...)
 }
@@ -66,6 +98,15 @@ func SendDirectMessages(envelop Envelop, toSends ...string) error {
 func RespondMessages(envelop Envelop, toReplies ...string) error {
 	return robot.RespondMessages(envelop, toReplies...)
 }
+func SendRichMessages(envelop Envelop, toSends ...Message) error {
+	return robot.SendRichMessages(envelop, toSends...)
+}
+func SendDirectRichMessages(envelop Envelop, toSends ...Message) error {
+	return robot.SendDirectRichMessages(envelop, toSends...)
+}
+func RespondRichMessages(envelop Envelop, toReplies ...Message) error {
+	return robot.RespondRichMessages(envelop, toReplies...)
+}
 func LoadStore() error {
 	return robot.LoadStore()
 }
@@ -79,6 +120,15 @@ func ApiAuthMatcher() func(http.Handler) http.Handler {
 func SlashCommandUrl() string {
 	return robot.SlashCommandUrl()
 }
+func ActionUrl(adapterName string) string {
+	return robot.ActionUrl(adapterName)
+}
+func RegisterAction(name string, handler ActionHandler) error {
+	return robot.RegisterAction(name, handler)
+}
+func UnregisterAction(name string) {
+	robot.UnregisterAction(name)
+}
 
 func IconUrl() string {
 	return robot.IconUrl()
@@ -140,6 +190,9 @@ func Host() string {
 func GetScripts() []Script {
 	return robot.GetScripts()
 }
+func GetSlashCommands() []SlashCommand {
+	return robot.GetSlashCommands()
+}
 func IsValidToken(tokenToCheck string) bool {
 	return robot.IsValidToken(tokenToCheck)
 }
@@ -149,6 +202,12 @@ func SetLogLevel(level string) {
 func Start(addr string) error {
 	return robot.Start(addr)
 }
+func Stop(ctx context.Context) error {
+	return robot.Stop(ctx)
+}
+func Shutdown() error {
+	return robot.Shutdown()
+}
 func ToGubotEvent(event emitter.Event) GubotEvent {
 	return event.Args[0].(GubotEvent)
 }
@@ -13,14 +13,17 @@ import (
 const CONFIG_FILENAME = "config_gubot.yml"
 
 type GubotConfig struct {
-	Tokens         []string               `yaml:"tokens"`
-	LogLevel       string                 `yaml:"log_level"`
-	Name           string                 `yaml:"name"`
-	Host           string                 `yaml:"host"`
-	SkipInsecure   bool                   `yaml:"skip_insecure"`
-	ProgramScripts []ProgramScript        `yaml:"program_scripts"`
-	Services       []ServiceLocal         `yaml:"services"`
-	Config         map[string]interface{} `yaml:"config" cloud:"-"`
+	Tokens                 []string               `yaml:"tokens"`
+	LogLevel               string                 `yaml:"log_level"`
+	Name                   string                 `yaml:"name"`
+	Host                   string                 `yaml:"host"`
+	SkipInsecure           bool                   `yaml:"skip_insecure"`
+	ProgramScripts         []ProgramScript        `yaml:"program_scripts"`
+	ScriptTimeoutInSeconds int                    `yaml:"script_timeout_in_seconds"`
+	ScriptWorkers          int                    `yaml:"script_workers"`
+	ActionsSecret          string                 `yaml:"actions_secret"`
+	Services               []ServiceLocal         `yaml:"services"`
+	Config                 map[string]interface{} `yaml:"config" cloud:"-"`
 }
 
 type ProgramScript struct {
@@ -109,6 +112,9 @@ func (c *ConfFileCloudEnv) Load() error {
 	conf.Config["tokens"] = conf.Tokens
 	conf.Config["log_level"] = conf.LogLevel
 	conf.Config["program_scripts"] = conf.ProgramScripts
+	conf.Config["script_timeout_in_seconds"] = conf.ScriptTimeoutInSeconds
+	conf.Config["script_workers"] = conf.ScriptWorkers
+	conf.Config["actions_secret"] = conf.ActionsSecret
 
 	confMap := conf.Config
 	for key, value := range confMap {
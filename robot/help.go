@@ -0,0 +1,219 @@
+package robot
+
+import (
+	"fmt"
+	"github.com/ArthurHlt/gubot/adapter"
+	"sort"
+	"strings"
+)
+
+// HELP_PAGE_SIZE is the maximum size of a help message, it fits in limits of all adapters.
+const HELP_PAGE_SIZE = 3000
+
+const helpDefaultCategory = "general"
+
+var helpSources = []string{SourceBuiltin, SourceRemote, SourceProgram}
+
+// ScriptAuthorizer can be implemented by a middleware given to Use, scripts that a user can't use are hidden in help.
+type ScriptAuthorizer interface {
+	CanUseScript(script Script, envelop Envelop) bool
+}
+
+func (g *Gubot) InitializeHelp() {
+	g.RegisterScript(Script{
+		Name:        "help",
+		Description: "Provide the list of available scripts, details of a script with `help <script>` or search with `help search <term>`",
+		Category:    "help",
+		Command: &Command{
+			Name: "help",
+			Args: []CommandArg{
+				{Name: "query", Type: ArgText, Optional: true, Description: "name of a script or `search <term>`"},
+			},
+			Flags: []CommandFlag{
+				{Name: "page", Short: "p", Type: ArgInt, Default: 1, Description: "page to show"},
+			},
+		},
+		ArgsFunction:     g.help,
+		TriggerOnMention: true,
+		Type:             Tsend,
+	})
+}
+
+func (g *Gubot) help(envelop Envelop, args Args) ([]string, error) {
+	scripts := g.visibleScripts(envelop)
+	query := strings.TrimSpace(args.String("query"))
+	page := args.Int("page")
+	if query == "" {
+		return []string{helpPage(helpList("Available scripts:", scripts), page, "help")}, nil
+	}
+	lowerQuery := strings.ToLower(query)
+	if !strings.HasPrefix(lowerQuery, "search ") {
+		found := make(Scripts, 0)
+		for _, script := range scripts {
+			if strings.EqualFold(script.Name, query) {
+				found = append(found, script)
+			}
+		}
+		if len(found) > 0 {
+			return []string{helpPage(helpDetails(found), page, "help "+query)}, nil
+		}
+	}
+	term := strings.TrimSpace(strings.TrimPrefix(lowerQuery, "search "))
+	found := searchScripts(scripts, term)
+	if len(found) == 0 {
+		return []string{fmt.Sprintf("No script found for `%s`.", term)}, nil
+	}
+	return []string{helpPage(
+		helpList(fmt.Sprintf("Scripts found for `%s`:", term), found),
+		page,
+		"help search "+term,
+	)}, nil
+}
+
+// visibleScripts give scripts that user in envelop is authorized to use.
+func (g *Gubot) visibleScripts(envelop Envelop) Scripts {
+	scripts := g.copyScripts()
+	visible := make(Scripts, 0)
+	for _, script := range scripts {
+		if script.Name == REMOTE_SCRIPTS_NAME || !g.canUseScript(script, envelop) {
+			continue
+		}
+		visible = append(visible, script)
+	}
+	return visible
+}
+
+func (g *Gubot) canUseScript(script Script, envelop Envelop) bool {
+	for _, authorizer := range g.scriptAuthorizers {
+		if !authorizer.CanUseScript(script, envelop) {
+			return false
+		}
+	}
+	return true
+}
+
+func searchScripts(scripts Scripts, term string) Scripts {
+	found := make(Scripts, 0)
+	for _, script := range scripts {
+		text := strings.ToLower(strings.Join([]string{
+			script.Name, script.Description, script.Category, script.Example,
+		}, " "))
+		if strings.Contains(text, term) {
+			found = append(found, script)
+		}
+	}
+	return found
+}
+
+func scriptCategory(script Script) string {
+	if script.Category == "" {
+		return helpDefaultCategory
+	}
+	return strings.ToLower(script.Category)
+}
+
+// helpList group scripts by source and then by category.
+func helpList(title string, scripts Scripts) string {
+	list := title + "\n"
+	for _, source := range helpSources {
+		categories := make(map[string][]string)
+		for _, script := range scripts {
+			scriptSource := script.Source
+			if scriptSource == "" {
+				scriptSource = SourceBuiltin
+			}
+			if scriptSource != source {
+				continue
+			}
+			category := scriptCategory(script)
+			categories[category] = append(categories[category], helpLine(script))
+		}
+		if len(categories) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(categories))
+		for name := range categories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		list += fmt.Sprintf("\n**%s scripts**\n", strings.Title(source))
+		for _, name := range names {
+			list += fmt.Sprintf("_%s_\n", strings.Title(name))
+			list += strings.Join(categories[name], "\n") + "\n"
+		}
+	}
+	list += "\n`*`: Script will be only triggered when talking explicitly to the bot."
+	return list
+}
+
+func helpLine(script Script) string {
+	line := fmt.Sprintf("- %s", strings.Title(script.Name))
+	if script.TriggerOnMention {
+		line += "*"
+	}
+	if script.Command != nil {
+		line += " -- usage: `" + script.Command.Usage() + "`"
+	} else if script.Example == "" {
+		line += " -- " + scriptMatcherType(script) + ": `" + script.Matcher + "`"
+	} else {
+		line += " -- e.g.: `" + script.Example + "`"
+	}
+	if script.Description != "" {
+		line += " -- " + strings.Title(script.Description)
+	}
+	return line
+}
+
+func scriptMatcherType(script Script) string {
+	if script.MatcherType == "" {
+		return MatcherRegex
+	}
+	return script.MatcherType
+}
+
+func helpDetails(scripts Scripts) string {
+	details := make([]string, 0, len(scripts))
+	for _, script := range scripts {
+		detail := fmt.Sprintf("**%s**", strings.Title(script.Name))
+		if script.Description != "" {
+			detail += "\n" + strings.Title(script.Description)
+		}
+		source := script.Source
+		if source == "" {
+			source = SourceBuiltin
+		}
+		detail += fmt.Sprintf("\nCategory: %s -- Source: %s -- Type: %s", scriptCategory(script), source, script.Type)
+		if script.TriggerOnMention {
+			detail += "\nOnly triggered when talking explicitly to the bot."
+		}
+		if script.Command != nil {
+			detail += "\n" + script.Command.Help()
+		} else {
+			detail += "\nMatcher (" + scriptMatcherType(script) + "): `" + script.Matcher + "`"
+		}
+		if script.Example != "" {
+			detail += "\nExample: `" + script.Example + "`"
+		}
+		details = append(details, detail)
+	}
+	return strings.Join(details, "\n\n")
+}
+
+// helpPage split text in pages of HELP_PAGE_SIZE and give the asked one with a footer to navigate.
+func helpPage(text string, page int, command string) string {
+	pages := adapter.TruncateMessage(text, HELP_PAGE_SIZE)
+	if len(pages) == 1 {
+		return pages[0]
+	}
+	if page < 1 {
+		page = 1
+	}
+	if page > len(pages) {
+		page = len(pages)
+	}
+	footer := fmt.Sprintf("\n\n_Page %d/%d", page, len(pages))
+	if page < len(pages) {
+		footer += fmt.Sprintf(", use `%s --page=%d` to see the next page", command, page+1)
+	}
+	return pages[page-1] + footer + "_"
+}
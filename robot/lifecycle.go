@@ -0,0 +1,154 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DEFAULT_SHUTDOWN_TIMEOUT = 30 * time.Second
+
+type lifecycle struct {
+	inflight   *sync.WaitGroup
+	stopping   int32
+	stopped    chan struct{}
+	stopOnce   *sync.Once
+	websockets map[*websocket.Conn]struct{}
+	mutex      *sync.Mutex
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		inflight:   &sync.WaitGroup{},
+		stopped:    make(chan struct{}),
+		stopOnce:   new(sync.Once),
+		websockets: make(map[*websocket.Conn]struct{}),
+		mutex:      new(sync.Mutex),
+	}
+}
+
+// IsStopping returns true when gubot is stopping or stopped, envelops received are then dropped.
+func (g Gubot) IsStopping() bool {
+	return atomic.LoadInt32(&g.lifecycle.stopping) == 1
+}
+
+// acquire register an envelop in flight, it returns false if gubot is stopping.
+func (g *Gubot) acquire() bool {
+	g.lifecycle.mutex.Lock()
+	defer g.lifecycle.mutex.Unlock()
+	if g.IsStopping() {
+		return false
+	}
+	g.lifecycle.inflight.Add(1)
+	return true
+}
+
+func (g *Gubot) release() {
+	g.lifecycle.inflight.Done()
+}
+
+func (g *Gubot) trackWebSocket(ws *websocket.Conn) {
+	g.lifecycle.mutex.Lock()
+	defer g.lifecycle.mutex.Unlock()
+	g.lifecycle.websockets[ws] = struct{}{}
+}
+
+func (g *Gubot) untrackWebSocket(ws *websocket.Conn) {
+	g.lifecycle.mutex.Lock()
+	defer g.lifecycle.mutex.Unlock()
+	delete(g.lifecycle.websockets, ws)
+}
+
+func (g *Gubot) closeWebSockets() {
+	g.lifecycle.mutex.Lock()
+	defer g.lifecycle.mutex.Unlock()
+	for ws := range g.lifecycle.websockets {
+		ws.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "gubot is stopping"),
+			time.Now().Add(time.Second),
+		)
+		ws.Close()
+		delete(g.lifecycle.websockets, ws)
+	}
+}
+
+func (g *Gubot) waitInflight(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.lifecycle.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("Timeout when waiting for in-flight messages: " + ctx.Err().Error())
+	}
+}
+
+// Stop stop gubot gracefully: http server stop accepting requests, in-flight messages are drained,
+// adapters implementing StoppableAdapter are stopped, websocket clients are closed and store is flushed.
+// Scripts still running when ctx is done are cancelled.
+func (g *Gubot) Stop(ctx context.Context) error {
+	var result error
+	g.lifecycle.stopOnce.Do(func() {
+		defer close(g.lifecycle.stopped)
+		log.Info("Gubot is stopping...")
+		g.lifecycle.mutex.Lock()
+		atomic.StoreInt32(&g.lifecycle.stopping, 1)
+		g.lifecycle.mutex.Unlock()
+
+		if g.server != nil {
+			err := g.server.Shutdown(ctx)
+			if err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+		g.stopScheduler()
+		g.dialogs.clear()
+
+		err := g.waitInflight(ctx)
+		if err != nil {
+			result = multierror.Append(result, err)
+		}
+		g.cancel()
+
+		for _, adp := range g.adapters {
+			stoppable, ok := adp.(StoppableAdapter)
+			if !ok {
+				continue
+			}
+			err := stoppable.Stop(ctx)
+			if err != nil {
+				result = multierror.Append(result, errors.New("Error when stopping adapter '"+adp.Name()+"': "+err.Error()))
+			}
+		}
+
+		g.Emit(GubotEvent{
+			Name: EVENT_ROBOT_STOPPED,
+		})
+		g.closeWebSockets()
+
+		if g.store != nil {
+			err := g.store.Close()
+			if err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+		log.Info("Gubot stopped.")
+	})
+	return result
+}
+
+// Shutdown stop gubot and wait at most DEFAULT_SHUTDOWN_TIMEOUT for in-flight messages.
+func (g *Gubot) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DEFAULT_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	return g.Stop(ctx)
+}
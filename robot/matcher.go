@@ -0,0 +1,289 @@
+package robot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	MatcherRegex   = "regex"
+	MatcherCommand = "command"
+	MatcherGlob    = "glob"
+	MatcherKeyword = "keyword"
+	MatcherFuzzy   = "fuzzy"
+)
+
+// Matcher decide if a message must trigger a script and give submatches to the script function,
+// submatches must be in the same form as regexp.FindAllStringSubmatch.
+type Matcher interface {
+	Match(content string) bool
+	SubMatch(content string) [][]string
+}
+
+// MatcherFactory create a matcher from the matcher expression given in a script.
+type MatcherFactory func(expr string) (Matcher, error)
+
+var matcherFactories = map[string]MatcherFactory{
+	MatcherRegex:   NewRegexMatcher,
+	MatcherCommand: NewCommandMatcher,
+	MatcherGlob:    NewGlobMatcher,
+	MatcherKeyword: NewKeywordMatcher,
+	MatcherFuzzy:   NewFuzzyMatcher,
+}
+var mutexMatcherFactories = new(sync.Mutex)
+
+// RegisterMatcherType let you add your own matcher type which can be used in script with MatcherType.
+func RegisterMatcherType(matcherType string, factory MatcherFactory) {
+	mutexMatcherFactories.Lock()
+	defer mutexMatcherFactories.Unlock()
+	matcherFactories[strings.ToLower(matcherType)] = factory
+}
+
+// NewMatcher create a matcher of the given type, regex is used when type is empty.
+func NewMatcher(matcherType, expr string) (Matcher, error) {
+	if matcherType == "" {
+		matcherType = MatcherRegex
+	}
+	mutexMatcherFactories.Lock()
+	factory, ok := matcherFactories[strings.ToLower(matcherType)]
+	mutexMatcherFactories.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown matcher type '%s'", matcherType)
+	}
+	return factory(expr)
+}
+
+type RegexMatcher struct {
+	regex *regexp.Regexp
+}
+
+func NewRegexMatcher(expr string) (Matcher, error) {
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexMatcher{regex}, nil
+}
+
+func (m RegexMatcher) Match(content string) bool {
+	return m.regex.MatchString(content)
+}
+
+func (m RegexMatcher) SubMatch(content string) [][]string {
+	return m.regex.FindAllStringSubmatch(content, -1)
+}
+
+const (
+	ArgString = "string"
+	ArgInt    = "int"
+	ArgFloat  = "float"
+	ArgBool   = "bool"
+	ArgText   = "text"
+)
+
+var argPatterns = map[string]string{
+	ArgString: `(\S+)`,
+	ArgInt:    `([-+]?\d+)`,
+	ArgFloat:  `([-+]?\d+(?:\.\d+)?)`,
+	ArgBool:   `(true|false|yes|no|on|off|1|0)`,
+	ArgText:   `(.+)`,
+}
+
+// CommandMatcher match a message word by word where arguments are given with `<name>` or `<name:type>`,
+// e.g.: `deploy <app> to <env>` or `scale <app> to <instances:int>`.
+// Available types are string (a single word, the default), int, float, bool and text (words until the end),
+// arguments are typed as the ones of a Command when script has an ArgsFunction.
+type CommandMatcher struct {
+	*RegexMatcher
+	expr string
+	Args []CommandArg
+}
+
+var commandArgRegex = regexp.MustCompile(`^<([a-zA-Z0-9_-]+)(?::([a-z]+))?>$`)
+
+func NewCommandMatcher(expr string) (Matcher, error) {
+	words := strings.Fields(expr)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("command matcher can't be empty")
+	}
+	args := make([]CommandArg, 0)
+	parts := make([]string, len(words))
+	for i, word := range words {
+		argMatch := commandArgRegex.FindStringSubmatch(word)
+		if argMatch == nil {
+			parts[i] = regexp.QuoteMeta(word)
+			continue
+		}
+		argType := argMatch[2]
+		if argType == "" {
+			argType = ArgString
+		}
+		pattern, ok := argPatterns[argType]
+		if !ok {
+			return nil, fmt.Errorf("unknown type '%s' for argument '%s'", argType, argMatch[1])
+		}
+		if argType == ArgText && i != len(words)-1 {
+			return nil, fmt.Errorf("argument '%s' of type text must be the last one", argMatch[1])
+		}
+		parts[i] = pattern
+		args = append(args, CommandArg{
+			Name: argMatch[1],
+			Type: argType,
+		})
+	}
+	regex, err := regexp.Compile(`(?i)^` + strings.Join(parts, `\s+`) + `$`)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandMatcher{
+		RegexMatcher: &RegexMatcher{regex},
+		expr:         strings.Join(words, " "),
+		Args:         args,
+	}, nil
+}
+
+// Parse give typed arguments from a message, they are converted as arguments of a Command.
+func (m CommandMatcher) Parse(content string) (Args, error) {
+	subMatch := m.regex.FindStringSubmatch(content)
+	if subMatch == nil {
+		return nil, fmt.Errorf("message doesn't match '%s'", m.expr)
+	}
+	args := make(Args)
+	for i, arg := range m.Args {
+		typed, err := convertArg(subMatch[i+1], arg.argType())
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for argument '%s': %s", arg.Name, err.Error())
+		}
+		args[arg.Name] = typed
+	}
+	return args, nil
+}
+
+func (m CommandMatcher) Help() string {
+	return "Usage: `" + m.expr + "`"
+}
+
+// GlobMatcher match the whole message where `*` match any characters and `?` match one character,
+// each wildcard is given as a submatch.
+type GlobMatcher struct {
+	*RegexMatcher
+}
+
+func NewGlobMatcher(expr string) (Matcher, error) {
+	pattern := ""
+	for _, c := range expr {
+		switch c {
+		case '*':
+			pattern += "(.*)"
+		case '?':
+			pattern += "(.)"
+		default:
+			pattern += regexp.QuoteMeta(string(c))
+		}
+	}
+	regex, err := regexp.Compile(`(?is)^` + pattern + `$`)
+	if err != nil {
+		return nil, err
+	}
+	return &GlobMatcher{&RegexMatcher{regex}}, nil
+}
+
+// KeywordMatcher match when all keywords (separated by spaces or commas) are found as words in the message,
+// keywords found are given as submatches.
+type KeywordMatcher struct {
+	keywords    []string
+	maxDistance func(keyword string) int
+}
+
+func splitKeywords(expr string) []string {
+	return strings.FieldsFunc(strings.ToLower(expr), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+func NewKeywordMatcher(expr string) (Matcher, error) {
+	keywords := splitKeywords(expr)
+	if len(keywords) == 0 {
+		return nil, fmt.Errorf("keyword matcher must have at least one keyword")
+	}
+	return &KeywordMatcher{
+		keywords: keywords,
+		maxDistance: func(string) int {
+			return 0
+		},
+	}, nil
+}
+
+// NewFuzzyMatcher act as keyword matcher but accept typos: one for each 4 letters in a keyword.
+func NewFuzzyMatcher(expr string) (Matcher, error) {
+	keywords := splitKeywords(expr)
+	if len(keywords) == 0 {
+		return nil, fmt.Errorf("fuzzy matcher must have at least one keyword")
+	}
+	return &KeywordMatcher{
+		keywords: keywords,
+		maxDistance: func(keyword string) int {
+			return len([]rune(keyword)) / 4
+		},
+	}, nil
+}
+
+func (m KeywordMatcher) findKeywords(content string) []string {
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !(r == '_' || r == '-' || r == '\'' ||
+			(r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r > 127)
+	})
+	found := make([]string, 0, len(m.keywords))
+	for _, keyword := range m.keywords {
+		max := m.maxDistance(keyword)
+		for _, word := range words {
+			if levenshtein(keyword, word) <= max {
+				found = append(found, word)
+				break
+			}
+		}
+	}
+	return found
+}
+
+func (m KeywordMatcher) Match(content string) bool {
+	return len(m.findKeywords(content)) == len(m.keywords)
+}
+
+func (m KeywordMatcher) SubMatch(content string) [][]string {
+	found := m.findKeywords(content)
+	if len(found) != len(m.keywords) {
+		return [][]string{}
+	}
+	return [][]string{append([]string{content}, found...)}
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,81 @@
+package robot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchers(t *testing.T) {
+	tests := []struct {
+		matcherType string
+		expr        string
+		content     string
+		matched     bool
+		subMatch    [][]string
+	}{
+		{MatcherRegex, "^hello (.+)$", "hello world", true, [][]string{{"hello world", "world"}}},
+		{"", "^hello$", "goodbye", false, nil},
+		{MatcherCommand, "deploy <app> to <env>", "Deploy myapp to  prod", true, [][]string{{"Deploy myapp to  prod", "myapp", "prod"}}},
+		{MatcherCommand, "scale <app> to <instances:int>", "scale myapp to many", false, nil},
+		{MatcherCommand, "scale <app> to <instances:int>", "scale myapp to -2", true, [][]string{{"scale myapp to -2", "myapp", "-2"}}},
+		{MatcherCommand, "say <words:text>", "say hello world", true, [][]string{{"say hello world", "hello world"}}},
+		{MatcherCommand, "deploy <app>", "deploy myapp now", false, nil},
+		{MatcherGlob, "open the * doors", "Open the pod bay doors", true, [][]string{{"Open the pod bay doors", "pod bay"}}},
+		{MatcherGlob, "file?.txt", "file1.txt", true, [][]string{{"file1.txt", "1"}}},
+		{MatcherGlob, "open the * doors", "open the doors please", false, nil},
+		{MatcherKeyword, "weather, tomorrow", "What's the Weather like tomorrow?", true, [][]string{{"What's the Weather like tomorrow?", "weather", "tomorrow"}}},
+		{MatcherKeyword, "weather tomorrow", "weather today", false, nil},
+		{MatcherKeyword, "weather", "weathers", false, nil},
+		{MatcherFuzzy, "weather tomorrow", "wether tomorow", true, [][]string{{"wether tomorow", "wether", "tomorow"}}},
+		{MatcherFuzzy, "weather", "leather", true, [][]string{{"leather", "leather"}}},
+		{MatcherFuzzy, "cat", "bat", false, nil},
+	}
+	for _, test := range tests {
+		matcher, err := NewMatcher(test.matcherType, test.expr)
+		if err != nil {
+			t.Errorf("%s '%s': unexpected error: %s", test.matcherType, test.expr, err.Error())
+			continue
+		}
+		if matcher.Match(test.content) != test.matched {
+			t.Errorf("%s '%s' on '%s': expected match to be %t", test.matcherType, test.expr, test.content, test.matched)
+			continue
+		}
+		if test.matched && !reflect.DeepEqual(matcher.SubMatch(test.content), test.subMatch) {
+			t.Errorf("%s '%s' on '%s': expected submatches %q, got %q",
+				test.matcherType, test.expr, test.content, test.subMatch, matcher.SubMatch(test.content))
+		}
+	}
+}
+
+func TestNewMatcherInvalid(t *testing.T) {
+	tests := []struct {
+		matcherType string
+		expr        string
+	}{
+		{"unknown", "hello"},
+		{MatcherRegex, "^(hello$"},
+		{MatcherCommand, ""},
+		{MatcherCommand, "deploy <app:date>"},
+		{MatcherCommand, "say <words:text> now"},
+		{MatcherKeyword, " , "},
+		{MatcherFuzzy, ""},
+	}
+	for _, test := range tests {
+		if _, err := NewMatcher(test.matcherType, test.expr); err == nil {
+			t.Errorf("%s '%s': expected matcher to be refused", test.matcherType, test.expr)
+		}
+	}
+}
+
+func TestRegisterMatcherType(t *testing.T) {
+	RegisterMatcherType("Exact", func(expr string) (Matcher, error) {
+		return NewRegexMatcher(`^\Q` + expr + `\E$`)
+	})
+	matcher, err := NewMatcher("exact", "a.b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matcher.Match("a.b") || matcher.Match("axb") {
+		t.Error("expected registered matcher type to be used")
+	}
+}
@@ -0,0 +1,252 @@
+package robot
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Message is a structured message, adapters implementing RichAdapter receive it as is
+// and other adapters receive its PlainText fallback.
+type Message struct {
+	// Text is the main text of the message, it is used when Markdown is empty or adapter doesn't support markdown
+	Text string `json:"text,omitempty"`
+	// Markdown is the main text of the message formatted in markdown
+	Markdown string          `json:"markdown,omitempty"`
+	Title    string          `json:"title,omitempty"`
+	TitleUrl string          `json:"title_url,omitempty"`
+	Color    string          `json:"color,omitempty"`
+	Fields   []MessageField  `json:"fields,omitempty"`
+	Buttons  []MessageButton `json:"buttons,omitempty"`
+	Menus    []MessageMenu   `json:"menus,omitempty"`
+	Images   []MessageImage  `json:"images,omitempty"`
+	Files    []MessageFile   `json:"files,omitempty"`
+	// ThreadId is a hint to post message in a thread, adapters without threads ignore it
+	ThreadId string `json:"thread_id,omitempty"`
+}
+
+type MessageField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
+
+type MessageButton struct {
+	Name  string `json:"name,omitempty"`
+	Text  string `json:"text"`
+	Url   string `json:"url,omitempty"`
+	Value string `json:"value,omitempty"`
+	// Style can be default, primary or danger
+	Style string `json:"style,omitempty"`
+	// Action is the name of a registered action called when button is clicked
+	Action string `json:"action,omitempty"`
+	// ActionId is set by gubot when message is sent, adapters must give it back in callbacks
+	ActionId string `json:"action_id,omitempty"`
+}
+
+// MessageMenu let user choose an option, the registered Action is called with the selected value.
+type MessageMenu struct {
+	Name     string              `json:"name,omitempty"`
+	Text     string              `json:"text"`
+	Value    string              `json:"value,omitempty"`
+	Options  []MessageMenuOption `json:"options"`
+	Action   string              `json:"action"`
+	ActionId string              `json:"action_id,omitempty"`
+}
+
+type MessageMenuOption struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+type MessageImage struct {
+	Url     string `json:"url"`
+	AltText string `json:"alt_text,omitempty"`
+}
+
+// MessageFile is a file to upload, adapters which can't upload show Url instead if given.
+type MessageFile struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type,omitempty"`
+	Content     []byte `json:"content,omitempty"`
+	Url         string `json:"url,omitempty"`
+}
+
+// RichAdapter is an adapter which can send structured messages.
+type RichAdapter interface {
+	SendRich(Envelop, Message) error
+	ReplyRich(Envelop, Message) error
+}
+
+// SendDirectRichAdapter is an adapter which can send structured messages as private message.
+type SendDirectRichAdapter interface {
+	SendDirectRich(Envelop, Message) error
+}
+
+// CodeBlock give a markdown code block to use in Markdown of a message.
+func CodeBlock(language, code string) string {
+	return "```" + language + "\n" + strings.TrimSuffix(code, "\n") + "\n```"
+}
+
+// Content give Markdown if set, Text otherwise.
+func (m Message) Content() string {
+	if m.Markdown != "" {
+		return m.Markdown
+	}
+	return m.Text
+}
+
+// PlainText is the fallback of the message for adapters which can't send rich messages.
+func (m Message) PlainText() string {
+	parts := make([]string, 0)
+	if m.Title != "" {
+		title := m.Title
+		if m.TitleUrl != "" {
+			title += " (" + m.TitleUrl + ")"
+		}
+		parts = append(parts, title)
+	}
+	if m.Content() != "" {
+		parts = append(parts, m.Content())
+	}
+	for _, field := range m.Fields {
+		parts = append(parts, field.Title+": "+field.Value)
+	}
+	for _, image := range m.Images {
+		if image.AltText == "" {
+			parts = append(parts, image.Url)
+			continue
+		}
+		parts = append(parts, image.AltText+": "+image.Url)
+	}
+	for _, file := range m.Files {
+		if file.Url == "" {
+			parts = append(parts, "File: "+file.Name)
+			continue
+		}
+		parts = append(parts, "File "+file.Name+": "+file.Url)
+	}
+	buttons := make([]string, 0)
+	for _, button := range m.Buttons {
+		if button.Url == "" {
+			buttons = append(buttons, "["+button.Text+"]")
+			continue
+		}
+		buttons = append(buttons, button.Text+": "+button.Url)
+	}
+	if len(buttons) > 0 {
+		parts = append(parts, strings.Join(buttons, " "))
+	}
+	for _, menu := range m.Menus {
+		options := make([]string, len(menu.Options))
+		for i, option := range menu.Options {
+			options[i] = option.Text
+		}
+		parts = append(parts, menu.Text+": "+strings.Join(options, ", "))
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (m Message) hasActions() bool {
+	for _, button := range m.Buttons {
+		if button.Action != "" {
+			return true
+		}
+	}
+	for _, menu := range m.Menus {
+		if menu.Action != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// richSender give functions of an adapter to send a plain text and a rich message, rich one is nil
+// when adapter can't send rich messages.
+type richSender func(adp Adapter) (func(Envelop, string) error, func(Envelop, Message) error)
+
+func (g *Gubot) SendRichMessages(envelop Envelop, messages ...Message) error {
+	return g.sendingRichEnvelop(envelop, EVENT_ROBOT_SEND, false, messages, func(adp Adapter) (func(Envelop, string) error, func(Envelop, Message) error) {
+		if richAdp, ok := adp.(RichAdapter); ok {
+			return adp.Send, richAdp.SendRich
+		}
+		return adp.Send, nil
+	})
+}
+
+func (g *Gubot) RespondRichMessages(envelop Envelop, messages ...Message) error {
+	if len(messages) > 0 && envelop.User.Name == "" {
+		return errors.New("You must provide a user name in envelop")
+	}
+	return g.sendingRichEnvelop(envelop, EVENT_ROBOT_RESPOND, false, messages, func(adp Adapter) (func(Envelop, string) error, func(Envelop, Message) error) {
+		if richAdp, ok := adp.(RichAdapter); ok {
+			return adp.Reply, richAdp.ReplyRich
+		}
+		return adp.Reply, nil
+	})
+}
+
+func (g *Gubot) SendDirectRichMessages(envelop Envelop, messages ...Message) error {
+	return g.sendingRichEnvelop(envelop, EVENT_ROBOT_RESPOND, true, messages, func(adp Adapter) (func(Envelop, string) error, func(Envelop, Message) error) {
+		reply := adp.Reply
+		directAdp, isDirect := adp.(SendDirectAdapter)
+		if isDirect {
+			reply = directAdp.SendDirect
+		}
+		if richAdp, ok := adp.(SendDirectRichAdapter); ok {
+			return reply, richAdp.SendDirectRich
+		}
+		if richAdp, ok := adp.(RichAdapter); ok && !isDirect {
+			return reply, richAdp.ReplyRich
+		}
+		return reply, nil
+	})
+}
+
+// sendingRichEnvelop send a random message from list on every adapter, actions are signed once for all adapters
+// and adapters which can't send rich messages receive its plain text.
+func (g *Gubot) sendingRichEnvelop(envelop Envelop, eventAction EventAction, direct bool, messages []Message, sender richSender) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	message := messages[rand.Intn(len(messages))].copy()
+	envelop = g.withIconUrl(envelop)
+	for _, adp := range g.adapters {
+		if _, sendRich := sender(adp); sendRich != nil {
+			err := g.signActions(&message, newActionBinding(envelop, direct))
+			if err != nil {
+				log.Error("Error when creating actions, message will be sent without them: " + err.Error())
+			}
+			break
+		}
+	}
+	g.Emit(GubotEvent{
+		Name:        eventAction,
+		Envelop:     envelop,
+		Message:     message.PlainText(),
+		RichMessage: &message,
+	})
+	for _, adp := range g.adapters {
+		log.Debugf("Adapter '%s' chose a random rich message from a list of %d and sent it.", adp.Name(), len(messages))
+		send, sendRich := sender(adp)
+		var err error
+		if sendRich == nil {
+			err = send(envelop, message.PlainText())
+		} else {
+			err = sendRich(envelop, message)
+		}
+		if err != nil {
+			log.Errorf("Error when sending rich message on adapter '%s' : %s", adp.Name(), err.Error())
+		}
+	}
+	return nil
+}
+
+// copy give a message which can receive action ids without modifying buttons and menus of the original one.
+func (m Message) copy() Message {
+	m.Buttons = append([]MessageButton(nil), m.Buttons...)
+	m.Menus = append([]MessageMenu(nil), m.Menus...)
+	return m
+}
@@ -0,0 +1,86 @@
+package robot
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type richRecordAdapterConfig struct{}
+
+// richRecordAdapter keep rich messages sent by gubot.
+type richRecordAdapter struct {
+	*recordAdapter
+	name     string
+	mutex    *sync.Mutex
+	messages []Message
+}
+
+func newRichRecordAdapter(name string) *richRecordAdapter {
+	return &richRecordAdapter{
+		recordAdapter: newRecordAdapter(),
+		name:          name,
+		mutex:         new(sync.Mutex),
+		messages:      make([]Message, 0),
+	}
+}
+
+func (a *richRecordAdapter) Name() string {
+	return a.name
+}
+
+func (a *richRecordAdapter) Config() interface{} {
+	return richRecordAdapterConfig{}
+}
+
+func (a *richRecordAdapter) SendRich(envelop Envelop, message Message) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.messages = append(a.messages, message)
+	return nil
+}
+
+func (a *richRecordAdapter) ReplyRich(envelop Envelop, message Message) error {
+	return a.SendRich(envelop, message)
+}
+
+func (a *richRecordAdapter) sentRich() []Message {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return append([]Message{}, a.messages...)
+}
+
+func TestSendRichMessages(t *testing.T) {
+	g := NewGubot()
+	plain := newRecordAdapter()
+	rich := newRichRecordAdapter("rich")
+	g.RegisterAdapter(plain)
+	g.RegisterAdapter(rich)
+	message := Message{
+		Title: "Build",
+		Text:  "succeeded",
+	}
+
+	err := g.SendRichMessages(Envelop{ChannelName: "general"}, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(plain.sent(), []string{"Build\nsucceeded"}) {
+		t.Errorf("expected plain text fallback, got %v", plain.sent())
+	}
+	if !reflect.DeepEqual(rich.sentRich(), []Message{message}) {
+		t.Errorf("expected rich message as is, got %v", rich.sentRich())
+	}
+	if len(rich.sent()) != 0 {
+		t.Errorf("expected rich adapter to not receive plain text, got %v", rich.sent())
+	}
+}
+
+func TestRespondRichMessagesRequireUser(t *testing.T) {
+	g := NewGubot()
+	err := g.RespondRichMessages(Envelop{ChannelName: "general"}, Message{Text: "hello"})
+	if err == nil {
+		t.Error("expected respond without user name to fail")
+	}
+}
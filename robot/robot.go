@@ -1,6 +1,7 @@
 package robot
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -24,6 +25,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -34,6 +36,7 @@ const (
 
 const (
 	EVENT_ROBOT_STARTED           EventAction = "started"
+	EVENT_ROBOT_STOPPED           EventAction = "stopped"
 	EVENT_ROBOT_CHANNEL_ENTER     EventAction = "channel_enter"
 	EVENT_ROBOT_CHANNEL_LEAVE     EventAction = "channel_leave"
 	EVENT_ROBOT_USER_ONLINE       EventAction = "user_online"
@@ -44,13 +47,19 @@ const (
 	EVENT_ROBOT_SEND              EventAction = "send"
 	EVENT_ROBOT_RESPOND           EventAction = "respond"
 	EVENT_ROBOT_NO_SCRIPT_FOUND   EventAction = "no_script_found"
+	EVENT_ROBOT_JOB_FIRED         EventAction = "job_fired"
+	EVENT_ROBOT_SCRIPT_EXECUTED   EventAction = "script_executed"
+	EVENT_ROBOT_ACTION_TRIGGERED  EventAction = "action_triggered"
 )
 
 type EventAction string
 type GubotEvent struct {
-	Name    EventAction
-	Envelop Envelop
-	Message string
+	Name        EventAction
+	Envelop     Envelop
+	Message     string
+	RichMessage *Message      `json:",omitempty"`
+	Script      string        `json:",omitempty"`
+	Latency     time.Duration `json:",omitempty"`
 }
 
 type Gubot struct {
@@ -64,12 +73,23 @@ type Gubot struct {
 	httpClient         *http.Client
 	gautocloud         loader.Loader
 	store              *gorm.DB
+	brain              Brain
 	scripts            *Scripts
 	slashCommands      *[]SlashCommand
 	mutexScript        *sync.Mutex
 	mutexSlashCommand  *sync.Mutex
 	scriptMiddlewares  []ScriptMiddleware
 	commandMiddlewares []CommandMiddleware
+	scriptAuthorizers  []ScriptAuthorizer
+	dialogs            *dialogs
+	actions            *actions
+	scheduler          *scheduler
+	ctx                context.Context
+	cancel             context.CancelFunc
+	scriptTimeout      time.Duration
+	scriptWorkers      int
+	server             *http.Server
+	lifecycle          *lifecycle
 }
 
 func NewGubot() *Gubot {
@@ -89,6 +109,7 @@ func NewGubot() *Gubot {
 	}
 	scripts := Scripts(make([]Script, 0))
 	slashCommands := make([]SlashCommand, 0)
+	ctx, cancel := context.WithCancel(context.Background())
 	gubot := &Gubot{
 		GubotEmitter:       emitter.New(uint(100)),
 		name:               "gubot",
@@ -103,6 +124,13 @@ func NewGubot() *Gubot {
 		scriptMiddlewares:  make([]ScriptMiddleware, 0),
 		commandMiddlewares: make([]CommandMiddleware, 0),
 		slashCommands:      &slashCommands,
+		dialogs:            newDialogs(),
+		actions:            newActions(),
+		scheduler:          newScheduler(),
+		ctx:                ctx,
+		cancel:             cancel,
+		scriptWorkers:      DEFAULT_SCRIPT_WORKERS,
+		lifecycle:          newLifecycle(),
 	}
 	gubot.gautocloud.RegisterConnector(NewGubotGenericConnector(GubotConfig{}))
 	return gubot
@@ -176,6 +204,16 @@ func (g Gubot) HttpClient() *http.Client {
 	return g.httpClient
 }
 
+// Context returns a context which is cancelled when gubot stop.
+func (g Gubot) Context() context.Context {
+	return g.ctx
+}
+
+// SetScriptTimeout set the default timeout for scripts which doesn't define their own, 0 means no timeout.
+func (g *Gubot) SetScriptTimeout(timeout time.Duration) {
+	g.scriptTimeout = timeout
+}
+
 func (g *Gubot) RegisterAdapter(adp Adapter) {
 	g.adapters = append(g.adapters, adp)
 	g.gautocloud.RegisterConnector(NewGubotGenericConnector(adp.Config()))
@@ -209,6 +247,9 @@ func (g *Gubot) Use(middlewares ...Middleware) {
 	for _, mid := range middlewares {
 		g.scriptMiddlewares = append(g.scriptMiddlewares, mid.ScriptMiddleware)
 		g.commandMiddlewares = append(g.commandMiddlewares, mid.CommandMiddleware)
+		if authorizer, ok := mid.(ScriptAuthorizer); ok {
+			g.scriptAuthorizers = append(g.scriptAuthorizers, authorizer)
+		}
 	}
 }
 
@@ -228,7 +269,7 @@ func (g *Gubot) RegisterSlashCommand(slashCommand SlashCommand) error {
 		return err
 	}
 	if g.findSlashCommandIndex(slashCommand) != -1 {
-		return errors.New("slashCommand '%s' already registered")
+		return fmt.Errorf("Slash command '%s' already registered", slashCommand.Trigger)
 	}
 	*g.slashCommands = append(*g.slashCommands, slashCommand)
 	log.Debugf("%s registered.", slashCommand)
@@ -304,16 +345,24 @@ func (g *Gubot) UpdateSlashCommands(slashCommands []SlashCommand) error {
 func (g *Gubot) RegisterScript(script Script) error {
 	defer g.mutexScript.Unlock()
 	g.mutexScript.Lock()
+	script = script.withCommand()
 	err := g.checkScript(script)
 	if err != nil {
 		return err
 	}
 	if g.findScriptIndex(script) != -1 {
-		return errors.New("Script '%s' already registered")
+		return fmt.Errorf("Script '%s' already registered", script.Name)
+	}
+	err = script.compile()
+	if err != nil {
+		return err
 	}
 	if script.Sanitizer == nil {
 		script.Sanitizer = SanitizeDefault
 	}
+	if script.Source == "" {
+		script.Source = SourceBuiltin
+	}
 	*g.scripts = append(*g.scripts, script)
 	log.Debugf("%s registered.", script.String())
 	return nil
@@ -366,6 +415,7 @@ func (g *Gubot) RegisterScripts(scripts []Script) error {
 func (g *Gubot) UnregisterScript(script Script) error {
 	defer g.mutexScript.Unlock()
 	g.mutexScript.Lock()
+	script = script.withCommand()
 	err := g.checkScript(script)
 	if err != nil {
 		return err
@@ -374,8 +424,10 @@ func (g *Gubot) UnregisterScript(script Script) error {
 	if i == -1 {
 		return nil
 	}
-	scripts := *g.scripts
-	scripts = append(scripts[:i], scripts[i+1:]...)
+	// a new slice is created to not modify scripts being dispatched
+	scripts := make(Scripts, 0, len(*g.scripts)-1)
+	scripts = append(scripts, (*g.scripts)[:i]...)
+	scripts = append(scripts, (*g.scripts)[i+1:]...)
 	*g.scripts = scripts
 	log.Debugf("%s unregistered.", script.String())
 	return nil
@@ -394,6 +446,7 @@ func (g *Gubot) UnregisterScripts(scripts []Script) error {
 func (g *Gubot) UpdateScript(script Script) error {
 	defer g.mutexScript.Unlock()
 	g.mutexScript.Lock()
+	script = script.withCommand()
 	err := g.checkScript(script)
 	if err != nil {
 		return err
@@ -402,7 +455,19 @@ func (g *Gubot) UpdateScript(script Script) error {
 	if i == -1 {
 		return nil
 	}
-	scripts := *g.scripts
+	err = script.compile()
+	if err != nil {
+		return err
+	}
+	if script.Sanitizer == nil {
+		script.Sanitizer = SanitizeDefault
+	}
+	if script.Source == "" {
+		script.Source = SourceBuiltin
+	}
+	// a new slice is created to not modify scripts being dispatched
+	scripts := make(Scripts, len(*g.scripts))
+	copy(scripts, *g.scripts)
 	scripts[i] = script
 	*g.scripts = scripts
 	log.Debugf("%s updated.", script.String())
@@ -420,13 +485,22 @@ func (g *Gubot) UpdateScripts(scripts []Script) error {
 }
 
 func (g Gubot) checkScript(script Script) error {
-	if script.Function == nil || script.Matcher == "" || script.Type == "" ||
+	if (script.Function == nil && script.ContextFunction == nil && script.ArgsFunction == nil) || script.Matcher == "" || script.Type == "" ||
 		script.Name == "" {
 		return errors.New("Script " + script.Name + " can't have function, matcher, type or name empty.")
 	}
 	return nil
 }
 
+// copyScripts give a copy of registered scripts which can be used without holding lock.
+func (g *Gubot) copyScripts() Scripts {
+	g.mutexScript.Lock()
+	defer g.mutexScript.Unlock()
+	scripts := make(Scripts, len(*g.scripts))
+	copy(scripts, *g.scripts)
+	return scripts
+}
+
 func (g *Gubot) findScriptIndex(findScript Script) int {
 	for index, script := range *g.scripts {
 		if script.Name == findScript.Name &&
@@ -459,7 +533,21 @@ func (g Gubot) Store() *gorm.DB {
 	return g.store
 }
 
+func (g Gubot) Brain() Brain {
+	return g.brain
+}
+
+// SetBrain replace the brain backed by the store, it must be called before gubot start.
+func (g *Gubot) SetBrain(brain Brain) {
+	g.brain = brain
+}
+
 func (g *Gubot) Receive(envelop Envelop) {
+	if !g.acquire() {
+		log.Debugf("Gubot is stopping, envelop=%v dropped", envelop)
+		return
+	}
+	defer g.release()
 	envelop.FromReceived = true
 	log.Debugf("Received envelop=%v", envelop)
 	g.Emit(GubotEvent{
@@ -467,26 +555,27 @@ func (g *Gubot) Receive(envelop Envelop) {
 		Envelop: envelop,
 	})
 	g.registerUser(envelop)
-	toSends := g.getMessages(envelop, Tsend)
-	toReplies := g.getMessages(envelop, Trespond)
-	toDirect := g.getMessages(envelop, Tdirect)
+	if g.receiveDialog(envelop) {
+		return
+	}
+	result := g.dispatch(envelop)
 
-	if len(toSends) == 0 && len(toReplies) == 0 && len(toSends) == 0 {
+	if result.isEmpty() {
 		g.Emit(GubotEvent{
 			Name:    EVENT_ROBOT_NO_SCRIPT_FOUND,
 			Envelop: envelop,
 		})
 	}
 
-	err := g.SendMessages(envelop, toSends...)
+	err := g.SendMessages(envelop, result.toSends...)
 	if err != nil {
 		log.Error("Error when sending messages: " + err.Error())
 	}
-	err = g.RespondMessages(envelop, toReplies...)
+	err = g.RespondMessages(envelop, result.toReplies...)
 	if err != nil {
 		log.Error("Error when replying messages: " + err.Error())
 	}
-	err = g.SendDirectMessages(envelop, toDirect...)
+	err = g.SendDirectMessages(envelop, result.toDirect...)
 	if err != nil {
 		log.Error("Error when sending direct messages: " + err.Error())
 	}
@@ -553,11 +642,8 @@ func (g Gubot) choseRandomMessage(messages []string) string {
 	return messages[rand.Intn(len(messages))]
 }
 
-func (g *Gubot) sendingEnvelop(envelop Envelop, adpFn func(Envelop, string) error, eventAction EventAction, messages []string) error {
-	if len(messages) == 0 {
-		return nil
-	}
-	message := g.choseRandomMessage(messages)
+// withIconUrl set icon of gubot on envelop if it has none.
+func (g *Gubot) withIconUrl(envelop Envelop) Envelop {
 	if envelop.IconUrl == "" && g.host != "" {
 		host := g.host
 		if strings.HasPrefix(host, "https") {
@@ -566,6 +652,15 @@ func (g *Gubot) sendingEnvelop(envelop Envelop, adpFn func(Envelop, string) erro
 		}
 		envelop.IconUrl = host + icon_route
 	}
+	return envelop
+}
+
+func (g *Gubot) sendingEnvelop(envelop Envelop, adpFn func(Envelop, string) error, eventAction EventAction, messages []string) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	message := g.choseRandomMessage(messages)
+	envelop = g.withIconUrl(envelop)
 	g.Emit(GubotEvent{
 		Name:    eventAction,
 		Envelop: envelop,
@@ -585,15 +680,29 @@ func (g *Gubot) LoadStore() error {
 		}
 		log.Info("Sqlite file created in path: " + dbFile)
 	}
+	return g.UseStore(store)
+}
+
+// UseStore migrate store and use it for users, remote scripts and brain, remote scripts in store are registered.
+func (g *Gubot) UseStore(store *gorm.DB) error {
 	store.AutoMigrate(&User{})
 	store.AutoMigrate(&RemoteScript{})
 	store.AutoMigrate(&SlashCommandToken{})
+	store.AutoMigrate(&BrainEntry{})
+	store.AutoMigrate(&ScheduledJob{})
+	store.AutoMigrate(&ActionToken{})
 	var rmtScripts []RemoteScript
 	store.Find(&rmtScripts)
 	for _, rmtScript := range rmtScripts {
-		g.RegisterScript(g.remoteScriptToScript(rmtScript))
+		err := g.RegisterScript(g.remoteScriptToScript(rmtScript))
+		if err != nil {
+			log.Errorf("Remote script '%s' can't be loaded: %s", rmtScript.Name, err.Error())
+		}
 	}
 	g.store = store
+	if g.brain == nil {
+		g.brain = NewStoreBrain(store)
+	}
 	return nil
 }
 
@@ -625,6 +734,7 @@ func (g Gubot) InitDefaultRoute() {
 	g.router.Handle("/", http.HandlerFunc(g.showScripts)).Methods("GET")
 
 	g.router.Handle("/slash-command", http.HandlerFunc(g.slashCommand)).Methods("POST", "GET")
+	g.router.Handle("/actions/{adapter}", http.HandlerFunc(g.actionCallback)).Methods("POST")
 
 	mux.NewRouter()
 	apiRouter := g.router.PathPrefix("/api").Subrouter()
@@ -674,31 +784,6 @@ func (g Gubot) IsSecured(w http.ResponseWriter, req *http.Request) bool {
 	return auth
 }
 
-func (g Gubot) getMessages(envelop Envelop, typeScript TypeScript) []string {
-	toSends := make([]string, 0)
-	g.mutexScript.Lock()
-	scripts := *g.scripts
-	g.mutexScript.Unlock()
-	for _, script := range scripts.ListFromType(typeScript) {
-		if script.TriggerOnMention && envelop.NotMentioned {
-			continue
-		}
-		message := script.Sanitizer(envelop.Message)
-		if !match(script.Matcher, message) {
-			continue
-		}
-		log.Debug("%s respond on envelop=%v", script.String(), envelop)
-		function := g.generateScriptFunction(script, script.Function)
-		messages, err := function(envelop, allSubMatch(script.Matcher, message))
-		if err != nil {
-			log.Error(fmt.Sprintf("Error on script '%s': %s", script.Name, err.Error()))
-			continue
-		}
-		toSends = append(toSends, messages...)
-	}
-	return toSends
-}
-
 func (g Gubot) generateScriptFunction(script Script, handler EnvelopHandler) EnvelopHandler {
 	for i := len(g.scriptMiddlewares) - 1; i >= 0; i-- {
 		middleware := g.scriptMiddlewares[i]
@@ -707,6 +792,47 @@ func (g Gubot) generateScriptFunction(script Script, handler EnvelopHandler) Env
 	return handler
 }
 
+// scriptContext give a context derived from gubot context with script timeout or default script timeout if set.
+func (g Gubot) scriptContext(script Script) (context.Context, context.CancelFunc) {
+	timeout := script.Timeout
+	if timeout <= 0 {
+		timeout = g.scriptTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(g.ctx)
+	}
+	return context.WithTimeout(g.ctx, timeout)
+}
+
+func (g Gubot) scriptHandler(ctx context.Context, script Script) EnvelopHandler {
+	if script.ContextFunction == nil {
+		return script.Function
+	}
+	return func(envelop Envelop, subMatch [][]string) ([]string, error) {
+		return script.ContextFunction(ctx, envelop, subMatch)
+	}
+}
+
+// runWithContext stop waiting for handler when context is done, handlers without context can't be interrupted
+// but at least they don't block gubot anymore.
+func runWithContext(ctx context.Context, handler EnvelopHandler, envelop Envelop, subMatch [][]string) ([]string, error) {
+	type result struct {
+		messages []string
+		err      error
+	}
+	resultChan := make(chan result, 1)
+	go func() {
+		messages, err := handler(envelop, subMatch)
+		resultChan <- result{messages, err}
+	}()
+	select {
+	case res := <-resultChan:
+		return res.messages, res.err
+	case <-ctx.Done():
+		return []string{}, ctx.Err()
+	}
+}
+
 func (g Gubot) generateCommandFunction(command SlashCommand, handler CommandHandler) CommandHandler {
 	for i := len(g.commandMiddlewares) - 1; i >= 0; i-- {
 		middleware := g.commandMiddlewares[i]
@@ -715,16 +841,24 @@ func (g Gubot) generateCommandFunction(command SlashCommand, handler CommandHand
 	return handler
 }
 
+// regexes keep regexes compiled by match, patterns come from code and configuration so they are few.
+// A sync.Map is used as its zero value is ready when match is called during package initialization.
+var regexes sync.Map
+
 func match(matcher, content string) bool {
-	regex := regexp.MustCompile(matcher)
+	cached, ok := regexes.Load(matcher)
+	if ok {
+		return cached.(*regexp.Regexp).MatchString(content)
+	}
+	regex, err := regexp.Compile(matcher)
+	if err != nil {
+		log.Errorf("Invalid regex '%s': %s", matcher, err.Error())
+		return false
+	}
+	regexes.Store(matcher, regex)
 	return regex.MatchString(content)
 }
 
-func allSubMatch(matcher, content string) [][]string {
-	regex := regexp.MustCompile(matcher)
-	return regex.FindAllStringSubmatch(content, -1)
-}
-
 func (g Gubot) Router() *mux.Router {
 	return g.router
 }
@@ -747,46 +881,13 @@ func (g Gubot) IsValidToken(tokenToCheck string) bool {
 }
 
 func (g Gubot) GetScripts() []Script {
-	return []Script(*g.scripts)
-}
-
-func (g *Gubot) InitializeHelp() {
-	g.RegisterScript(Script{
-		Name:        "help",
-		Description: "Provide the list of available scripts",
-		Matcher:     "(?i)^help$",
-		Function: func(envelop Envelop, subMatch [][]string) ([]string, error) {
-			list := "Available scripts: \n"
-			g.mutexScript.Lock()
-			scripts := *g.scripts
-			g.mutexScript.Unlock()
-			for _, script := range scripts {
-				if script.Name == REMOTE_SCRIPTS_NAME {
-					continue
-				}
-				list += fmt.Sprintf(
-					"- %s",
-					strings.Title(script.Name),
-				)
-				if script.TriggerOnMention {
-					list += "*"
-				}
-				if script.Example == "" {
-					list += " -- regex: `" + script.Matcher + "`"
-				} else {
-					list += " -- e.g.: `" + script.Example + "`"
-				}
-				if script.Description != "" {
-					list += " -- " + strings.Title(script.Description)
-				}
-				list += "\n"
-			}
-			list += "`*`: Script will be only triggered when talking explicitly to the bot."
-			return []string{list}, nil
-		},
-		TriggerOnMention: true,
-		Type:             Tsend,
-	})
+	return []Script(g.copyScripts())
+}
+
+func (g *Gubot) GetSlashCommands() []SlashCommand {
+	g.mutexSlashCommand.Lock()
+	defer g.mutexSlashCommand.Unlock()
+	return append([]SlashCommand{}, *g.slashCommands...)
 }
 
 func (g Gubot) SlashCommandUrl() string {
@@ -823,6 +924,10 @@ func (g Gubot) initSlashCommand() error {
 
 func (g *Gubot) Start(addr string) error {
 	defer g.GubotEmitter.Off("*")
+	g.server = &http.Server{
+		Addr:    addr,
+		Handler: g.router,
+	}
 	var conf GubotConfig
 	err := g.gautocloud.Inject(&conf)
 	if err != nil {
@@ -839,6 +944,15 @@ func (g *Gubot) Start(addr string) error {
 	if len(conf.Tokens) > 0 {
 		g.SetTokens(conf.Tokens)
 	}
+	if conf.ScriptWorkers > 0 {
+		g.SetScriptWorkers(conf.ScriptWorkers)
+	}
+	if conf.ActionsSecret != "" {
+		g.SetActionsSecret(conf.ActionsSecret)
+	}
+	if conf.ScriptTimeoutInSeconds > 0 {
+		g.SetScriptTimeout(time.Duration(conf.ScriptTimeoutInSeconds) * time.Second)
+	}
 	if len(g.tokens) == 0 {
 		defaultToken := uuid.NewV4().String()
 		g.tokens = []string{defaultToken}
@@ -857,6 +971,7 @@ func (g *Gubot) Start(addr string) error {
 	g.runAdapters()
 	g.InitDefaultRoute()
 	g.InitializeHelp()
+	g.InitializeScheduler()
 	err = g.initSlashCommand()
 	if err != nil {
 		log.Error(err)
@@ -868,8 +983,16 @@ func (g *Gubot) Start(addr string) error {
 	g.Emit(GubotEvent{
 		Name: EVENT_ROBOT_INITIALIZED,
 	})
+	g.startScheduler()
 	g.Emit(GubotEvent{
 		Name: EVENT_ROBOT_STARTED,
 	})
-	return http.ListenAndServe(addr, g.router)
+	err = g.server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		g.cancel()
+		return err
+	}
+	// wait for the stop to finish before removing listeners
+	<-g.lifecycle.stopped
+	return nil
 }
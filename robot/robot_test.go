@@ -0,0 +1,41 @@
+package robot
+
+import (
+	"testing"
+)
+
+func noopHandler(envelop Envelop, subMatch [][]string) ([]string, error) {
+	return []string{}, nil
+}
+
+func TestRegisterScriptRejectsInvalidMatcher(t *testing.T) {
+	g := NewGubot()
+	err := g.RegisterScript(Script{Name: "invalid", Matcher: "^(unclosed$", Type: Tsend, Function: noopHandler})
+	if err == nil {
+		t.Error("expected script with an invalid regex to be refused")
+	}
+	if len(g.GetScripts()) != 0 {
+		t.Errorf("expected refused script to not be registered, got %v", g.GetScripts())
+	}
+
+	err = g.RegisterScript(Script{Name: "valid", Matcher: "^hello$", Type: Tsend, Function: noopHandler})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts := g.GetScripts()
+	if len(scripts) != 1 || scripts[0].matcher == nil {
+		t.Error("expected matcher to be compiled at registration")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	if !match("^hel+o$", "hello") || match("^hel+o$", "help") {
+		t.Error("unexpected result of match")
+	}
+	if _, ok := regexes.Load("^hel+o$"); !ok {
+		t.Error("expected regex to be compiled once and kept")
+	}
+	if match("^(unclosed$", "unclosed") {
+		t.Error("expected invalid regex to never match")
+	}
+}
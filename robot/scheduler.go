@@ -0,0 +1,353 @@
+package robot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SCHEDULER_TICK = 1 * time.Second
+
+type scheduler struct {
+	stop  chan struct{}
+	done  chan struct{}
+	mutex *sync.Mutex
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		mutex: new(sync.Mutex),
+	}
+}
+
+func (j ScheduledJob) String() string {
+	if j.Cron != "" {
+		return fmt.Sprintf("#%d `%s` (cron '%s')", j.ID, j.Message, j.Cron)
+	}
+	if j.Interval > 0 {
+		return fmt.Sprintf("#%d `%s` (every %s, next at %s)", j.ID, j.Message, j.Interval, j.NextRun.Format(time.RFC1123))
+	}
+	return fmt.Sprintf("#%d `%s` (at %s)", j.ID, j.Message, j.NextRun.Format(time.RFC1123))
+}
+
+func (j ScheduledJob) ToEnvelop() (Envelop, error) {
+	var envelop Envelop
+	err := json.Unmarshal([]byte(j.Envelop), &envelop)
+	return envelop, err
+}
+
+// ParseDuration act as time.ParseDuration but also accept days (e.g.: 2d).
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// ScheduleIn send message once on envelop after the delay.
+func (g *Gubot) ScheduleIn(envelop Envelop, delay time.Duration, typeScript TypeScript, message string) (ScheduledJob, error) {
+	return g.scheduleJob(envelop, ScheduledJob{
+		NextRun: time.Now().Add(delay),
+		Type:    string(typeScript),
+		Message: message,
+	})
+}
+
+// ScheduleEvery send message on envelop at each interval until job is cancelled.
+func (g *Gubot) ScheduleEvery(envelop Envelop, interval time.Duration, typeScript TypeScript, message string) (ScheduledJob, error) {
+	if interval < SCHEDULER_TICK {
+		return ScheduledJob{}, fmt.Errorf("Interval can't be less than %s", SCHEDULER_TICK)
+	}
+	return g.scheduleJob(envelop, ScheduledJob{
+		Interval: interval,
+		NextRun:  time.Now().Add(interval),
+		Type:     string(typeScript),
+		Message:  message,
+	})
+}
+
+// ScheduleCron send message on envelop each time the cron expression match until job is cancelled.
+func (g *Gubot) ScheduleCron(envelop Envelop, expr string, typeScript TypeScript, message string) (ScheduledJob, error) {
+	cron, err := ParseCron(expr)
+	if err != nil {
+		return ScheduledJob{}, err
+	}
+	return g.scheduleJob(envelop, ScheduledJob{
+		Cron:    cron.String(),
+		NextRun: cron.Next(time.Now()),
+		Type:    string(typeScript),
+		Message: message,
+	})
+}
+
+func (g *Gubot) scheduleJob(envelop Envelop, job ScheduledJob) (ScheduledJob, error) {
+	if g.store == nil {
+		return job, errors.New("Store must be initialized to schedule a job")
+	}
+	if job.Message == "" {
+		return job, errors.New("You must provide a message to schedule")
+	}
+	if job.Type == "" {
+		job.Type = string(Tsend)
+	}
+	if TypeScript(job.Type) == Trespond && envelop.User.Name == "" {
+		return job, errors.New("You must provide a user name in envelop")
+	}
+	envelop.FromReceived = false
+	b, err := json.Marshal(envelop)
+	if err != nil {
+		return job, err
+	}
+	job.Envelop = string(b)
+	job.Channel = ChannelScope(envelop).Id
+	job.Owner = UserScope(envelop).Id
+	err = g.store.Create(&job).Error
+	if err != nil {
+		return job, err
+	}
+	log.Debugf("Job %s scheduled.", job.String())
+	return job, nil
+}
+
+// CancelJob cancel a job scheduled on the channel of envelop, jobs from other channels can't be cancelled.
+func (g *Gubot) CancelJob(envelop Envelop, id uint) error {
+	if g.store == nil {
+		return errors.New("Store must be initialized to cancel a job")
+	}
+	result := g.store.Unscoped().
+		Where("id = ? AND channel = ?", id, ChannelScope(envelop).Id).
+		Delete(ScheduledJob{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("Job #%d not found in this channel.", id)
+	}
+	return nil
+}
+
+// ListJobs give all jobs scheduled on the channel of envelop.
+func (g *Gubot) ListJobs(envelop Envelop) ([]ScheduledJob, error) {
+	jobs := make([]ScheduledJob, 0)
+	if g.store == nil {
+		return jobs, errors.New("Store must be initialized to list jobs")
+	}
+	err := g.store.Where("channel = ?", ChannelScope(envelop).Id).Order("next_run").Find(&jobs).Error
+	return jobs, err
+}
+
+func (g *Gubot) startScheduler() {
+	g.scheduler.mutex.Lock()
+	defer g.scheduler.mutex.Unlock()
+	if g.scheduler.stop != nil {
+		return
+	}
+	g.scheduler.stop = make(chan struct{})
+	g.scheduler.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(SCHEDULER_TICK)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				g.runDueJobs(now)
+			}
+		}
+	}(g.scheduler.stop, g.scheduler.done)
+}
+
+func (g *Gubot) stopScheduler() {
+	g.scheduler.mutex.Lock()
+	defer g.scheduler.mutex.Unlock()
+	if g.scheduler.stop == nil {
+		return
+	}
+	close(g.scheduler.stop)
+	<-g.scheduler.done
+	g.scheduler.stop = nil
+}
+
+// deleteJob remove a job whatever its channel, it is used by scheduler when a job is done.
+func (g *Gubot) deleteJob(id uint) {
+	err := g.store.Unscoped().Where("id = ?", id).Delete(ScheduledJob{}).Error
+	if err != nil {
+		log.Errorf("Error when removing job #%d: %s", id, err.Error())
+	}
+}
+
+// rescheduleJob update only the next run of a job, a job cancelled meanwhile is not created again.
+func (g *Gubot) rescheduleJob(job ScheduledJob) {
+	err := g.store.Model(&ScheduledJob{}).Where("id = ?", job.ID).Update("next_run", job.NextRun).Error
+	if err != nil {
+		log.Errorf("Error when rescheduling job #%d: %s", job.ID, err.Error())
+	}
+}
+
+func (g *Gubot) runDueJobs(now time.Time) {
+	var jobs []ScheduledJob
+	err := g.store.Where("next_run <= ?", now).Find(&jobs).Error
+	if err != nil {
+		log.Error("Error when retrieving scheduled jobs: " + err.Error())
+		return
+	}
+	for _, job := range jobs {
+		g.fireJob(job, now)
+	}
+}
+
+func (g *Gubot) fireJob(job ScheduledJob, now time.Time) {
+	// jobs are rescheduled before sending to not fire them twice if sending is slow
+	switch {
+	case job.Cron != "":
+		cron, err := ParseCron(job.Cron)
+		if err != nil {
+			log.Errorf("Job %s removed: %s", job.String(), err.Error())
+			g.deleteJob(job.ID)
+			return
+		}
+		job.NextRun = cron.Next(now)
+		if job.NextRun.IsZero() {
+			log.Errorf("Job %s removed: cron expression never matches anymore", job.String())
+			g.deleteJob(job.ID)
+			return
+		}
+		g.rescheduleJob(job)
+	case job.Interval > 0:
+		job.NextRun = now.Add(job.Interval)
+		g.rescheduleJob(job)
+	default:
+		g.deleteJob(job.ID)
+	}
+	envelop, err := job.ToEnvelop()
+	if err != nil {
+		log.Errorf("Error when decoding envelop of job %s: %s", job.String(), err.Error())
+		return
+	}
+	log.Debugf("Job %s fired.", job.String())
+	g.Emit(GubotEvent{
+		Name:    EVENT_ROBOT_JOB_FIRED,
+		Envelop: envelop,
+		Message: job.Message,
+	})
+	err = g.sendByType(envelop, TypeScript(job.Type), job.Message)
+	if err != nil {
+		log.Errorf("Error when sending message of job %s: %s", job.String(), err.Error())
+	}
+}
+
+func (g *Gubot) InitializeScheduler() {
+	g.RegisterScripts([]Script{
+		{
+			Name:        "remind me in",
+			Description: "Remind you something after a delay",
+			Category:    "scheduler",
+			Example:     "remind me in 10m to check the oven",
+			Matcher:     "(?i)^remind me in ([0-9]+[a-z]+) (?:to )?(.+)$",
+			Function: func(envelop Envelop, subMatch [][]string) ([]string, error) {
+				delay, err := ParseDuration(subMatch[0][1])
+				if err != nil {
+					return []string{}, err
+				}
+				job, err := g.ScheduleIn(envelop, delay, Trespond, subMatch[0][2])
+				if err != nil {
+					return []string{}, err
+				}
+				return []string{fmt.Sprintf("I will remind you at %s (job #%d).", job.NextRun.Format(time.RFC1123), job.ID)}, nil
+			},
+			TriggerOnMention: true,
+			Type:             Trespond,
+		},
+		{
+			Name:        "remind me every",
+			Description: "Remind you something at each interval",
+			Category:    "scheduler",
+			Example:     "remind me every 1h to drink water",
+			Matcher:     "(?i)^remind me every ([0-9]+[a-z]+) (?:to )?(.+)$",
+			Function: func(envelop Envelop, subMatch [][]string) ([]string, error) {
+				interval, err := ParseDuration(subMatch[0][1])
+				if err != nil {
+					return []string{}, err
+				}
+				job, err := g.ScheduleEvery(envelop, interval, Trespond, subMatch[0][2])
+				if err != nil {
+					return []string{}, err
+				}
+				return []string{fmt.Sprintf("I will remind you every %s (job #%d).", interval, job.ID)}, nil
+			},
+			TriggerOnMention: true,
+			Type:             Trespond,
+		},
+		{
+			Name:        "schedule",
+			Description: "Send a message in channel each time the cron expression match",
+			Category:    "scheduler",
+			Example:     "schedule \"0 9 * * 1-5\" time for standup",
+			Matcher:     "(?i)^schedule \"([^\"]+)\" (.+)$",
+			Function: func(envelop Envelop, subMatch [][]string) ([]string, error) {
+				job, err := g.ScheduleCron(envelop, subMatch[0][1], Tsend, subMatch[0][2])
+				if err != nil {
+					return []string{}, err
+				}
+				return []string{fmt.Sprintf("Message scheduled, next at %s (job #%d).", job.NextRun.Format(time.RFC1123), job.ID)}, nil
+			},
+			TriggerOnMention: true,
+			Type:             Tsend,
+		},
+		{
+			Name:        "schedule list",
+			Description: "List scheduled messages in this channel",
+			Category:    "scheduler",
+			Example:     "schedule list",
+			Matcher:     "(?i)^schedule list$",
+			Function: func(envelop Envelop, subMatch [][]string) ([]string, error) {
+				jobs, err := g.ListJobs(envelop)
+				if err != nil {
+					return []string{}, err
+				}
+				if len(jobs) == 0 {
+					return []string{"There is no scheduled message in this channel."}, nil
+				}
+				list := "Scheduled messages: \n"
+				for _, job := range jobs {
+					list += "- " + job.String() + "\n"
+				}
+				return []string{list}, nil
+			},
+			TriggerOnMention: true,
+			Type:             Tsend,
+		},
+		{
+			Name:        "schedule cancel",
+			Description: "Cancel a scheduled message",
+			Category:    "scheduler",
+			Example:     "schedule cancel 1",
+			Matcher:     "(?i)^schedule cancel #?([0-9]+)$",
+			Function: func(envelop Envelop, subMatch [][]string) ([]string, error) {
+				id, err := strconv.Atoi(subMatch[0][1])
+				if err != nil {
+					return []string{}, err
+				}
+				err = g.CancelJob(envelop, uint(id))
+				if err != nil {
+					return []string{err.Error()}, nil
+				}
+				return []string{fmt.Sprintf("Job #%d cancelled.", id)}, nil
+			},
+			TriggerOnMention: true,
+			Type:             Tsend,
+		},
+	})
+}
@@ -1,6 +1,10 @@
 package robot
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 const (
 	Tsend    TypeScript = "send"
@@ -8,6 +12,12 @@ const (
 	Tdirect  TypeScript = "direct"
 )
 
+const (
+	SourceBuiltin = "built-in"
+	SourceRemote  = "remote"
+	SourceProgram = "program"
+)
+
 type ScriptMiddleware func(script Script, next EnvelopHandler) EnvelopHandler
 
 type CommandMiddleware func(command SlashCommand, next CommandHandler) CommandHandler
@@ -19,17 +29,28 @@ type Middleware interface {
 
 type EnvelopHandler func(Envelop, [][]string) ([]string, error)
 
+// ContextEnvelopHandler is an EnvelopHandler which receive a context cancelled when script timed out or gubot is stopping.
+type ContextEnvelopHandler func(context.Context, Envelop, [][]string) ([]string, error)
+
 type CommandHandler func(Envelop) (string, error)
 
 type Script struct {
 	Name             string                   `json:"name" gorm:"primary_key"`
 	Description      string                   `json:"description"`
 	Example          string                   `json:"example"`
+	Category         string                   `json:"category"`
+	Source           string                   `json:"source,omitempty" gorm:"-"`
 	Matcher          string                   `json:"matcher"`
+	MatcherType      string                   `json:"matcher_type"`
 	TriggerOnMention bool                     `json:"trigger_on_mention"`
 	Function         EnvelopHandler           `json:"-" gorm:"-"`
+	ContextFunction  ContextEnvelopHandler    `json:"-" gorm:"-"`
+	Command          *Command                 `json:"-" gorm:"-"`
+	ArgsFunction     ArgsHandler              `json:"-" gorm:"-"`
+	Timeout          time.Duration            `json:"-" gorm:"-"`
 	Sanitizer        func(text string) string `json:"-" gorm:"-"`
 	Type             TypeScript               `json:"type" gorm:"-"`
+	matcher          Matcher
 }
 
 type TypeScript string
@@ -47,6 +68,59 @@ func (s Scripts) ListFromType(typeScript TypeScript) Scripts {
 	return Scripts(scripts)
 }
 
+// withCommand generate matcher and function from command definition if script has one.
+func (s Script) withCommand() Script {
+	if s.Command == nil {
+		return s
+	}
+	if s.Matcher == "" {
+		s.Matcher = s.Command.Usage()
+	}
+	if s.Function == nil && s.ContextFunction == nil && s.ArgsFunction != nil {
+		s.Function = toEnvelopHandler(*s.Command, s.ArgsFunction)
+	}
+	return s
+}
+
+// compile validate and cache the matcher, it is done when script is registered or updated.
+func (s *Script) compile() error {
+	if s.Command != nil {
+		err := s.Command.check()
+		if err != nil {
+			return fmt.Errorf("Script '%s' has an invalid command: %s", s.Name, err.Error())
+		}
+		s.matcher = *s.Command
+		return nil
+	}
+	matcher, err := NewMatcher(s.MatcherType, s.Matcher)
+	if err != nil {
+		return fmt.Errorf("Script '%s' has an invalid matcher '%s': %s", s.Name, s.Matcher, err.Error())
+	}
+	s.matcher = matcher
+	// a command matcher give typed arguments as a command does
+	if commandMatcher, ok := matcher.(*CommandMatcher); ok && s.Function == nil && s.ContextFunction == nil && s.ArgsFunction != nil {
+		s.Function = toEnvelopHandler(commandMatcher, s.ArgsFunction)
+	}
+	if s.Function == nil && s.ContextFunction == nil {
+		return fmt.Errorf("Script '%s' can only have an ArgsFunction with a command or a command matcher", s.Name)
+	}
+	return nil
+}
+
+func (s Script) match(content string) bool {
+	if s.matcher == nil && s.compile() != nil {
+		return false
+	}
+	return s.matcher.Match(content)
+}
+
+func (s Script) allSubMatch(content string) [][]string {
+	if s.matcher == nil && s.compile() != nil {
+		return [][]string{}
+	}
+	return s.matcher.SubMatch(content)
+}
+
 func (s Script) String() string {
 	return fmt.Sprintf("Script '%s' with matcher '%s' and type '%s'", s.Name, s.Matcher, s.Type)
 }
@@ -59,5 +133,5 @@ type SlashCommand struct {
 }
 
 func (s SlashCommand) String() string {
-	return fmt.Sprintf("Slash command '%s' with trigger word '%s' and type '%s'", s.Title, s.Trigger)
+	return fmt.Sprintf("Slash command '%s' with trigger word '%s'", s.Title, s.Trigger)
 }
@@ -2,8 +2,10 @@ package robot
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"github.com/hashicorp/go-multierror"
 	log "github.com/sirupsen/logrus"
 	"io"
 	"io/ioutil"
@@ -69,16 +71,20 @@ func (g *Gubot) registerRemoteScripts(w http.ResponseWriter, req *http.Request)
 	}
 	for _, rmtScript := range tmpScripts {
 		g.Store().Create(&rmtScript)
-		g.RegisterScript(g.remoteScriptToScript(rmtScript))
-		log.Info("Client '%s' on api registered: %s.", getRemoteIp(req), rmtScript.String())
+		err = g.RegisterScript(g.remoteScriptToScript(rmtScript))
+		if err != nil {
+			log.Errorf("Client '%s' on api can't register %s: %s", getRemoteIp(req), rmtScript.String(), err.Error())
+			continue
+		}
+		log.Infof("Client '%s' on api registered: %s.", getRemoteIp(req), rmtScript.String())
 	}
 
 	w.WriteHeader(http.StatusCreated)
 }
 func (g *Gubot) remoteScriptToScript(rmtScript RemoteScript) Script {
 	script := rmtScript.ToScript()
-	script.Function = func(envelop Envelop, submatch [][]string) ([]string, error) {
-		return g.sendEnvelopToScript(envelop, submatch, rmtScript)
+	script.ContextFunction = func(ctx context.Context, envelop Envelop, submatch [][]string) ([]string, error) {
+		return g.sendEnvelopToScript(ctx, envelop, submatch, rmtScript)
 	}
 	return script
 }
@@ -98,11 +104,14 @@ func (g *Gubot) deleteRemoteScripts(w http.ResponseWriter, req *http.Request) {
 		if !g.isRemoteScriptExists(script) {
 			continue
 		}
-		var whereScript RemoteScript
-		whereScript.Name = script.Name
-		g.Store().Unscoped().Where(&whereScript).Delete(RemoteScript{})
-		g.UnregisterScript(g.remoteScriptToScript(script))
-		log.Info("Client '%s' on api delete script: %s.", getRemoteIp(req), script.String())
+		var dbScript RemoteScript
+		g.Store().Where("name = ?", script.Name).First(&dbScript)
+		g.Store().Unscoped().Where("name = ?", script.Name).Delete(RemoteScript{})
+		err = g.UnregisterScript(g.remoteScriptToScript(dbScript))
+		if err != nil {
+			log.Errorf("Client '%s' on api can't unregister %s: %s", getRemoteIp(req), dbScript.String(), err.Error())
+		}
+		log.Infof("Client '%s' on api delete script: %s.", getRemoteIp(req), script.String())
 	}
 	w.WriteHeader(http.StatusOK)
 }
@@ -132,7 +141,16 @@ func (g *Gubot) updateRemoteScripts(w http.ResponseWriter, req *http.Request) {
 		if !g.isRemoteScriptExists(script) {
 			notExistingScript = append(notExistingScript, script)
 		}
-
+		err = g.checkRemoteScript(script)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			data, _ := json.Marshal(HttpError{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			})
+			w.Write(data)
+			return
+		}
 	}
 	if len(notExistingScript) > 0 {
 		w.WriteHeader(http.StatusNotModified)
@@ -140,24 +158,59 @@ func (g *Gubot) updateRemoteScripts(w http.ResponseWriter, req *http.Request) {
 		w.Write(data)
 		return
 	}
+	var result error
 	for _, script := range tmpScripts {
 		var dbScript RemoteScript
-		var whereScript RemoteScript
-		whereScript.Name = script.Name
-		g.Store().Where(&whereScript).First(&dbScript)
-		g.UnregisterScript(dbScript.ToScript())
+		g.Store().Where("name = ?", script.Name).First(&dbScript)
+		oldScript := g.remoteScriptToScript(dbScript)
 		log.Infof("Client '%s' on api update script: %s.", getRemoteIp(req), dbScript.String())
 		dbScript.Matcher = script.Matcher
+		dbScript.MatcherType = script.MatcherType
 		dbScript.Type = script.Type
 		dbScript.Url = script.Url
 		dbScript.TriggerOnMention = script.TriggerOnMention
 		dbScript.Description = script.Description
 		dbScript.Example = script.Example
+		dbScript.Category = script.Category
+		dbScript.TimeoutInSeconds = script.TimeoutInSeconds
+		err = g.replaceScript(oldScript, g.remoteScriptToScript(dbScript))
+		if err != nil {
+			log.Errorf("Client '%s' on api can't update %s: %s", getRemoteIp(req), dbScript.String(), err.Error())
+			result = multierror.Append(result, err)
+			continue
+		}
 		g.Store().Save(&dbScript)
-		g.RegisterScript(g.remoteScriptToScript(script))
+	}
+	if result != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		data, _ := json.Marshal(HttpError{
+			Code:    http.StatusInternalServerError,
+			Message: result.Error(),
+		})
+		w.Write(data)
+		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
+
+// replaceScript update a registered script, it is registered again when its matcher or type change
+// because they identify a script.
+func (g *Gubot) replaceScript(oldScript, newScript Script) error {
+	if oldScript.Matcher == newScript.Matcher && oldScript.Type == newScript.Type {
+		return g.UpdateScript(newScript)
+	}
+	err := g.UnregisterScript(oldScript)
+	if err != nil {
+		return err
+	}
+	err = g.RegisterScript(newScript)
+	if err != nil {
+		// previous script is restored to not lose it
+		g.RegisterScript(oldScript)
+		return err
+	}
+	return nil
+}
 func (g *Gubot) sendMessagesRemoteScripts(w http.ResponseWriter, req *http.Request) {
 	g.envelopeMessagesSend(w, req, Tsend)
 }
@@ -210,12 +263,13 @@ func (g *Gubot) retrieveRemoteScript(r io.Reader) ([]RemoteScript, error) {
 	return []RemoteScript{tmpScript}, nil
 }
 func (g *Gubot) checkRemoteScript(script RemoteScript) error {
-	if script.Name != "" && script.Matcher != "" && script.Type != "" && script.Url != "" {
-		return nil
+	if script.Name == "" || script.Matcher == "" || script.Type == "" || script.Url == "" {
+		return errors.New("Script must give a json with matcher, name, type and url key.")
 	}
-	return errors.New("Script must give a json with matcher, name, type and url key.")
+	toCheck := script.ToScript()
+	return toCheck.compile()
 }
-func (g *Gubot) sendEnvelopToScript(envelop Envelop, subMatch [][]string, script RemoteScript) ([]string, error) {
+func (g *Gubot) sendEnvelopToScript(ctx context.Context, envelop Envelop, subMatch [][]string, script RemoteScript) ([]string, error) {
 	dataToSend := struct {
 		Envelop
 		SubMatch [][]string `json:"sub_match"`
@@ -230,10 +284,11 @@ func (g *Gubot) sendEnvelopToScript(envelop Envelop, subMatch [][]string, script
 		return messages, err
 	}
 	req.Header.Set("Content-type", "application/json")
-	resp, err := g.HttpClient().Do(req)
+	resp, err := g.HttpClient().Do(req.WithContext(ctx))
 	if err != nil {
 		return messages, err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		return messages, errors.New(strconv.Itoa(resp.StatusCode) + " " + resp.Status)
 	}
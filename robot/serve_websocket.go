@@ -42,10 +42,12 @@ func (g *Gubot) serveWebSocket(w http.ResponseWriter, r *http.Request) {
 		log.Error("Upgrade:", err)
 		return
 	}
-	log.Info("Client '%s' on websocket trying to connect", getRemoteIp(r))
+	log.Infof("Client '%s' on websocket trying to connect", getRemoteIp(r))
+	g.trackWebSocket(ws)
 	defer func() {
+		g.untrackWebSocket(ws)
 		ws.Close()
-		log.Info("Client '%s' on websocket disconnected", getRemoteIp(r))
+		log.Infof("Client '%s' on websocket disconnected", getRemoteIp(r))
 	}()
 	seq := 1
 	var tokenRequest WebSocketTokenRequest
@@ -67,7 +69,7 @@ func (g *Gubot) serveWebSocket(w http.ResponseWriter, r *http.Request) {
 			Status:   WEB_SOCKET_STATUS_FAIL,
 			Error:    "Invalid token",
 		})
-		log.Info("Client '%s' on websocket use wrong token", getRemoteIp(r))
+		log.Infof("Client '%s' on websocket use wrong token", getRemoteIp(r))
 		return
 	}
 	if tokenRequest.Seq != seq {
@@ -78,7 +80,7 @@ func (g *Gubot) serveWebSocket(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	log.Info("Client '%s' on websocket is connected", getRemoteIp(r))
+	log.Infof("Client '%s' on websocket is connected", getRemoteIp(r))
 	err = ws.WriteJSON(WebSocketRequest{
 		SeqReply: seq,
 		Status:   WEB_SOCKET_STATUS_OK,
@@ -91,7 +93,9 @@ func (g *Gubot) serveWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	seq++
-	for event := range g.On("*") {
+	events := g.On("*")
+	defer g.GubotEmitter.Off("*", events)
+	for event := range events {
 		gubotEvent := ToGubotEvent(event)
 		err = sendWebSocketEvent(ws, gubotEvent, seq)
 		if err != nil {
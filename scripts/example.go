@@ -4,6 +4,7 @@ import (
 	"github.com/ArthurHlt/gubot/robot"
 	"fmt"
 	"time"
+	"strconv"
 	"net/http"
 	"encoding/json"
 	"github.com/gorilla/mux"
@@ -17,7 +18,6 @@ func init() {
 	var conf ExampleScriptConfig
 	robot.GetConfig(&conf)
 	e := &ExampleScript{
-		annoy:  make(map[string]bool),
 		config: conf,
 	}
 	// we create the table in database only when the store has been initialized
@@ -26,6 +26,8 @@ func init() {
 	})
 	e.listen()
 	robot.Router().HandleFunc("/gubot/chatsecrets/{channel}", e.handlerChatsecret)
+	// action called when a user click on a button of the coffee message
+	robot.RegisterAction("example_coffee", e.coffeeAction)
 	robot.RegisterScripts([]robot.Script{
 		{
 			Name:     "badger",
@@ -34,10 +36,27 @@ func init() {
 			Type:     robot.Tsend, // will just send a message
 		},
 		{
-			Name:     "doors",
-			Matcher:  "(?i)open the (.*) doors", // you can have text inside (.*) inside submatch in your function
-			Function: e.doors,
-			Type:     robot.Trespond, // will send a message by explicitly responding to a user
+			Name:        "badger card",
+			Description: "show a badger in a rich message",
+			Example:     "show me a badger",
+			Matcher:     "(?i)^show me a badger$",
+			Function:    e.badgerCard,
+			Type:        robot.Tsend,
+		},
+		{
+			Name:        "coffee",
+			Description: "ask who wants a coffee with buttons",
+			Example:     "coffee time",
+			Matcher:     "(?i)^coffee time$",
+			Function:    e.coffee,
+			Type:        robot.Tsend,
+		},
+		{
+			Name:        "doors",
+			Matcher:     "open the * doors", // you can have text matched by * inside submatch in your function
+			MatcherType: robot.MatcherGlob,
+			Function:    e.doors,
+			Type:        robot.Trespond, // will send a message by explicitly responding to a user
 		},
 		{
 			Name:     "lulz",
@@ -69,15 +88,50 @@ func init() {
 		{
 			Name:        "menu order",
 			Description: "order something to eat",
-			Example:     "menu order with pizza, coca & pies",
-			Matcher:     "(?i)menu order (with)? ([a-z]*) ([a-z]*)? ([a-z]*)?",
-			Function:    e.menu,
-			Sanitizer:   robot.SanitizeDefaultWithSpecialChar,
-			Type:        robot.Tsend,
+			Category:    "menu",
+			Example:     "menu order pizza coca --dessert pies",
+			Command: &robot.Command{
+				Name: "menu order",
+				Args: []robot.CommandArg{
+					{Name: "plate", Description: "what you want to eat"},
+					{Name: "drink", Description: "what you want to drink", Default: "water"},
+				},
+				Flags: []robot.CommandFlag{
+					{Name: "dessert", Short: "d", Type: robot.ArgString, Description: "add a dessert"},
+				},
+			},
+			ArgsFunction: e.menu,
+			Type:         robot.Tsend,
+		},
+		{
+			Name:        "menu ask",
+			Description: "order something to eat by answering questions",
+			Category:    "menu",
+			Example:     "menu ask",
+			Matcher:     "(?i)^menu ask$",
+			Function:    e.menuAsk,
+			Type:        robot.Trespond,
+		},
+		{
+			Name:        "favorite",
+			Description: "remember your favorite things",
+			Example:     "my favorite drink is coca",
+			Matcher:     "(?i)^my favorite ([a-z]+) is (.+)$",
+			Function:    e.favorite,
+			Type:        robot.Trespond,
+		},
+		{
+			Name:        "favorites",
+			Description: "list your favorite things",
+			Example:     "what are my favorites",
+			Matcher:     "(?i)^what are my favorites$",
+			Function:    e.favorites,
+			Type:        robot.Trespond,
 		},
 		{
 			Name:             "menu list",
 			Description:      "list all orders",
+			Category:         "menu",
 			Example:          "menu list",
 			Matcher:          "(?i)menu list",
 			TriggerOnMention: true,
@@ -100,7 +154,6 @@ type ExampleScriptConfig struct {
 }
 type ExampleScript struct {
 	config ExampleScriptConfig
-	annoy  map[string]bool
 }
 type SecretMessage struct {
 	Secret string
@@ -110,6 +163,46 @@ func (e ExampleScript) badger(envelop robot.Envelop, subMatch [][]string) ([]str
 	return []string{"Badgers? BADGERS? WE DON'T NEED NO STINKIN BADGERS"}, nil
 }
 
+// badgerCard send a rich message, adapters which can't show it receive a text version
+func (e ExampleScript) badgerCard(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
+	message := robot.Message{
+		Title:    "Honey badger",
+		TitleUrl: "https://en.wikipedia.org/wiki/Honey_badger",
+		Markdown: "Honey badger **don't care**.",
+		Color:    "#8B4513",
+		Fields: []robot.MessageField{
+			{Title: "Family", Value: "Mustelidae", Short: true},
+			{Title: "Fear", Value: "None", Short: true},
+		},
+		Images: []robot.MessageImage{
+			{Url: "https://upload.wikimedia.org/wikipedia/commons/5/52/Honey_badger.jpg", AltText: "a honey badger"},
+		},
+	}
+	return []string{}, robot.SendRichMessages(envelop, message)
+}
+
+// coffee send buttons, when clicked the registered action "example_coffee" receive the button value
+func (e ExampleScript) coffee(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
+	message := robot.Message{
+		Text: "Who wants a coffee?",
+		Buttons: []robot.MessageButton{
+			{Text: "Me!", Action: "example_coffee", Value: "yes", Style: "primary"},
+			{Text: "Not me", Action: "example_coffee", Value: "no"},
+		},
+	}
+	return []string{}, robot.SendRichMessages(envelop, message)
+}
+
+func (e ExampleScript) coffeeAction(callback robot.ActionCallback) (robot.ActionResponse, error) {
+	if callback.Value == "no" {
+		return robot.ActionResponse{Text: "Ok, no coffee for you."}, nil
+	}
+	return robot.ActionResponse{
+		Text:   callback.Envelop.User.Name + " is making coffee.",
+		Update: true,
+	}, nil
+}
+
 func (e ExampleScript) doors(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
 	doorType := subMatch[0][1]
 	if doorType == "pod bay" {
@@ -121,10 +214,11 @@ func (e ExampleScript) doors(envelop robot.Envelop, subMatch [][]string) ([]stri
 func (e ExampleScript) lulz(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
 	return []string{"lol", "rofl", "lmao"}, nil
 }
-func (e ExampleScript) menu(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
-	plate := subMatch[0][2]
-	drink := subMatch[0][3]
-	dessert := subMatch[0][4]
+func (e ExampleScript) menu(envelop robot.Envelop, args robot.Args) ([]string, error) {
+	e.createOrder(envelop, args.String("plate"), args.String("drink"), args.String("dessert"))
+	return e.menuShow(envelop, nil)
+}
+func (e ExampleScript) createOrder(envelop robot.Envelop, plate, drink, dessert string) {
 	var user robot.User
 	robot.Store().Where(&robot.User{
 		UserId: envelop.User.Id,
@@ -135,7 +229,32 @@ func (e ExampleScript) menu(envelop robot.Envelop, subMatch [][]string) ([]strin
 		Drink:   drink,
 		Dessert: dessert,
 	})
-	return e.menuShow(envelop, subMatch)
+}
+
+// menuAsk start a dialog with the user, each answer is given to the next step
+func (e ExampleScript) menuAsk(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
+	err := robot.Ask(envelop, robot.Dialog{
+		CancelMessage:  "Order cancelled.",
+		TimeoutMessage: "You took too long to choose, order cancelled.",
+		Handler: func(envelop robot.Envelop) ([]string, error) {
+			plate := envelop.Message
+			err := robot.Ask(envelop, robot.Dialog{
+				CancelMessage: "Order cancelled.",
+				Handler: func(envelop robot.Envelop) ([]string, error) {
+					e.createOrder(envelop, plate, envelop.Message, "")
+					return e.menuShow(envelop, nil)
+				},
+			})
+			if err != nil {
+				return []string{}, err
+			}
+			return []string{"And what do you want to drink?"}, nil
+		},
+	})
+	if err != nil {
+		return []string{}, err
+	}
+	return []string{"What plate do you want? (say `cancel` to stop)"}, nil
 }
 func (e ExampleScript) menuShow(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
 	var menus []ExampleMenu
@@ -155,6 +274,28 @@ func (e ExampleScript) menuShow(envelop robot.Envelop, subMatch [][]string) ([]s
 	}
 	return []string{res}, nil
 }
+func (e ExampleScript) favorite(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
+	// values stored in user scope are only visible for this user
+	err := robot.GetBrain().Set(robot.UserScope(envelop), subMatch[0][1], subMatch[0][2], 0)
+	if err != nil {
+		return []string{}, err
+	}
+	return []string{"I will remember it."}, nil
+}
+func (e ExampleScript) favorites(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
+	favorites, err := robot.GetBrain().List(robot.UserScope(envelop))
+	if err != nil {
+		return []string{}, err
+	}
+	if len(favorites) == 0 {
+		return []string{"I don't know your favorites yet."}, nil
+	}
+	res := "Your favorites are: \n"
+	for _, key := range robot.SortedKeys(favorites) {
+		res += fmt.Sprintf("- %s: %s\n", key, favorites[key])
+	}
+	return []string{res}, nil
+}
 func (e ExampleScript) topic(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
 	return []string{envelop.Message + "? that's a paddlin"}, nil
 }
@@ -166,25 +307,40 @@ func (e *ExampleScript) ultimateQuestion(envelop robot.Envelop, subMatch [][]str
 	return []string{e.config.GubotAnswerToTheUltimateQuestionOfLifeTheUniverseAndEverything + ", but what is the question?"}, nil
 }
 func (e *ExampleScript) annoyMe(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
-	e.annoy[envelop.ChannelName+envelop.ChannelId] = true
-	go func() {
-		for {
-			if !e.annoy[envelop.ChannelName+envelop.ChannelId] {
-				break
-			}
-			robot.SendMessages(envelop, "AAAAAAAAAAAEEEEEEEEEEEEEEEEEEEEEEEEIIIIIIIIHHHHHHHHHH")
-			time.Sleep(2 * time.Second)
-		}
-	}()
+	// only one job is kept in brain, a second one could never be cancelled
+	_, found, err := robot.GetBrain().Get(robot.ChannelScope(envelop), "annoy_job")
+	if err != nil {
+		return []string{}, err
+	}
+	if found {
+		return []string{"I'm already annoying you, am I not?"}, nil
+	}
+	// job is stored and will continue to annoy even after a restart
+	job, err := robot.ScheduleEvery(envelop, 2*time.Second, robot.Tsend, "AAAAAAAAAAAEEEEEEEEEEEEEEEEEEEEEEEEIIIIIIIIHHHHHHHHHH")
+	if err != nil {
+		return []string{}, err
+	}
+	err = robot.GetBrain().Set(robot.ChannelScope(envelop), "annoy_job", strconv.Itoa(int(job.ID)), 0)
+	if err != nil {
+		return []string{}, err
+	}
 	return []string{"Hey, want to hear the most annoying sound in the world?"}, nil
 }
 func (e *ExampleScript) unannoyMe(envelop robot.Envelop, subMatch [][]string) ([]string, error) {
-	message := "Not annoying you right now, am I?"
-	if e.annoy[envelop.ChannelName+envelop.ChannelId] {
-		e.annoy[envelop.ChannelName+envelop.ChannelId] = false
-		message = "GUYS, GUYS, GUYS!"
+	jobId, found, err := robot.GetBrain().Get(robot.ChannelScope(envelop), "annoy_job")
+	if err != nil {
+		return []string{}, err
+	}
+	if !found {
+		return []string{"Not annoying you right now, am I?"}, nil
+	}
+	id, _ := strconv.Atoi(jobId)
+	err = robot.CancelJob(envelop, uint(id))
+	if err != nil {
+		return []string{}, err
 	}
-	return []string{message}, nil
+	robot.GetBrain().Delete(robot.ChannelScope(envelop), "annoy_job")
+	return []string{"GUYS, GUYS, GUYS!"}, nil
 }
 func (e ExampleScript) handlerChatsecret(w http.ResponseWriter, req *http.Request) {
 	var secretMessage SecretMessage